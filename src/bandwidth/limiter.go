@@ -0,0 +1,226 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+// Package bandwidth caps the rate of uploads and downloads. One Limiter
+// can be shared by any number of concurrent transfers, which then share
+// its budget.
+package bandwidth
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Window is a time of day with its own rate.
+type Window struct {
+	// Start and End are offsets from midnight, in local time. A window
+	// whose End is before its Start runs past midnight.
+	Start time.Duration
+	End   time.Duration
+	// BytesPerSecond is the rate during the window. 0 is unlimited.
+	BytesPerSecond int64
+}
+
+func (w Window) contains(timeOfDay time.Duration) bool {
+	if w.End < w.Start {
+		return timeOfDay >= w.Start || timeOfDay < w.End
+	}
+
+	return timeOfDay >= w.Start && timeOfDay < w.End
+}
+
+// Schedule is a rate that depends on the time of day.
+type Schedule struct {
+	// Default is the rate outside every window. 0 is unlimited.
+	Default int64
+	// Windows are checked in order, and the first that
+	// contains the time of day sets the rate
+	Windows []Window
+}
+
+// RateAt returns the rate in bytes per second at t. 0 is unlimited.
+func (s Schedule) RateAt(t time.Time) int64 {
+	// The wall clock, so windows keep their hours when clocks change
+	timeOfDay := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	for _, window := range s.Windows {
+		if window.contains(timeOfDay) {
+			return window.BytesPerSecond
+		}
+	}
+
+	return s.Default
+}
+
+// Limiter lets bytes through at the rate of its schedule. Bytes that
+// were not used in the last second are saved, so a transfer can briefly
+// go faster after a pause, but never by more than one second's worth.
+type Limiter struct {
+	schedule Schedule
+
+	mu sync.Mutex
+	// tokens is the number of bytes that can be let through now.
+	// It is negative while callers are waiting for their bytes.
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a Limiter of bytesPerSecond. 0 is unlimited.
+func NewLimiter(bytesPerSecond int64) *Limiter {
+	return NewScheduledLimiter(Schedule{Default: bytesPerSecond})
+}
+
+// NewScheduledLimiter returns a Limiter whose rate follows schedule.
+func NewScheduledLimiter(schedule Schedule) *Limiter {
+	return &Limiter{schedule: schedule, last: time.Now()}
+}
+
+// WaitN blocks until n more bytes can be transferred, or ctx ends. Bytes
+// are granted in the order they are asked for, so concurrent callers
+// share the rate. A nil Limiter never blocks.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	if l == nil || n <= 0 || ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	rate := float64(l.schedule.RateAt(now))
+	if rate <= 0 {
+		l.tokens, l.last = 0, now
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+
+	l.tokens = min(rate, l.tokens+now.Sub(l.last).Seconds()*rate)
+	l.last = now
+	l.tokens -= float64(n)
+	wait := time.Duration(-l.tokens / rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give the bytes back to the callers behind this one
+		l.mu.Lock()
+		l.tokens += float64(n)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// ParseLimit parses a limit given on the command line. It is a comma
+// separated list of a rate, and of rates for times of day in the form
+// HH:MM-HH:MM=rate. A rate is a number of bytes per second, optionally
+// followed by a unit such as KB, KiB, MB or MiB, and 0 is unlimited.
+// Times of day are local. For example, "09:00-18:00=512KiB,4MiB" allows
+// 512 KiB/s during office hours and 4 MiB/s at other times. Without a
+// rate outside the windows, other times are unlimited.
+//
+// It returns nil for an empty limit.
+func ParseLimit(spec string) (*Limiter, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	var schedule Schedule
+	hasDefault := false
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		times, rateValue, isWindow := strings.Cut(part, "=")
+		if !isWindow {
+			if hasDefault {
+				return nil, fmt.Errorf("limit %q has more than one rate outside a time window", spec)
+			}
+			rate, err := ParseRate(part)
+			if err != nil {
+				return nil, err
+			}
+			schedule.Default = rate
+			hasDefault = true
+			continue
+		}
+
+		window, err := parseWindow(times)
+		if err != nil {
+			return nil, err
+		}
+		window.BytesPerSecond, err = ParseRate(rateValue)
+		if err != nil {
+			return nil, err
+		}
+		schedule.Windows = append(schedule.Windows, window)
+	}
+
+	return NewScheduledLimiter(schedule), nil
+}
+
+// units are the multipliers of the rate units ParseRate accepts
+var units = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"kib": 1024,
+	"mb":  1000 * 1000,
+	"mib": 1024 * 1024,
+	"gb":  1000 * 1000 * 1000,
+	"gib": 1024 * 1024 * 1024,
+}
+
+// ParseRate parses a number of bytes per second, such as 1500000,
+// 1.5MB or 512KiB. A trailing /s is allowed.
+func ParseRate(value string) (int64, error) {
+	trimmed := strings.TrimSuffix(strings.TrimSpace(value), "/s")
+	number := strings.TrimRightFunc(trimmed, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	multiplier, ok := units[strings.ToLower(strings.TrimSpace(trimmed[len(number):]))]
+	amount, err := strconv.ParseFloat(number, 64)
+	if !ok || err != nil || amount < 0 || math.IsInf(amount*multiplier, 0) {
+		return 0, fmt.Errorf("invalid rate %q", value)
+	}
+
+	return int64(amount * multiplier), nil
+}
+
+// parseWindow parses the HH:MM-HH:MM of a window
+func parseWindow(value string) (Window, error) {
+	startValue, endValue, found := strings.Cut(value, "-")
+	if !found {
+		return Window{}, fmt.Errorf("invalid time window %q", value)
+	}
+
+	start, err := parseTimeOfDay(startValue)
+	if err != nil {
+		return Window{}, err
+	}
+	end, err := parseTimeOfDay(endValue)
+	if err != nil {
+		return Window{}, err
+	}
+	if start == end {
+		return Window{}, fmt.Errorf("time window %q is empty", value)
+	}
+
+	return Window{Start: start, End: end}, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -0,0 +1,156 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package bandwidth_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sdksnippets/bandwidth"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	for value, want := range map[string]int64{
+		"0":        0,
+		"1500":     1500,
+		"512KiB":   512 * 1024,
+		"1.5MB":    1500000,
+		"2 mib/s":  2 * 1024 * 1024,
+		"1GB":      1000 * 1000 * 1000,
+		" 100b/s ": 100,
+	} {
+		got, err := bandwidth.ParseRate(value)
+		if err != nil || got != want {
+			t.Errorf("ParseRate(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+
+	for _, value := range []string{"", "fast", "-1", "10 bits", "MiB"} {
+		if _, err := bandwidth.ParseRate(value); err == nil {
+			t.Errorf("ParseRate(%q) succeeded", value)
+		}
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	limiter, err := bandwidth.ParseLimit("")
+	if limiter != nil || err != nil {
+		t.Errorf("empty limit = %v, %v, want nil", limiter, err)
+	}
+
+	for _, spec := range []string{"1MiB,2MiB", "09:00=1MiB", "9-17=1MiB", "09:00-09:00=1MiB", "25:00-26:00=1MiB"} {
+		if _, err := bandwidth.ParseLimit(spec); err == nil {
+			t.Errorf("ParseLimit(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestScheduleRateAt(t *testing.T) {
+	at := func(hour int, minute int) time.Time {
+		return time.Date(2024, 3, 4, hour, minute, 0, 0, time.Local)
+	}
+	schedule := bandwidth.Schedule{
+		Default: 4000,
+		Windows: []bandwidth.Window{
+			{Start: 9 * time.Hour, End: 18 * time.Hour, BytesPerSecond: 1000},
+			// Runs past midnight
+			{Start: 23 * time.Hour, End: 2 * time.Hour},
+		},
+	}
+
+	for _, test := range []struct {
+		at   time.Time
+		want int64
+	}{
+		{at(8, 59), 4000},
+		{at(9, 0), 1000},
+		{at(17, 59), 1000},
+		{at(18, 0), 4000},
+		{at(23, 30), 0},
+		{at(1, 59), 0},
+		{at(2, 0), 4000},
+	} {
+		if got := schedule.RateAt(test.at); got != test.want {
+			t.Errorf("RateAt(%s) = %d, want %d", test.at.Format("15:04"), got, test.want)
+		}
+	}
+}
+
+func TestLimiterSharesBudget(t *testing.T) {
+	limiter := bandwidth.NewLimiter(1000 * 1000)
+
+	// Four transfers of 50 KB each share 1 MB/s, so
+	// together they take at least 200 ms
+	started := time.Now()
+	var transfers sync.WaitGroup
+	for range 4 {
+		transfers.Go(func() {
+			for range 5 {
+				if err := limiter.WaitN(context.Background(), 10*1000); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+	transfers.Wait()
+
+	elapsed := time.Since(started)
+	if elapsed < 190*time.Millisecond || elapsed > time.Second {
+		t.Errorf("200 KB at 1 MB/s took %v", elapsed)
+	}
+}
+
+func TestLimiterCancelled(t *testing.T) {
+	limiter := bandwidth.NewLimiter(1000)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	err := limiter.WaitN(ctx, 1000*1000)
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(started) > time.Second {
+		t.Errorf("WaitN = %v after %v, want it to stop with the context", err, time.Since(started))
+	}
+
+	// A nil limiter never waits
+	var unlimited *bandwidth.Limiter
+	if err := unlimited.WaitN(context.Background(), 1000*1000); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLimitByteStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(path, []byte("0123456789"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if stream := bandwidth.LimitByteStream(context.Background(), file, nil); stream != file {
+		t.Error("a nil limiter wrapped the stream")
+	}
+
+	stream := bandwidth.LimitByteStream(context.Background(), file, bandwidth.NewLimiter(1000*1000))
+	buffer := make([]byte, 4)
+	if _, err := stream.ReadAt(buffer, 3); err != nil || string(buffer) != "3456" {
+		t.Errorf("ReadAt = %q, %v", buffer, err)
+	}
+	if info, err := stream.Stat(); err != nil || info.Size() != 10 {
+		t.Errorf("Stat = %v, %v", info, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stream = bandwidth.LimitByteStream(ctx, file, bandwidth.NewLimiter(1))
+	if _, err := stream.ReadAt(buffer, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadAt after cancel = %v, want context.Canceled", err)
+	}
+}
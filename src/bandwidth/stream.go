@@ -0,0 +1,39 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package bandwidth
+
+import (
+	"context"
+
+	"github.com/microsoftgraph/msgraph-sdk-go-core/fileuploader"
+)
+
+// byteStream waits for the limiter before every read
+type byteStream struct {
+	fileuploader.ByteStream
+	ctx     context.Context
+	limiter *Limiter
+}
+
+func (s *byteStream) ReadAt(p []byte, off int64) (int, error) {
+	err := s.limiter.WaitN(s.ctx, len(p))
+	if err != nil {
+		return 0, err
+	}
+
+	return s.ByteStream.ReadAt(p, off)
+}
+
+// LimitByteStream returns a stream that reads from stream no faster than
+// limiter allows, for fileuploader.NewLargeFileUploadTask. Upload tasks
+// read a whole slice at a time and send it at full speed, so the rate
+// holds on average, and smaller slices make it steadier. Reads fail with
+// ctx.Err() once ctx ends. If limiter is nil, stream is returned as it is.
+func LimitByteStream(ctx context.Context, stream fileuploader.ByteStream, limiter *Limiter) fileuploader.ByteStream {
+	if limiter == nil {
+		return stream
+	}
+
+	return &byteStream{ByteStream: stream, ctx: ctx, limiter: limiter}
+}
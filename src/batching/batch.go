@@ -0,0 +1,472 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+// Package batching sends any number of requests through Microsoft Graph
+// JSON batching, splitting them into batches Graph will accept.
+package batching
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoft/kiota-abstractions-go/serialization"
+	graphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
+)
+
+// MaxBatchSize is the most requests Graph accepts in a single $batch call
+const MaxBatchSize = 20
+
+// RetryOptions controls how steps that Graph throttled or could not
+// handle are sent again. A $batch request can succeed while individual
+// steps return 429, 503 or 504.
+type RetryOptions struct {
+	// MaxAttempts is the most times a step is sent, including the first.
+	// 1 disables retries.
+	MaxAttempts int
+	// Delay is the wait before the first retry when no step has a
+	// Retry-After header. It doubles for each later retry.
+	Delay time.Duration
+	// MaxDelay caps the wait between attempts, including Retry-After.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryOptions matches the defaults of the kiota retry handler,
+// which retries whole requests but not individual batch steps.
+var DefaultRetryOptions = RetryOptions{
+	MaxAttempts: 4,
+	Delay:       3 * time.Second,
+	MaxDelay:    180 * time.Second,
+}
+
+// Batch collects requests under IDs chosen by the caller. Unlike
+// graphcore.BatchRequest it has no limit on the number of steps.
+type Batch struct {
+	adapter   abstractions.RequestAdapter
+	retry     RetryOptions
+	steps     []*step
+	stepsById map[string]*step
+}
+
+type step struct {
+	id        string
+	request   abstractions.RequestInformation
+	dependsOn []string
+	// factory parses the response, if set by AddTypedStep
+	factory serialization.ParsableFactory
+}
+
+// NewBatch creates an empty batch that is sent with adapter and
+// retries steps with DefaultRetryOptions.
+func NewBatch(adapter abstractions.RequestAdapter) *Batch {
+	return NewBatchWithRetryOptions(adapter, DefaultRetryOptions)
+}
+
+// NewBatchWithRetryOptions creates an empty batch that is sent with
+// adapter and retries steps as described by options.
+func NewBatchWithRetryOptions(adapter abstractions.RequestAdapter, options RetryOptions) *Batch {
+	if options.MaxAttempts < 1 {
+		options.MaxAttempts = 1
+	}
+
+	return &Batch{
+		adapter:   adapter,
+		retry:     options,
+		stepsById: map[string]*step{},
+	}
+}
+
+// AddStep adds a request to the batch. Graph runs it only after every
+// step in dependsOn has succeeded, so those steps must already be added.
+func (b *Batch) AddStep(id string, request abstractions.RequestInformation, dependsOn ...string) error {
+	if id == "" {
+		return fmt.Errorf("batch step ID must not be empty")
+	}
+	if _, exists := b.stepsById[id]; exists {
+		return fmt.Errorf("batch step %q was already added", id)
+	}
+	for _, dependency := range dependsOn {
+		if _, exists := b.stepsById[dependency]; !exists {
+			return fmt.Errorf("batch step %q depends on %q, which has not been added", id, dependency)
+		}
+	}
+
+	newStep := &step{
+		id:        id,
+		request:   request,
+		dependsOn: dependsOn,
+	}
+	b.steps = append(b.steps, newStep)
+	b.stepsById[id] = newStep
+
+	return nil
+}
+
+// Result holds the response to every step in a Batch. Responses are
+// keyed by the caller's step IDs, so it can be read with
+// graphcore.GetBatchResponseById.
+type Result struct {
+	graphcore.BatchResponse
+
+	steps    []*step
+	outcomes []StepOutcome
+}
+
+// StepOutcome is the final state of one step after any retries.
+type StepOutcome struct {
+	Id string
+	// Status is 0 if Graph never returned a response for the step
+	Status int32
+	// Attempts is the number of times the step was sent. It is 0 for
+	// steps that were answered with 424 because a dependency failed.
+	Attempts int
+	// RetriesExhausted is set if the step could still be retried when
+	// the MaxAttempts limit was reached.
+	RetriesExhausted bool
+}
+
+// Outcomes returns the outcome of every step, in the order they were added.
+func (r *Result) Outcomes() []StepOutcome {
+	return r.outcomes
+}
+
+// Outcome returns the outcome of the step with the given ID.
+func (r *Result) Outcome(id string) (StepOutcome, bool) {
+	for _, outcome := range r.outcomes {
+		if outcome.Id == id {
+			return outcome, true
+		}
+	}
+
+	return StepOutcome{}, false
+}
+
+// Send sends the steps in as few $batch requests as possible. Steps
+// connected by dependencies share a batch when they fit in one. Larger
+// dependency chains are split across batches that are sent in order,
+// and a step whose dependency failed in an earlier batch is not sent
+// but answered with 424 Failed Dependency, as Graph does.
+//
+// Steps that return 429, 503 or 504 are sent again after the longest
+// Retry-After among them, together with the steps that failed because
+// they depend on them. Steps missing from a batch response are sent
+// again too. Steps that succeeded are not sent again.
+func (b *Batch) Send(ctx context.Context) (*Result, error) {
+	responses := map[string]graphcore.BatchItem{}
+	attempts := map[string]int{}
+
+	var err error
+	exhausted := false
+	pending := b.steps
+	for attempt := 1; ; attempt++ {
+		for _, step := range pending {
+			delete(responses, step.id)
+		}
+
+		err = b.sendSteps(ctx, pending, responses, attempts)
+		if err != nil {
+			break
+		}
+		if attempt == b.retry.MaxAttempts {
+			exhausted = true
+			break
+		}
+
+		var delay time.Duration
+		pending, delay = b.retryableSteps(pending, responses, attempt)
+		if len(pending) == 0 {
+			break
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = ctx.Err()
+		case <-timer.C:
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	return b.result(responses, attempts, exhausted), err
+}
+
+func (b *Batch) result(responses map[string]graphcore.BatchItem, attempts map[string]int, exhausted bool) *Result {
+	// Keep the order the steps were added in
+	result := &Result{
+		BatchResponse: graphcore.NewBatchResponse(),
+		steps:         b.steps,
+	}
+	for _, step := range b.steps {
+		response, answered := responses[step.id]
+		if !answered && attempts[step.id] == 0 {
+			// Not sent because an earlier batch could not be sent
+			continue
+		}
+		if answered {
+			result.AddResponses([]graphcore.BatchItem{response})
+		}
+
+		status, ok := stepStatus(responses, step.id)
+		outcome := StepOutcome{
+			Id:       step.id,
+			Status:   status,
+			Attempts: attempts[step.id],
+		}
+		outcome.RetriesExhausted = exhausted && (!ok || retryable(status))
+		result.outcomes = append(result.outcomes, outcome)
+	}
+
+	return result
+}
+
+// retryableSteps returns the steps to send again and how long to wait
+// first. Steps that Graph did not answer are included, and so are steps
+// that failed with 424 when a step they depend on is being retried.
+func (b *Batch) retryableSteps(steps []*step, responses map[string]graphcore.BatchItem, attempt int) ([]*step, time.Duration) {
+	var retry []*step
+	retrying := map[string]bool{}
+	var retryAfter time.Duration
+	hasRetryAfter := false
+
+	for _, step := range steps {
+		status, ok := stepStatus(responses, step.id)
+		if !ok || retryable(status) {
+			retry = append(retry, step)
+			retrying[step.id] = true
+
+			if !ok {
+				continue
+			}
+			if delay, ok := parseRetryAfter(responses[step.id].GetHeaders()); ok {
+				hasRetryAfter = true
+				retryAfter = max(retryAfter, delay)
+			}
+			continue
+		}
+
+		// Steps are in dependency order, so their dependencies
+		// have already been checked
+		if status == http.StatusFailedDependency {
+			for _, dependency := range step.dependsOn {
+				if retrying[dependency] {
+					retry = append(retry, step)
+					retrying[step.id] = true
+					break
+				}
+			}
+		}
+	}
+
+	delay := retryAfter
+	if !hasRetryAfter {
+		delay = b.retry.Delay << (attempt - 1)
+	}
+	if b.retry.MaxDelay > 0 && delay > b.retry.MaxDelay {
+		delay = b.retry.MaxDelay
+	}
+
+	return retry, delay
+}
+
+// stepStatus returns the status of the response to the step with id,
+// and false if the batch response had no response or status for it
+func stepStatus(responses map[string]graphcore.BatchItem, id string) (int32, bool) {
+	response, ok := responses[id]
+	if !ok || response == nil || response.GetStatus() == nil {
+		return 0, false
+	}
+
+	return *response.GetStatus(), true
+}
+
+func retryable(status int32) bool {
+	return status == http.StatusTooManyRequests ||
+		status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(headers graphcore.RequestHeader) (time.Duration, bool) {
+	for key, value := range headers {
+		if !strings.EqualFold(key, "Retry-After") {
+			continue
+		}
+
+		if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return max(time.Until(date), 0), true
+		}
+	}
+
+	return 0, false
+}
+
+// sendSteps sends steps and stores each response in responses under the
+// caller's step ID, counting each step that is sent in attempts.
+// Dependencies on steps that already have a response are resolved
+// before sending instead of being sent to Graph.
+func (b *Batch) sendSteps(ctx context.Context, steps []*step, responses map[string]graphcore.BatchItem, attempts map[string]int) error {
+	chunks := chunkSteps(steps)
+	for i, chunk := range chunks {
+		batch := graphcore.NewBatchRequest(b.adapter)
+		// Maps the IDs graphcore generates back to the caller's IDs
+		callerIds := map[string]string{}
+		items := map[string]graphcore.BatchItem{}
+
+		for _, step := range chunk {
+			var dependsOn []string
+			failedDependency := ""
+			for _, dependency := range step.dependsOn {
+				if item, sent := items[dependency]; sent {
+					dependsOn = append(dependsOn, *item.GetId())
+				} else if response, ok := responses[dependency]; !ok || !succeeded(response) {
+					failedDependency = dependency
+					break
+				}
+			}
+			if failedDependency != "" {
+				responses[step.id] = failedDependencyResponse(step.id, failedDependency)
+				continue
+			}
+
+			item, err := batch.AddBatchRequestStep(step.request)
+			if err != nil {
+				return fmt.Errorf("adding batch step %q: %w", step.id, err)
+			}
+			if len(dependsOn) > 0 {
+				item.SetDependsOn(dependsOn)
+			}
+			callerIds[*item.GetId()] = step.id
+			items[step.id] = item
+		}
+
+		if len(items) == 0 {
+			continue
+		}
+
+		for id := range items {
+			attempts[id]++
+		}
+
+		batchResponse, err := batch.Send(ctx, b.adapter)
+		if err != nil {
+			return fmt.Errorf("sending batch %d of %d: %w", i+1, len(chunks), err)
+		}
+
+		for _, response := range batchResponse.GetResponses() {
+			if response.GetId() == nil {
+				continue
+			}
+			callerId, ok := callerIds[*response.GetId()]
+			if !ok {
+				continue
+			}
+			response.SetId(&callerId)
+			responses[callerId] = response
+		}
+	}
+
+	return nil
+}
+
+// chunkSteps splits steps into batches of at most MaxBatchSize. Steps
+// connected by dependencies are kept together when they fit in one
+// batch. Steps keep their relative order, and since a step can only
+// depend on earlier steps, every dependency is in the same or an
+// earlier batch.
+func chunkSteps(steps []*step) [][]*step {
+	var chunks [][]*step
+	var current []*step
+	for _, group := range connectedSteps(steps) {
+		if len(group) <= MaxBatchSize && len(current)+len(group) > MaxBatchSize {
+			chunks = append(chunks, current)
+			current = nil
+		}
+		for _, step := range group {
+			if len(current) == MaxBatchSize {
+				chunks = append(chunks, current)
+				current = nil
+			}
+			current = append(current, step)
+		}
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+
+	return chunks
+}
+
+// connectedSteps groups steps that are linked by dependencies, directly
+// or through other steps. Groups are ordered by their first step and
+// each keeps the order of steps.
+func connectedSteps(steps []*step) [][]*step {
+	// Union-find over step IDs
+	parents := map[string]string{}
+	var root func(id string) string
+	root = func(id string) string {
+		parent, ok := parents[id]
+		if !ok || parent == id {
+			return id
+		}
+		parents[id] = root(parent)
+		return parents[id]
+	}
+
+	inSet := map[string]bool{}
+	for _, step := range steps {
+		inSet[step.id] = true
+	}
+	for _, step := range steps {
+		for _, dependency := range step.dependsOn {
+			if inSet[dependency] {
+				parents[root(step.id)] = root(dependency)
+			}
+		}
+	}
+
+	var groups [][]*step
+	groupIndex := map[string]int{}
+	for _, step := range steps {
+		stepRoot := root(step.id)
+		index, ok := groupIndex[stepRoot]
+		if !ok {
+			index = len(groups)
+			groupIndex[stepRoot] = index
+			groups = append(groups, nil)
+		}
+		groups[index] = append(groups[index], step)
+	}
+
+	return groups
+}
+
+func succeeded(response graphcore.BatchItem) bool {
+	status := response.GetStatus()
+	return status != nil && *status < 400
+}
+
+func failedDependencyResponse(id string, dependency string) graphcore.BatchItem {
+	status := int32(http.StatusFailedDependency)
+	response := graphcore.NewBatchItem()
+	response.SetId(&id)
+	response.SetStatus(&status)
+	response.SetHeaders(graphcore.RequestHeader{"Content-Type": "application/json"})
+	response.SetBody(graphcore.RequestBody{
+		"error": map[string]any{
+			"code":    "FailedDependency",
+			"message": "Dependent request " + dependency + " failed.",
+		},
+	})
+
+	return response
+}
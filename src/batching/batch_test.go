@@ -0,0 +1,271 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package batching_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sdksnippets/batching"
+	"sdksnippets/graphmock"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	khttp "github.com/microsoft/kiota-http-go"
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+	graphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+// batchCounter counts the $batch requests sent through the pipeline
+type batchCounter struct {
+	count atomic.Int32
+}
+
+func (c *batchCounter) Intercept(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "/$batch") {
+		c.count.Add(1)
+	}
+	return pipeline.Next(req, middlewareIndex)
+}
+
+func newMockClient(t *testing.T) (*graph.GraphServiceClient, *batchCounter) {
+	t.Helper()
+
+	counter := &batchCounter{}
+	graphClient, _ := graphmock.NewTestClient(t, counter)
+
+	return graphClient, counter
+}
+
+// addMessageStep adds a GET for one of the mock server's seeded messages,
+// which are numbered from 1 to 30
+func addMessageStep(t *testing.T, graphClient *graph.GraphServiceClient, batch *batching.Batch, id string, message int, dependsOn ...string) {
+	t.Helper()
+
+	request, err := graphClient.Me().
+		Messages().
+		ByMessageId(fmt.Sprintf("message-%04d", message)).
+		ToGetRequestInformation(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = batch.AddStep(id, *request, dependsOn...)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendSplitsLargeBatches(t *testing.T) {
+	graphClient, counter := newMockClient(t)
+
+	batch := batching.NewBatch(graphClient.GetAdapter())
+	for i := 0; i < 45; i++ {
+		addMessageStep(t, graphClient, batch, fmt.Sprintf("step-%d", i), i%30+1)
+	}
+
+	result, err := batch.Send(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := counter.count.Load(); got != 3 {
+		t.Errorf("sent %d batches, want 3", got)
+	}
+	for i := 0; i < 45; i++ {
+		message, err := graphcore.GetBatchResponseById[models.Messageable](
+			result, fmt.Sprintf("step-%d", i), models.CreateMessageFromDiscriminatorValue)
+		if err != nil {
+			t.Fatalf("step-%d: %v", i, err)
+		}
+		if want := fmt.Sprintf("message-%04d", i%30+1); *message.GetId() != want {
+			t.Errorf("step-%d returned %s, want %s", i, *message.GetId(), want)
+		}
+	}
+}
+
+func TestSendKeepsDependencyChainsTogether(t *testing.T) {
+	graphClient, counter := newMockClient(t)
+
+	batch := batching.NewBatch(graphClient.GetAdapter())
+	for i := 0; i < 15; i++ {
+		addMessageStep(t, graphClient, batch, fmt.Sprintf("single-%d", i), i+1)
+	}
+	// A chain of 10 does not fit after the first 15 steps,
+	// so it should move to the next batch as a whole
+	addMessageStep(t, graphClient, batch, "chain-0", 1)
+	for i := 1; i < 10; i++ {
+		addMessageStep(t, graphClient, batch, fmt.Sprintf("chain-%d", i), i+1, fmt.Sprintf("chain-%d", i-1))
+	}
+
+	result, err := batch.Send(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := counter.count.Load(); got != 2 {
+		t.Errorf("sent %d batches, want 2", got)
+	}
+	if failed := result.GetFailedResponses(); len(failed) > 0 {
+		t.Errorf("unexpected failed steps: %v", failed)
+	}
+	if got := len(result.GetResponses()); got != 25 {
+		t.Errorf("got %d responses, want 25", got)
+	}
+}
+
+func TestSendFailsDependentsAcrossBatches(t *testing.T) {
+	graphClient, counter := newMockClient(t)
+
+	batch := batching.NewBatch(graphClient.GetAdapter())
+	addMessageStep(t, graphClient, batch, "chain-0", 1)
+	// message-0099 does not exist, so the rest of the chain fails
+	addMessageStep(t, graphClient, batch, "chain-1", 99, "chain-0")
+	for i := 2; i < 25; i++ {
+		addMessageStep(t, graphClient, batch, fmt.Sprintf("chain-%d", i), i, fmt.Sprintf("chain-%d", i-1))
+	}
+
+	result, err := batch.Send(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second part of the chain is answered without being sent
+	if got := counter.count.Load(); got != 1 {
+		t.Errorf("sent %d batches, want 1", got)
+	}
+
+	statuses := result.GetStatusCodes()
+	if statuses["chain-0"] != http.StatusOK || statuses["chain-1"] != http.StatusNotFound {
+		t.Errorf("got statuses %d and %d, want 200 and 404", statuses["chain-0"], statuses["chain-1"])
+	}
+	for i := 2; i < 25; i++ {
+		if status := statuses[fmt.Sprintf("chain-%d", i)]; status != http.StatusFailedDependency {
+			t.Errorf("chain-%d: got status %d, want 424", i, status)
+		}
+	}
+}
+
+func TestAddStepRejectsUnknownDependency(t *testing.T) {
+	graphClient, _ := newMockClient(t)
+
+	batch := batching.NewBatch(graphClient.GetAdapter())
+	request, err := graphClient.Me().ToGetRequestInformation(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = batch.AddStep("me", *request, "missing")
+	if err == nil {
+		t.Error("expected an error for a dependency that was not added")
+	}
+}
+
+// noDelay retries without waiting when steps have no Retry-After
+var noDelay = batching.RetryOptions{MaxAttempts: 3}
+
+func TestSendRetriesThrottledSteps(t *testing.T) {
+	graphClient, server := graphmock.NewTestClient(t)
+
+	server.FailNext("/me/messages/message-0002", 2, http.StatusTooManyRequests, 0)
+	server.FailNext("/me/messages/message-0003", 1, http.StatusServiceUnavailable, 0)
+
+	batch := batching.NewBatchWithRetryOptions(graphClient.GetAdapter(), noDelay)
+	addMessageStep(t, graphClient, batch, "first", 1)
+	addMessageStep(t, graphClient, batch, "throttled", 2)
+	addMessageStep(t, graphClient, batch, "unavailable", 3)
+	// Fails with 424 until "throttled" succeeds
+	addMessageStep(t, graphClient, batch, "dependent", 4, "throttled")
+
+	result, err := batch.Send(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]batching.StepOutcome{
+		"first":       {Id: "first", Status: http.StatusOK, Attempts: 1},
+		"throttled":   {Id: "throttled", Status: http.StatusOK, Attempts: 3},
+		"unavailable": {Id: "unavailable", Status: http.StatusOK, Attempts: 2},
+		"dependent":   {Id: "dependent", Status: http.StatusOK, Attempts: 3},
+	}
+	for _, outcome := range result.Outcomes() {
+		if outcome != want[outcome.Id] {
+			t.Errorf("got outcome %+v, want %+v", outcome, want[outcome.Id])
+		}
+	}
+	if len(result.Outcomes()) != len(want) {
+		t.Errorf("got %d outcomes, want %d", len(result.Outcomes()), len(want))
+	}
+}
+
+func TestSendStopsRetryingAtMaxAttempts(t *testing.T) {
+	graphClient, server := graphmock.NewTestClient(t)
+
+	server.FailNext("/me/messages/message-0001", 5, http.StatusGatewayTimeout, 0)
+
+	batch := batching.NewBatchWithRetryOptions(graphClient.GetAdapter(), noDelay)
+	addMessageStep(t, graphClient, batch, "timeout", 1)
+
+	result, err := batch.Send(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outcome, ok := result.Outcome("timeout")
+	if !ok {
+		t.Fatal("no outcome for step")
+	}
+	want := batching.StepOutcome{Id: "timeout", Status: http.StatusGatewayTimeout, Attempts: 3, RetriesExhausted: true}
+	if outcome != want {
+		t.Errorf("got outcome %+v, want %+v", outcome, want)
+	}
+}
+
+// responseDropper removes every step response from the
+// first drop $batch responses
+type responseDropper struct {
+	drop atomic.Int32
+}
+
+func (d *responseDropper) Intercept(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request) (*http.Response, error) {
+	response, err := pipeline.Next(req, middlewareIndex)
+	if err != nil || !strings.HasSuffix(req.URL.Path, "/$batch") || d.drop.Add(-1) < 0 {
+		return response, err
+	}
+
+	response.Body.Close()
+	response.Body = io.NopCloser(strings.NewReader(`{"responses":[]}`))
+	response.ContentLength = -1
+	response.Header.Del("Content-Length")
+	return response, nil
+}
+
+func TestSendRetriesMissingResponses(t *testing.T) {
+	for _, test := range []struct {
+		maxAttempts int
+		want        batching.StepOutcome
+	}{
+		{3, batching.StepOutcome{Id: "missing", Status: http.StatusOK, Attempts: 2}},
+		{1, batching.StepOutcome{Id: "missing", Attempts: 1, RetriesExhausted: true}},
+	} {
+		dropper := &responseDropper{}
+		dropper.drop.Store(1)
+		graphClient, _ := graphmock.NewTestClient(t, dropper)
+
+		batch := batching.NewBatchWithRetryOptions(graphClient.GetAdapter(), batching.RetryOptions{MaxAttempts: test.maxAttempts})
+		addMessageStep(t, graphClient, batch, "missing", 1)
+
+		result, err := batch.Send(context.Background())
+		if err != nil {
+			t.Fatalf("%d attempts: %v", test.maxAttempts, err)
+		}
+		outcome, ok := result.Outcome("missing")
+		if !ok || outcome != test.want {
+			t.Errorf("%d attempts: got outcome %+v, %v, want %+v", test.maxAttempts, outcome, ok, test.want)
+		}
+	}
+}
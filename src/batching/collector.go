@@ -0,0 +1,172 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package batching
+
+import (
+	"encoding/json"
+	"fmt"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoft/kiota-abstractions-go/serialization"
+	graphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+)
+
+// StepResult is the response to one step, with its body parsed by the
+// factory the step was added with. Unlike graphcore.GetBatchResponseById,
+// reading a failed step is not an error.
+type StepResult[T serialization.Parsable] struct {
+	Id      string
+	Status  int32
+	Headers map[string]string
+	// Value is the parsed body of a successful step. It is the zero
+	// value if the step failed, returned no body, or has no factory.
+	Value T
+	// ODataError is the parsed body of a failed step
+	ODataError *odataerrors.ODataError
+	// ParseError is set if the body could not be parsed,
+	// or the step has no response
+	ParseError error
+}
+
+// Succeeded reports whether the step returned a success status and,
+// if it had a body, the body was parsed. Value is nil for a step
+// that succeeded without a body, such as a 204.
+func (r StepResult[T]) Succeeded() bool {
+	return r.Err() == nil
+}
+
+// Err returns the step's ODataError, its ParseError, or nil.
+func (r StepResult[T]) Err() error {
+	if r.ODataError != nil {
+		return r.ODataError
+	}
+
+	return r.ParseError
+}
+
+// TypedStep reads the result of a step added with AddTypedStep.
+type TypedStep[T serialization.Parsable] struct {
+	id      string
+	factory serialization.ParsableFactory
+}
+
+// AddTypedStep adds a request to batch, like Batch.AddStep, and registers
+// the factory that parses its response. Read the parsed response from
+// the Result with the returned TypedStep.
+func AddTypedStep[T serialization.Parsable](batch *Batch, id string, request abstractions.RequestInformation,
+	factory serialization.ParsableFactory, dependsOn ...string) (*TypedStep[T], error) {
+	err := batch.AddStep(id, request, dependsOn...)
+	if err != nil {
+		return nil, err
+	}
+	batch.stepsById[id].factory = factory
+
+	return &TypedStep[T]{id: id, factory: factory}, nil
+}
+
+// Id returns the step ID given to AddTypedStep.
+func (s *TypedStep[T]) Id() string {
+	return s.id
+}
+
+// Result returns the step's response from result.
+func (s *TypedStep[T]) Result(result *Result) StepResult[T] {
+	untyped := parseStepResult(s.id, result.GetResponseById(s.id), s.factory)
+
+	typed := StepResult[T]{
+		Id:         untyped.Id,
+		Status:     untyped.Status,
+		Headers:    untyped.Headers,
+		ODataError: untyped.ODataError,
+		ParseError: untyped.ParseError,
+	}
+	if untyped.Value != nil {
+		value, ok := untyped.Value.(T)
+		if !ok {
+			typed.ParseError = fmt.Errorf("batch step %q: factory returned %T, want %T", s.id, untyped.Value, typed.Value)
+		}
+		typed.Value = value
+	}
+
+	return typed
+}
+
+// StepResults returns the response to every step, in the order they were
+// added. Bodies of steps added with AddTypedStep are parsed with their
+// factory, and error bodies of every step are parsed as ODataError.
+func (r *Result) StepResults() []StepResult[serialization.Parsable] {
+	results := make([]StepResult[serialization.Parsable], 0, len(r.steps))
+	for _, step := range r.steps {
+		results = append(results, parseStepResult(step.id, r.GetResponseById(step.id), step.factory))
+	}
+
+	return results
+}
+
+func parseStepResult(id string, response graphcore.BatchItem, factory serialization.ParsableFactory) StepResult[serialization.Parsable] {
+	result := StepResult[serialization.Parsable]{Id: id}
+	if response == nil || response.GetStatus() == nil {
+		result.ParseError = fmt.Errorf("batch step %q has no response", id)
+		return result
+	}
+
+	result.Status = *response.GetStatus()
+	result.Headers = response.GetHeaders()
+
+	if result.Status >= 400 {
+		result.ODataError = parseODataError(response)
+		return result
+	}
+
+	if factory == nil || response.GetBody() == nil {
+		return result
+	}
+
+	value, err := parseBody(response, factory)
+	if err != nil {
+		result.ParseError = fmt.Errorf("parsing batch step %q: %w", id, err)
+	}
+	result.Value = value
+
+	return result
+}
+
+// parseODataError parses the body of a failed step. A body that is not an
+// OData error still produces an ODataError carrying the status code.
+func parseODataError(response graphcore.BatchItem) *odataerrors.ODataError {
+	var odataError *odataerrors.ODataError
+	if response.GetBody() != nil {
+		value, err := parseBody(response, odataerrors.CreateODataErrorFromDiscriminatorValue)
+		if err == nil {
+			odataError, _ = value.(*odataerrors.ODataError)
+		}
+	}
+	if odataError == nil {
+		odataError = odataerrors.NewODataError()
+	}
+
+	odataError.SetStatusCode(int(*response.GetStatus()))
+	responseHeaders := abstractions.NewResponseHeaders()
+	for key, value := range response.GetHeaders() {
+		responseHeaders.Add(key, value)
+	}
+	odataError.SetResponseHeaders(responseHeaders)
+
+	return odataError
+}
+
+func parseBody(response graphcore.BatchItem, factory serialization.ParsableFactory) (serialization.Parsable, error) {
+	content, err := json.Marshal(response.GetBody())
+	if err != nil {
+		return nil, err
+	}
+
+	parseNode, err := serialization.DefaultParseNodeFactoryInstance.GetRootParseNode("application/json", content)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseNode.GetObjectValue(factory)
+}
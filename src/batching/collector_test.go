@@ -0,0 +1,88 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package batching_test
+
+import (
+	"context"
+	"net/http"
+	"sdksnippets/batching"
+	"testing"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+func TestTypedStepResults(t *testing.T) {
+	graphClient, _ := newMockClient(t)
+	ctx := context.Background()
+
+	meRequest, err := graphClient.Me().ToGetRequestInformation(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missingRequest, err := graphClient.Me().Messages().ByMessageId("missing").ToGetRequestInformation(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleteRequest, err := graphClient.Me().Messages().ByMessageId("message-0001").ToDeleteRequestInformation(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := batching.NewBatch(graphClient.GetAdapter())
+	meStep, err := batching.AddTypedStep[models.Userable](
+		batch, "me", *meRequest, models.CreateUserFromDiscriminatorValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missingStep, err := batching.AddTypedStep[models.Messageable](
+		batch, "missing", *missingRequest, models.CreateMessageFromDiscriminatorValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = batch.AddStep("delete", *deleteRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := batch.Send(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	me := meStep.Result(result)
+	if !me.Succeeded() || me.Status != http.StatusOK {
+		t.Fatalf("me: got status %d and error %v", me.Status, me.Err())
+	}
+	if got := *me.Value.GetDisplayName(); got != "Megan Bowen" {
+		t.Errorf("me: got display name %q", got)
+	}
+	if me.Headers["Content-Type"] != "application/json" {
+		t.Errorf("me: got headers %v", me.Headers)
+	}
+
+	missing := missingStep.Result(result)
+	if missing.Succeeded() || missing.Status != http.StatusNotFound {
+		t.Fatalf("missing: got status %d and error %v", missing.Status, missing.Err())
+	}
+	if missing.ODataError == nil || *missing.ODataError.GetErrorEscaped().GetCode() != "ErrorItemNotFound" {
+		t.Errorf("missing: got error %v", missing.Err())
+	}
+	if missing.ODataError.GetStatusCode() != http.StatusNotFound {
+		t.Errorf("missing: got error status %d", missing.ODataError.GetStatusCode())
+	}
+	if missing.Value != nil {
+		t.Errorf("missing: got value %v", missing.Value)
+	}
+
+	results := result.StepResults()
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if results[2].Id != "delete" || results[2].Status != http.StatusNoContent || !results[2].Succeeded() {
+		t.Errorf("delete: got %+v", results[2])
+	}
+	if _, ok := results[0].Value.(models.Userable); !ok {
+		t.Errorf("me: untyped result has value %T", results[0].Value)
+	}
+}
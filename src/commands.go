@@ -0,0 +1,486 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sdksnippets/bandwidth"
+	"sdksnippets/export"
+	"sdksnippets/graphhelper"
+	"sdksnippets/progress"
+	"sdksnippets/snippets"
+	"sdksnippets/transfer"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize   int32 = 10
+	defaultUploadPath       = "Documents/vacation.gif"
+	limitUsage              = "bandwidth cap, such as 2MiB or 09:00-18:00=512KiB,4MiB; empty is unlimited"
+)
+
+// usageError indicates the command line could not be parsed.
+// main exits with status 2 for these, and 1 for every other error.
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+func newUsageError(format string, a ...any) error {
+	return &usageError{message: fmt.Sprintf(format, a...)}
+}
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string, logger *log.Logger) error
+}
+
+var commands = []command{
+	{"run", "Run a group of samples (batch, requests, upload, paging) or a single snippet by name", runSamplesCommand},
+	{"list", "List available snippets, optionally filtered by --group, --scope or --read-only", listCommand},
+	{"export", "Export a collection such as me/messages to a JSONL or CSV file", exportCommand},
+	{"upload-folder", "Upload a local folder and everything in it to OneDrive", uploadFolderCommand},
+	{"login", "Sign in and save the account so later runs sign in silently", loginCommand},
+	{"logout", "Forget the saved account and its cached tokens", logoutCommand},
+	{"whoami", "Show the saved account", whoamiCommand},
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: sdksnippets [command] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "With no command, an interactive menu is shown.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-14s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(w, "  %-14s %s\n", "help", "Show this help")
+}
+
+func runCommand(args []string, logger *log.Logger) error {
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		printUsage(os.Stdout)
+		return nil
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], logger)
+		}
+	}
+
+	return newUsageError("unknown command %q", args[0])
+}
+
+// parseFlags parses args into flags, converting parse failures
+// into usage errors. Positional arguments are not allowed.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	flags.SetOutput(io.Discard)
+	err := flags.Parse(args)
+	if err != nil {
+		return newUsageError("%s: %v", flags.Name(), err)
+	}
+
+	if flags.NArg() > 0 {
+		return newUsageError("%s: unexpected argument %q", flags.Name(), flags.Arg(0))
+	}
+
+	return nil
+}
+
+func runSamplesCommand(args []string, logger *log.Logger) error {
+	if len(args) < 1 {
+		return newUsageError("run: missing sample group")
+	}
+
+	group := args[0]
+	flags := flag.NewFlagSet("run "+group, flag.ContinueOnError)
+
+	switch group {
+	case "batch":
+		if err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+
+		userClient, err := newUserClient(logger)
+		if err != nil {
+			return err
+		}
+		return snippets.RunBatchSamples(userClient)
+	case "requests":
+		if err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+
+		userClient, err := newUserClient(logger)
+		if err != nil {
+			return err
+		}
+		return snippets.RunRequestSamples(userClient)
+	case "upload":
+		file := flags.String("file", os.Getenv("LARGE_FILE_PATH"), "path to the local file to upload, or - for standard input")
+		dest := flags.String("dest", defaultUploadPath, "destination path, relative to the drive root, or the file name with --parent-id")
+		driveId := flags.String("drive-id", "", "ID of the drive to upload to; empty is the signed-in user's OneDrive")
+		site := flags.String("site", "", "ID or hostname:/path: of a SharePoint site to upload to")
+		library := flags.String("library", "", "name of a document library in --site; empty is the site's default library")
+		parentId := flags.String("parent-id", "", "ID of the folder to upload into, instead of a path")
+		conflict := flags.String("conflict", string(transfer.ConflictReplace), "what to do if the destination exists: fail, replace or rename")
+		style := flags.String("progress", string(progress.BarStyle), "how to show progress: bar, json or quiet")
+		limit := flags.String("limit", os.Getenv("BANDWIDTH_LIMIT"), limitUsage)
+		if err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+		if *file == "" {
+			return newUsageError("run upload: --file is required when LARGE_FILE_PATH is not set")
+		}
+		destination, err := snippets.Inputs{
+			"dest":      *dest,
+			"drive-id":  *driveId,
+			"site":      *site,
+			"library":   *library,
+			"parent-id": *parentId,
+			"conflict":  *conflict,
+		}.Destination()
+		if err != nil {
+			return newUsageError("run upload: %v", err)
+		}
+		output, err := snippets.Inputs{"progress": *style}.Progress("progress")
+		if err != nil {
+			return newUsageError("run upload: %v", err)
+		}
+		limiter, err := bandwidth.ParseLimit(*limit)
+		if err != nil {
+			return newUsageError("run upload: %v", err)
+		}
+		if *file != "-" {
+			if _, err := os.Stat(*file); err != nil {
+				return fmt.Errorf("run upload: %w", err)
+			}
+		}
+
+		userClient, err := newUserClient(logger)
+		if err != nil {
+			return err
+		}
+		return snippets.RunUploadSamples(userClient, *file, destination, output, limiter)
+	case "paging":
+		pageSize := flags.Int("page-size", int(defaultPageSize), "number of messages to request per page")
+		if err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+		if *pageSize < 1 || *pageSize > 1000 {
+			return newUsageError("run paging: --page-size must be between 1 and 1000")
+		}
+
+		userClient, err := newUserClient(logger)
+		if err != nil {
+			return err
+		}
+		return snippets.RunPagingSamples(userClient, int32(*pageSize))
+	default:
+		snippet, ok := snippets.Lookup(group)
+		if !ok {
+			return newUsageError("run: unknown sample group or snippet %q", group)
+		}
+		return runSnippet(snippet, args[1:], logger)
+	}
+}
+
+// runSnippet runs a single registered snippet, exposing
+// each of its inputs as a command line flag.
+func runSnippet(snippet snippets.Snippet, args []string, logger *log.Logger) error {
+	flags := flag.NewFlagSet("run "+snippet.Name, flag.ContinueOnError)
+
+	values := map[string]*string{}
+	for _, input := range snippet.Inputs {
+		value := input.Default
+		if env := os.Getenv(input.Env); input.Env != "" && env != "" {
+			value = env
+		}
+		values[input.Name] = flags.String(input.Name, value, input.Description)
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	given := snippets.Inputs{}
+	for name, value := range values {
+		given[name] = *value
+	}
+	inputs, err := snippet.ResolveInputs(given)
+	if err != nil {
+		return newUsageError("run %s: %v", snippet.Name, err)
+	}
+
+	userClient, err := newUserClient(logger)
+	if err != nil {
+		return err
+	}
+
+	err = snippet.Run(userClient, inputs)
+	if err != nil {
+		return fmt.Errorf("%s: %w", snippet.Name, err)
+	}
+
+	return nil
+}
+
+func listCommand(args []string, logger *log.Logger) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	group := flags.String("group", "", "only list snippets in this group")
+	scope := flags.String("scope", "", "only list snippets that require this scope")
+	readOnly := flags.Bool("read-only", false, "only list snippets that do not modify data")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	for _, snippet := range snippets.All() {
+		if *group != "" && snippet.Group != *group {
+			continue
+		}
+		if *scope != "" && !snippet.HasScope(*scope) {
+			continue
+		}
+		if *readOnly && snippet.Mutates {
+			continue
+		}
+
+		access := "read-only"
+		if snippet.Mutates {
+			access = "mutates"
+		}
+		fmt.Printf("%-30s %-9s %-9s %s\n", snippet.Name, snippet.Group, access, snippet.Description)
+		fmt.Printf("%-30s scopes: %s\n", "", strings.Join(snippet.Scopes, ", "))
+		for _, input := range snippet.Inputs {
+			required := ""
+			if input.Required {
+				required = " (required)"
+			}
+			fmt.Printf("%-30s --%s: %s%s\n", "", input.Name, input.Description, required)
+		}
+	}
+
+	return nil
+}
+
+func exportCommand(args []string, logger *log.Logger) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	resource := flags.String("resource", "", "collection path relative to the service root, such as me/messages")
+	selectValue := flags.String("select", "", "comma-separated properties to request")
+	filter := flags.String("filter", "", "$filter expression")
+	pageSize := flags.Int("page-size", 0, "number of items to request per page; 0 uses the server's default")
+	format := flags.String("format", string(export.JSONLines), "output format, jsonl or csv")
+	columnsValue := flags.String("columns", "", "CSV columns as [header=]property/path, separated by commas")
+	output := flags.String("output", "", "file to write, or - for standard output")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *resource == "" {
+		return newUsageError("export: --resource is required")
+	}
+	if *output == "" {
+		return newUsageError("export: --output is required")
+	}
+	if *pageSize < 0 || *pageSize > 1000 {
+		return newUsageError("export: --page-size must be between 1 and 1000, or 0 for the server's default")
+	}
+
+	options := export.Options{
+		Resource: *resource,
+		Filter:   *filter,
+		PageSize: int32(*pageSize),
+		Format:   export.Format(*format),
+	}
+	switch options.Format {
+	case export.JSONLines:
+		if *columnsValue != "" {
+			return newUsageError("export: --columns only applies to csv")
+		}
+	case export.CSV:
+		columns, err := export.ParseColumns(*columnsValue)
+		if err != nil {
+			return newUsageError("export: %v", err)
+		}
+		options.Columns = columns
+	default:
+		return newUsageError("export: unknown format %q", *format)
+	}
+	for _, property := range strings.Split(*selectValue, ",") {
+		if property = strings.TrimSpace(property); property != "" {
+			options.Select = append(options.Select, property)
+		}
+	}
+	if options.Format == export.CSV && len(options.Columns) == 0 && len(options.Select) == 0 {
+		return newUsageError("export: csv needs --columns or --select")
+	}
+
+	userClient, err := newUserClient(logger)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *output != "-" {
+		w, err = os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+		defer w.Close()
+	}
+
+	buffered := bufio.NewWriter(w)
+	count, err := export.Export(context.Background(), userClient, buffered, options)
+	if flushErr := buffered.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if *output != "-" {
+		err = w.Close()
+		if err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+		fmt.Printf("Exported %d items to %s\n", count, *output)
+	}
+
+	return nil
+}
+
+func uploadFolderCommand(args []string, logger *log.Logger) error {
+	flags := flag.NewFlagSet("upload-folder", flag.ContinueOnError)
+	dir := flags.String("dir", "", "local folder to upload")
+	dest := flags.String("dest", "", "destination folder in OneDrive, relative to the root")
+	workers := flags.Int("workers", 4, "number of files to upload at once")
+	style := flags.String("progress", string(progress.BarStyle), "how to show progress: bar, json or quiet")
+	limit := flags.String("limit", os.Getenv("BANDWIDTH_LIMIT"), limitUsage)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	output, err := snippets.Inputs{"progress": *style}.Progress("progress")
+	if err != nil {
+		return newUsageError("upload-folder: %v", err)
+	}
+	limiter, err := bandwidth.ParseLimit(*limit)
+	if err != nil {
+		return newUsageError("upload-folder: %v", err)
+	}
+	if *dir == "" {
+		return newUsageError("upload-folder: --dir is required")
+	}
+	if *workers < 1 {
+		return newUsageError("upload-folder: --workers must be at least 1")
+	}
+	info, err := os.Stat(*dir)
+	if err != nil {
+		return fmt.Errorf("upload-folder: %w", err)
+	}
+	if !info.IsDir() {
+		return newUsageError("upload-folder: %s is not a folder", *dir)
+	}
+
+	userClient, err := newUserClient(logger)
+	if err != nil {
+		return err
+	}
+
+	// Ctrl-C stops the upload and deletes the upload sessions of large files
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	myDrive, err := userClient.Me().Drive().Get(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("upload-folder: getting user's drive: %w", err)
+	}
+
+	reporter := progress.NewReporter(output, progress.Options{})
+	summary, err := transfer.UploadFolder(ctx, userClient, *myDrive.GetId(), *dir, *dest,
+		transfer.FolderUploadOptions{Workers: *workers, Progress: reporter, Limiter: limiter})
+	reporter.Finish()
+	if err != nil {
+		return fmt.Errorf("upload-folder: %w", err)
+	}
+
+	fmt.Printf("Folders created: %d\n", summary.FoldersCreated)
+	fmt.Printf("Files uploaded:  %d (%d bytes)\n", summary.Uploaded, summary.BytesUploaded)
+	fmt.Printf("Files skipped:   %d\n", summary.Skipped)
+	fmt.Printf("Failed:          %d\n", len(summary.Failures))
+	fmt.Printf("Elapsed:         %s\n", summary.Elapsed.Round(time.Millisecond))
+	for _, failure := range summary.Failures {
+		fmt.Printf("  %v\n", failure)
+	}
+	if len(summary.Failures) > 0 {
+		return fmt.Errorf("upload-folder: %d items failed", len(summary.Failures))
+	}
+
+	return nil
+}
+
+func loginCommand(args []string, logger *log.Logger) error {
+	flags := flag.NewFlagSet("login", flag.ContinueOnError)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	record, err := graphhelper.Login(context.Background())
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+
+	fmt.Printf("Signed in as %s\n", record.Username)
+	return nil
+}
+
+func logoutCommand(args []string, logger *log.Logger) error {
+	flags := flag.NewFlagSet("logout", flag.ContinueOnError)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	signedIn, err := graphhelper.Logout()
+	if err != nil {
+		return fmt.Errorf("logout: %w", err)
+	}
+
+	if signedIn {
+		fmt.Println("Signed out")
+	} else {
+		fmt.Println("Not signed in")
+	}
+	return nil
+}
+
+func whoamiCommand(args []string, logger *log.Logger) error {
+	flags := flag.NewFlagSet("whoami", flag.ContinueOnError)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	record, err := graphhelper.LoadAuthenticationRecord()
+	if err != nil {
+		return fmt.Errorf("whoami: %w", err)
+	}
+	if record.HomeAccountID == "" {
+		fmt.Println("Not signed in")
+		return nil
+	}
+
+	fmt.Printf("Username:  %s\n", record.Username)
+	fmt.Printf("Tenant:    %s\n", record.TenantID)
+	fmt.Printf("Client:    %s\n", record.ClientID)
+	fmt.Printf("Authority: %s\n", record.Authority)
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package export
+
+import (
+	"github.com/microsoft/kiota-abstractions-go/serialization"
+)
+
+// collectionResponse is a page of any collection, with each item parsed
+// as an untyped node. It has the GetValue and GetOdataNextLink methods
+// that graphcore.PageIterator needs.
+type collectionResponse struct {
+	value    []serialization.UntypedNodeable
+	nextLink *string
+}
+
+func createCollectionResponseFromDiscriminatorValue(parseNode serialization.ParseNode) (serialization.Parsable, error) {
+	return &collectionResponse{}, nil
+}
+
+func (m *collectionResponse) GetValue() []serialization.UntypedNodeable {
+	return m.value
+}
+
+func (m *collectionResponse) GetOdataNextLink() *string {
+	return m.nextLink
+}
+
+func (m *collectionResponse) GetFieldDeserializers() map[string]func(serialization.ParseNode) error {
+	return map[string]func(serialization.ParseNode) error{
+		"value": func(n serialization.ParseNode) error {
+			values, err := n.GetCollectionOfObjectValues(serialization.CreateUntypedNodeFromDiscriminatorValue)
+			if err != nil {
+				return err
+			}
+
+			m.value = make([]serialization.UntypedNodeable, 0, len(values))
+			for _, value := range values {
+				if item, ok := value.(serialization.UntypedNodeable); ok {
+					m.value = append(m.value, item)
+				}
+			}
+			return nil
+		},
+		"@odata.nextLink": func(n serialization.ParseNode) error {
+			value, err := n.GetStringValue()
+			m.nextLink = value
+			return err
+		},
+	}
+}
+
+// Serialize writes the items back as a value collection.
+func (m *collectionResponse) Serialize(writer serialization.SerializationWriter) error {
+	values := make([]serialization.Parsable, len(m.value))
+	for i, value := range m.value {
+		values[i] = value
+	}
+
+	return writer.WriteCollectionOfObjectValues("value", values)
+}
@@ -0,0 +1,289 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+// Package export writes the items of any Microsoft Graph collection to
+// JSON Lines or CSV, one page at a time.
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sdksnippets/paging"
+	"strconv"
+	"strings"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoft/kiota-abstractions-go/serialization"
+)
+
+// Format is an output file format.
+type Format string
+
+const (
+	// JSONLines writes each item as JSON on its own line
+	JSONLines Format = "jsonl"
+	// CSV writes a header row and one row per item
+	CSV Format = "csv"
+)
+
+// Column maps a property of each item to a CSV column.
+type Column struct {
+	Header string
+	// Path is a slash-separated property path,
+	// such as sender/emailAddress/address
+	Path string
+}
+
+// ParseColumns parses a comma-separated list of columns. Each column is a
+// property path, optionally preceded by a header and "=", as in
+// "subject,from=sender/emailAddress/address". Without a header, the path
+// is used as the header.
+func ParseColumns(value string) ([]Column, error) {
+	var columns []Column
+	for _, spec := range strings.Split(value, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		header, path, found := strings.Cut(spec, "=")
+		if !found {
+			path = header
+		}
+		header, path = strings.TrimSpace(header), strings.Trim(strings.TrimSpace(path), "/")
+		if header == "" || path == "" {
+			return nil, fmt.Errorf("invalid column %q", spec)
+		}
+		columns = append(columns, Column{Header: header, Path: path})
+	}
+
+	return columns, nil
+}
+
+// Options describes what to export.
+type Options struct {
+	// Resource is the collection path relative to the service root,
+	// such as me/messages or groups
+	Resource string
+	Select   []string
+	Filter   string
+	// PageSize sets $top. Zero leaves the page size to the service.
+	PageSize int32
+	Format   Format
+	// Columns are the CSV columns. If empty, there is one column for
+	// each property in Select.
+	Columns []Column
+}
+
+// Export requests the collection described by options and writes every
+// item to w as it arrives, so only one page is held in memory. It returns
+// the number of items written.
+//
+// If Select is empty and Columns is not, only the top-level properties
+// the columns read are requested.
+func Export(ctx context.Context, client paging.Client, w io.Writer, options Options) (int, error) {
+	columns := options.Columns
+	selectProperties := options.Select
+	switch options.Format {
+	case JSONLines:
+	case CSV:
+		if len(columns) == 0 {
+			for _, property := range selectProperties {
+				columns = append(columns, Column{Header: property, Path: property})
+			}
+		}
+		if len(columns) == 0 {
+			return 0, errors.New("CSV export needs columns or $select")
+		}
+		if len(selectProperties) == 0 {
+			selectProperties = topLevelProperties(columns)
+		}
+	default:
+		return 0, fmt.Errorf("unsupported format %q", options.Format)
+	}
+
+	request, err := newRequest(client, options.Resource, selectProperties, options.Filter, options.PageSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var write func(item serialization.UntypedNodeable) error
+	var flush func() error
+	if options.Format == CSV {
+		csvWriter := csv.NewWriter(w)
+		headers := make([]string, len(columns))
+		for i, column := range columns {
+			headers[i] = column.Header
+		}
+		err = csvWriter.Write(headers)
+		if err != nil {
+			return 0, err
+		}
+
+		write = func(item serialization.UntypedNodeable) error {
+			return writeRow(csvWriter, item, columns)
+		}
+		flush = func() error {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+	} else {
+		write = func(item serialization.UntypedNodeable) error {
+			return writeLine(w, item)
+		}
+		flush = func() error { return nil }
+	}
+
+	count := 0
+	for item, err := range paging.FromRequest[serialization.UntypedNodeable](ctx, client, request,
+		createCollectionResponseFromDiscriminatorValue) {
+		if err != nil {
+			return count, fmt.Errorf("exporting %s: %w", options.Resource, err)
+		}
+
+		err = write(item)
+		if err != nil {
+			return count, fmt.Errorf("writing item %d: %w", count+1, err)
+		}
+		count++
+	}
+
+	return count, flush()
+}
+
+// newRequest creates a GET for resource with the given query options
+func newRequest(client paging.Client, resource string, selectProperties []string,
+	filter string, pageSize int32) (*abstractions.RequestInformation, error) {
+	resource = strings.Trim(resource, "/")
+	if resource == "" {
+		return nil, errors.New("resource path is empty")
+	}
+
+	uri, err := url.Parse(client.GetAdapter().GetBaseUrl() + "/" + resource)
+	if err != nil {
+		return nil, fmt.Errorf("parsing resource path %q: %w", resource, err)
+	}
+	query := uri.Query()
+	if len(selectProperties) > 0 {
+		query.Set("$select", strings.Join(selectProperties, ","))
+	}
+	if filter != "" {
+		query.Set("$filter", filter)
+	}
+	if pageSize > 0 {
+		query.Set("$top", strconv.Itoa(int(pageSize)))
+	}
+	uri.RawQuery = query.Encode()
+
+	request := abstractions.NewRequestInformation()
+	request.Method = abstractions.GET
+	request.SetUri(*uri)
+	request.Headers.TryAdd("Accept", "application/json")
+
+	return request, nil
+}
+
+// topLevelProperties returns the first segment of each column path
+func topLevelProperties(columns []Column) []string {
+	var properties []string
+	seen := map[string]bool{}
+	for _, column := range columns {
+		property, _, _ := strings.Cut(column.Path, "/")
+		if !seen[property] {
+			seen[property] = true
+			properties = append(properties, property)
+		}
+	}
+
+	return properties
+}
+
+// writeLine writes item to w as one line of JSON
+func writeLine(w io.Writer, item serialization.UntypedNodeable) error {
+	content, err := serializeJson(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(content, '\n'))
+	return err
+}
+
+func serializeJson(item serialization.UntypedNodeable) ([]byte, error) {
+	writer, err := serialization.DefaultSerializationWriterFactoryInstance.GetSerializationWriter("application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer writer.Close()
+
+	err = writer.WriteObjectValue("", item)
+	if err != nil {
+		return nil, err
+	}
+
+	return writer.GetSerializedContent()
+}
+
+func writeRow(csvWriter *csv.Writer, item serialization.UntypedNodeable, columns []Column) error {
+	row := make([]string, len(columns))
+	for i, column := range columns {
+		value, err := formatValue(propertyValue(item, column.Path))
+		if err != nil {
+			return fmt.Errorf("column %s: %w", column.Header, err)
+		}
+		row[i] = value
+	}
+
+	return csvWriter.Write(row)
+}
+
+// propertyValue resolves a slash-separated property path,
+// returning nil if any segment is missing
+func propertyValue(item serialization.UntypedNodeable, path string) serialization.UntypedNodeable {
+	value := item
+	for _, segment := range strings.Split(path, "/") {
+		object, ok := value.(*serialization.UntypedObject)
+		if !ok {
+			return nil
+		}
+		value = object.GetValue()[segment]
+	}
+
+	return value
+}
+
+// formatValue formats a property for a CSV cell. Objects and arrays
+// are written as JSON, and missing or null values as empty cells.
+func formatValue(value serialization.UntypedNodeable) (string, error) {
+	switch value := value.(type) {
+	case nil, *serialization.UntypedNull:
+		return "", nil
+	case *serialization.UntypedString:
+		return derefString(value.GetValue()), nil
+	case *serialization.UntypedBoolean:
+		return strconv.FormatBool(*value.GetValue()), nil
+	case *serialization.UntypedInteger:
+		return strconv.FormatInt(int64(*value.GetValue()), 10), nil
+	case *serialization.UntypedLong:
+		return strconv.FormatInt(*value.GetValue(), 10), nil
+	case *serialization.UntypedFloat:
+		return strconv.FormatFloat(float64(*value.GetValue()), 'g', -1, 32), nil
+	case *serialization.UntypedDouble:
+		return strconv.FormatFloat(*value.GetValue(), 'g', -1, 64), nil
+	}
+
+	content, err := serializeJson(value)
+	return string(content), err
+}
+
+func derefString(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
@@ -0,0 +1,127 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package export_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"sdksnippets/export"
+	"sdksnippets/graphmock"
+	"strings"
+	"testing"
+)
+
+func TestExportJSONLines(t *testing.T) {
+	graphClient, _ := graphmock.NewTestClient(t)
+
+	var output bytes.Buffer
+	count, err := export.Export(context.Background(), graphClient, &output, export.Options{
+		Resource: "me/messages",
+		Select:   []string{"subject", "sender"},
+		PageSize: 7,
+		Format:   export.JSONLines,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if count != 30 || len(lines) != 30 {
+		t.Fatalf("got count %d and %d lines, want 30", count, len(lines))
+	}
+
+	var message struct {
+		Id      string
+		Subject string
+		Sender  struct {
+			EmailAddress struct {
+				Address string
+			}
+		}
+	}
+	err = json.Unmarshal([]byte(lines[0]), &message)
+	if err != nil {
+		t.Fatalf("line 1 is not JSON: %v", err)
+	}
+	if message.Subject != "Message 1" || message.Sender.EmailAddress.Address != "alex@contoso.com" {
+		t.Errorf("got line %s", lines[0])
+	}
+}
+
+func TestExportCSVWithNestedColumns(t *testing.T) {
+	graphClient, _ := graphmock.NewTestClient(t)
+
+	columns, err := export.ParseColumns("subject, from=sender/emailAddress/address, missing/property")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	count, err := export.Export(context.Background(), graphClient, &output, export.Options{
+		Resource: "/me/messages",
+		Filter:   "subject eq 'Hello world'",
+		Format:   export.CSV,
+		Columns:  columns,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&output).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"subject", "from", "missing/property"},
+		{"Hello world", "alex@contoso.com", ""},
+	}
+	if count != 1 || !reflect.DeepEqual(rows, want) {
+		t.Errorf("got count %d and rows %q, want %q", count, rows, want)
+	}
+}
+
+func TestExportCSVWritesObjectsAsJSON(t *testing.T) {
+	graphClient, _ := graphmock.NewTestClient(t)
+
+	var output bytes.Buffer
+	_, err := export.Export(context.Background(), graphClient, &output, export.Options{
+		Resource: "groups",
+		Select:   []string{"displayName", "groupTypes", "mailEnabled"},
+		Format:   export.CSV,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&output).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Mark 8 Project Team", `["Unified"]`, "true"}
+	if len(rows) != 3 || !reflect.DeepEqual(rows[1], want) {
+		t.Errorf("got rows %q, want second row %q", rows, want)
+	}
+}
+
+func TestExportRejectsCSVWithoutColumns(t *testing.T) {
+	graphClient, _ := graphmock.NewTestClient(t)
+
+	_, err := export.Export(context.Background(), graphClient, &bytes.Buffer{}, export.Options{
+		Resource: "me/messages",
+		Format:   export.CSV,
+	})
+	if err == nil {
+		t.Error("got no error for a CSV export without columns")
+	}
+}
+
+func TestParseColumnsRejectsEmptyPath(t *testing.T) {
+	_, err := export.ParseColumns("subject,from=")
+	if err == nil {
+		t.Error("got no error for a column without a path")
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package graphhelper
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// AuthMode selects the credential NewUserGraphServiceClient signs in with
+type AuthMode string
+
+const (
+	DeviceCodeAuth       AuthMode = "devicecode"
+	InteractiveAuth      AuthMode = "interactive"
+	ClientSecretAuth     AuthMode = "clientsecret"
+	CertificateAuth      AuthMode = "certificate"
+	OnBehalfOfAuth       AuthMode = "obo"
+	ManagedIdentityAuth  AuthMode = "managedidentity"
+	WorkloadIdentityAuth AuthMode = "workloadidentity"
+	AzureCliAuth         AuthMode = "azurecli"
+)
+
+// defaultGraphScope requests the permissions granted to the app
+// registration, and is the only scope app-only credentials accept
+const defaultGraphScope = "https://graph.microsoft.com/.default"
+
+// AuthModes lists every supported AuthMode
+var AuthModes = []AuthMode{
+	DeviceCodeAuth,
+	InteractiveAuth,
+	ClientSecretAuth,
+	CertificateAuth,
+	OnBehalfOfAuth,
+	ManagedIdentityAuth,
+	WorkloadIdentityAuth,
+	AzureCliAuth,
+}
+
+// AuthModeFromEnvironment reads AUTH_MODE, defaulting to devicecode.
+func AuthModeFromEnvironment() (AuthMode, error) {
+	mode := AuthMode(strings.ToLower(strings.TrimSpace(os.Getenv("AUTH_MODE"))))
+	if mode == "" {
+		return DeviceCodeAuth, nil
+	}
+
+	for _, supported := range AuthModes {
+		if mode == supported {
+			return mode, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported AUTH_MODE %q", mode)
+}
+
+// IsDelegated reports whether the mode acts on behalf of a signed-in
+// user, so that requests to /me are valid.
+func (mode AuthMode) IsDelegated() bool {
+	switch mode {
+	case DeviceCodeAuth, InteractiveAuth, OnBehalfOfAuth, AzureCliAuth:
+		return true
+	}
+
+	return false
+}
+
+// NewCredentialFromEnvironment creates the credential selected by
+// AUTH_MODE and returns it with the scopes to request.
+//
+//   - devicecode: CLIENT_ID, TENANT_ID
+//   - interactive: CLIENT_ID, TENANT_ID, optional REDIRECT_URL
+//   - clientsecret: CLIENT_ID, TENANT_ID, CLIENT_SECRET
+//   - certificate: CLIENT_ID, TENANT_ID, CLIENT_CERTIFICATE_PATH,
+//     optional CLIENT_CERTIFICATE_PASSWORD
+//   - obo: CLIENT_ID, TENANT_ID, USER_ASSERTION, and either CLIENT_SECRET
+//     or CLIENT_CERTIFICATE_PATH
+//   - managedidentity: optional MANAGED_IDENTITY_CLIENT_ID for a
+//     user-assigned identity
+//   - workloadidentity: AZURE_FEDERATED_TOKEN_FILE, with CLIENT_ID and
+//     TENANT_ID overriding AZURE_CLIENT_ID and AZURE_TENANT_ID if set
+//   - azurecli: the account signed in with az login
+func NewCredentialFromEnvironment() (azcore.TokenCredential, []string, error) {
+	mode, err := AuthModeFromEnvironment()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var record azidentity.AuthenticationRecord
+	if mode.cachesSignIn() {
+		record, err = LoadAuthenticationRecord()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	credential, err := newCredential(mode, record)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating %s credential: %w", mode, err)
+	}
+
+	return credential, scopesFor(mode), nil
+}
+
+func scopesFor(mode AuthMode) []string {
+	// The Azure CLI only issues tokens for a resource's .default scope
+	if mode.IsDelegated() && mode != AzureCliAuth {
+		return splitList(os.Getenv("GRAPH_USER_SCOPES"))
+	}
+
+	return []string{defaultGraphScope}
+}
+
+// newCredential creates the credential for mode. The device code and
+// interactive credentials use the persistent token cache, and sign in
+// silently as record's account if its tokens are cached.
+func newCredential(mode AuthMode, record azidentity.AuthenticationRecord) (azcore.TokenCredential, error) {
+	clientId := os.Getenv("CLIENT_ID")
+	tenantId := os.Getenv("TENANT_ID")
+
+	switch mode {
+	case DeviceCodeAuth:
+		return azidentity.NewDeviceCodeCredential(&azidentity.DeviceCodeCredentialOptions{
+			ClientID:             clientId,
+			TenantID:             tenantId,
+			Cache:                newPersistentCache(),
+			AuthenticationRecord: record,
+			UserPrompt: func(ctx context.Context, message azidentity.DeviceCodeMessage) error {
+				fmt.Fprintln(os.Stderr, message.Message)
+				return nil
+			},
+		})
+	case InteractiveAuth:
+		return azidentity.NewInteractiveBrowserCredential(&azidentity.InteractiveBrowserCredentialOptions{
+			ClientID:             clientId,
+			TenantID:             tenantId,
+			RedirectURL:          os.Getenv("REDIRECT_URL"),
+			Cache:                newPersistentCache(),
+			AuthenticationRecord: record,
+		})
+	case ClientSecretAuth:
+		secret, err := requireEnv(mode, "CLIENT_SECRET")
+		if err != nil {
+			return nil, err
+		}
+		return azidentity.NewClientSecretCredential(tenantId, clientId, secret, nil)
+	case CertificateAuth:
+		certificatePath, err := requireEnv(mode, "CLIENT_CERTIFICATE_PATH")
+		if err != nil {
+			return nil, err
+		}
+		certs, key, err := loadCertificate(certificatePath)
+		if err != nil {
+			return nil, err
+		}
+		return azidentity.NewClientCertificateCredential(tenantId, clientId, certs, key, nil)
+	case OnBehalfOfAuth:
+		assertion, err := requireEnv(mode, "USER_ASSERTION")
+		if err != nil {
+			return nil, err
+		}
+		if certificatePath := os.Getenv("CLIENT_CERTIFICATE_PATH"); certificatePath != "" {
+			certs, key, err := loadCertificate(certificatePath)
+			if err != nil {
+				return nil, err
+			}
+			return azidentity.NewOnBehalfOfCredentialWithCertificate(
+				tenantId, clientId, assertion, certs, key, nil)
+		}
+		secret, err := requireEnv(mode, "CLIENT_SECRET")
+		if err != nil {
+			return nil, err
+		}
+		return azidentity.NewOnBehalfOfCredentialWithSecret(tenantId, clientId, assertion, secret, nil)
+	case ManagedIdentityAuth:
+		options := &azidentity.ManagedIdentityCredentialOptions{}
+		if id := os.Getenv("MANAGED_IDENTITY_CLIENT_ID"); id != "" {
+			options.ID = azidentity.ClientID(id)
+		}
+		return azidentity.NewManagedIdentityCredential(options)
+	case WorkloadIdentityAuth:
+		// Unset values fall back to the AZURE_* variables
+		// injected by the workload identity webhook
+		return azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+			ClientID: clientId,
+			TenantID: tenantId,
+		})
+	case AzureCliAuth:
+		return azidentity.NewAzureCLICredential(nil)
+	}
+
+	return nil, fmt.Errorf("unsupported AUTH_MODE %q", mode)
+}
+
+func requireEnv(mode AuthMode, name string) (string, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return "", fmt.Errorf("AUTH_MODE %s requires %s", mode, name)
+	}
+
+	return value, nil
+}
+
+// loadCertificate reads a PEM or PKCS#12 certificate and its private key,
+// decrypting it with CLIENT_CERTIFICATE_PASSWORD if set
+func loadCertificate(certificatePath string) ([]*x509.Certificate, crypto.PrivateKey, error) {
+	data, err := os.ReadFile(certificatePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading certificate: %w", err)
+	}
+
+	var password []byte
+	if value := os.Getenv("CLIENT_CERTIFICATE_PASSWORD"); value != "" {
+		password = []byte(value)
+	}
+
+	certs, key, err := azidentity.ParseCertificates(data, password)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing certificate: %w", err)
+	}
+
+	return certs, key, nil
+}
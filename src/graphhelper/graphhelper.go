@@ -5,37 +5,50 @@ package graphhelper
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"os"
 	"strconv"
 	"strings"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	graphdebug "github.com/jasonjoh/msgraph-sdk-go-debug-logger"
+	"github.com/microsoft/kiota-abstractions-go/authentication"
 	khttp "github.com/microsoft/kiota-http-go"
 	graph "github.com/microsoftgraph/msgraph-sdk-go"
 	graphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
 	auth "github.com/microsoftgraph/msgraph-sdk-go-core/authentication"
 )
 
+// defaultGraphBaseUrl is the service root used when replaying a cassette
+const defaultGraphBaseUrl = "https://graph.microsoft.com/v1.0"
+
+// NewUserGraphServiceClient creates a client that signs in with the
+// credential selected by AUTH_MODE. The device code and interactive
+// credentials prompt only if no saved account has cached tokens.
 func NewUserGraphServiceClient(logger *log.Logger) (*graph.GraphServiceClient, error) {
-	clientId := os.Getenv("CLIENT_ID")
-	tenantId := os.Getenv("TENANT_ID")
-	scopes := strings.Split(os.Getenv("GRAPH_USER_SCOPES"), ",")
 	debug, err := strconv.ParseBool(os.Getenv("ENABLE_GRAPH_LOG"))
 	if err != nil {
 		debug = false
 	}
 
-	credential, err := azidentity.NewDeviceCodeCredential(&azidentity.DeviceCodeCredentialOptions{
-		ClientID: clientId,
-		TenantID: tenantId,
-		UserPrompt: func(ctx context.Context, message azidentity.DeviceCodeMessage) error {
-			fmt.Println(message.Message)
-			return nil
-		},
-	})
+	var additionalMiddleware []khttp.Middleware
+	recorder, err := NewRecorderMiddlewareFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	if recorder != nil {
+		// Replaying needs neither credentials nor network
+		if recorder.Mode() == ReplayMode {
+			return NewOfflineGraphServiceClient(defaultGraphBaseUrl, recorder)
+		}
+		additionalMiddleware = append(additionalMiddleware, recorder)
+	}
+
+	credential, scopes, err := NewCredentialFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	err = signInIfNeeded(context.Background(), credential, scopes)
 	if err != nil {
 		return nil, err
 	}
@@ -46,7 +59,15 @@ func NewUserGraphServiceClient(logger *log.Logger) (*graph.GraphServiceClient, e
 	}
 
 	if debug {
-		return NewDebugGraphServiceClient(authProvider, logger)
+		return NewDebugGraphServiceClient(authProvider, logger, additionalMiddleware...)
+	} else if len(additionalMiddleware) > 0 {
+		adapter, err := newGraphRequestAdapterWithMiddleware(authProvider, additionalMiddleware...)
+		if err != nil {
+			return nil, err
+		}
+
+		client := graph.NewGraphServiceClient(adapter)
+		return client, nil
 	} else {
 		adapter, err := graph.NewGraphRequestAdapter(authProvider)
 		if err != nil {
@@ -58,7 +79,36 @@ func NewUserGraphServiceClient(logger *log.Logger) (*graph.GraphServiceClient, e
 	}
 }
 
-func NewDebugGraphServiceClient(authProvider *auth.AzureIdentityAuthenticationProvider, logger *log.Logger) (*graph.GraphServiceClient, error) {
+// NewRecorderMiddlewareFromEnvironment creates a RecorderMiddleware from
+// GRAPH_RECORDER_MODE, GRAPH_CASSETTE_PATH, GRAPH_RECORDER_SCRUB_FIELDS
+// and GRAPH_RECORDER_IGNORE_FIELDS. It returns nil if no mode is set.
+func NewRecorderMiddlewareFromEnvironment() (*RecorderMiddleware, error) {
+	mode := os.Getenv("GRAPH_RECORDER_MODE")
+	if mode == "" || mode == "off" {
+		return nil, nil
+	}
+
+	return NewRecorderMiddleware(RecorderOptions{
+		Mode:         RecorderMode(mode),
+		CassettePath: os.Getenv("GRAPH_CASSETTE_PATH"),
+		ScrubFields:  splitList(os.Getenv("GRAPH_RECORDER_SCRUB_FIELDS")),
+		IgnoreFields: splitList(os.Getenv("GRAPH_RECORDER_IGNORE_FIELDS")),
+	})
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+func NewDebugGraphServiceClient(authProvider *auth.AzureIdentityAuthenticationProvider, logger *log.Logger, additionalMiddleware ...khttp.Middleware) (*graph.GraphServiceClient, error) {
 	showTokens, err := strconv.ParseBool(os.Getenv("GRAPH_LOG_TOKENS"))
 	if err != nil {
 		showTokens = false
@@ -68,17 +118,39 @@ func NewDebugGraphServiceClient(authProvider *auth.AzureIdentityAuthenticationPr
 		showPayloads = false
 	}
 
+	debugMiddleware := graphdebug.NewGraphDebugLogMiddleware(logger, showTokens, showPayloads)
+	adapter, err := newGraphRequestAdapterWithMiddleware(authProvider,
+		append([]khttp.Middleware{debugMiddleware}, additionalMiddleware...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	client := graph.NewGraphServiceClient(adapter)
+	return client, nil
+}
+
+// newGraphRequestAdapterWithMiddleware creates an adapter whose pipeline is
+// the default Graph middleware followed by additionalMiddleware.
+func newGraphRequestAdapterWithMiddleware(authProvider authentication.AuthenticationProvider, additionalMiddleware ...khttp.Middleware) (*graph.GraphRequestAdapter, error) {
 	clientOptions := graph.GetDefaultClientOptions()
 	middleware := graphcore.GetDefaultMiddlewaresWithOptions(&clientOptions)
-	debugMiddleware := graphdebug.NewGraphDebugLogMiddleware(logger, showTokens, showPayloads)
-	allMiddleware := append(middleware, debugMiddleware)
+	allMiddleware := append(middleware, additionalMiddleware...)
 	httpClient := khttp.GetDefaultClient(allMiddleware...)
 
-	adapter, err := graph.NewGraphRequestAdapterWithParseNodeFactoryAndSerializationWriterFactoryAndHttpClient(
+	return graph.NewGraphRequestAdapterWithParseNodeFactoryAndSerializationWriterFactoryAndHttpClient(
 		authProvider, nil, nil, httpClient)
+}
+
+// NewOfflineGraphServiceClient creates a client that sends unauthenticated
+// requests to baseUrl, such as the service root of a graphmock.Server.
+// Any additional middleware runs after the default Graph middleware.
+func NewOfflineGraphServiceClient(baseUrl string, additionalMiddleware ...khttp.Middleware) (*graph.GraphServiceClient, error) {
+	adapter, err := newGraphRequestAdapterWithMiddleware(
+		&authentication.AnonymousAuthenticationProvider{}, additionalMiddleware...)
 	if err != nil {
 		return nil, err
 	}
+	adapter.SetBaseUrl(baseUrl)
 
 	client := graph.NewGraphServiceClient(adapter)
 	return client, nil
@@ -0,0 +1,546 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package graphhelper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	khttp "github.com/microsoft/kiota-http-go"
+)
+
+type RecorderMode string
+
+const (
+	// RecordMode sends requests to Graph and saves each
+	// request/response pair to the cassette
+	RecordMode RecorderMode = "record"
+	// ReplayMode answers requests from the cassette without
+	// touching the network
+	ReplayMode RecorderMode = "replay"
+)
+
+const redactedValue = "REDACTED"
+
+var (
+	// Headers that are never written to a cassette
+	scrubbedHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+	// Query parameters and JSON properties that carry credentials
+	scrubbedTokenFields = []string{
+		"access_token", "refresh_token", "id_token",
+		"client_secret", "client_assertion", "tempauth", "sig",
+	}
+)
+
+// RecorderOptions configures a RecorderMiddleware.
+type RecorderOptions struct {
+	Mode RecorderMode
+	// CassettePath is the JSON file interactions are saved to and read from
+	CassettePath string
+	// ScrubFields lists additional JSON properties and query parameters
+	// (for example mail or userPrincipalName) to redact from the cassette
+	ScrubFields []string
+	// IgnoreFields lists JSON properties and query parameters that are
+	// not compared when matching requests, such as values derived from
+	// the current date
+	IgnoreFields []string
+}
+
+type cassette struct {
+	Interactions []*interaction `json:"interactions"`
+}
+
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+	used     bool
+}
+
+type recordedRequest struct {
+	Method  string              `json:"method"`
+	Url     string              `json:"url"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	// Body holds JSON bodies as text. Other bodies are stored
+	// only as a sha256: digest, which is enough for matching.
+	Body string `json:"body,omitempty"`
+}
+
+type recordedResponse struct {
+	StatusCode int                 `json:"statusCode"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       string              `json:"body,omitempty"`
+	// BodyEncoding is "base64" for binary bodies
+	BodyEncoding string `json:"bodyEncoding,omitempty"`
+}
+
+// RecorderMiddleware is a kiota middleware that records Graph traffic to
+// a cassette file, or replays a cassette instead of sending requests.
+// It should be the last middleware in the pipeline, so it sees requests
+// exactly as they would be sent.
+type RecorderMiddleware struct {
+	options  RecorderOptions
+	scrub    map[string]bool
+	ignore   map[string]bool
+	mu       sync.Mutex
+	cassette cassette
+}
+
+// NewRecorderMiddleware creates a recorder. In replay mode the cassette
+// must already exist. In record mode any existing cassette is replaced.
+func NewRecorderMiddleware(options RecorderOptions) (*RecorderMiddleware, error) {
+	if options.CassettePath == "" {
+		return nil, errors.New("recorder: cassette path is required")
+	}
+
+	recorder := &RecorderMiddleware{
+		options: options,
+		scrub:   fieldSet(scrubbedTokenFields, options.ScrubFields),
+		ignore:  fieldSet(options.IgnoreFields),
+	}
+
+	switch options.Mode {
+	case RecordMode:
+		// Start with an empty cassette
+	case ReplayMode:
+		data, err := os.ReadFile(options.CassettePath)
+		if err != nil {
+			return nil, fmt.Errorf("recorder: reading cassette: %w", err)
+		}
+		err = json.Unmarshal(data, &recorder.cassette)
+		if err != nil {
+			return nil, fmt.Errorf("recorder: parsing cassette: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("recorder: unknown mode %q", options.Mode)
+	}
+
+	return recorder, nil
+}
+
+// Mode returns the mode the recorder was created with.
+func (m *RecorderMiddleware) Mode() RecorderMode {
+	return m.options.Mode
+}
+
+func fieldSet(lists ...[]string) map[string]bool {
+	set := map[string]bool{}
+	for _, list := range lists {
+		for _, field := range list {
+			set[strings.ToLower(field)] = true
+		}
+	}
+	return set
+}
+
+// Intercept implements khttp.Middleware.
+func (m *RecorderMiddleware) Intercept(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request) (*http.Response, error) {
+	requestBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("recorder: reading request body: %w", err)
+	}
+
+	recorded := m.recordRequest(req, requestBody)
+
+	if m.options.Mode == ReplayMode {
+		return m.replay(req, recorded)
+	}
+
+	resp, err := pipeline.Next(req, middlewareIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("recorder: reading response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	err = m.save(&interaction{
+		Request:  recorded,
+		Response: m.recordResponse(resp, responseBody),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// readRequestBody reads the body and restores it for the next
+// middleware, returning the uncompressed content.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	// The compression handler runs earlier in the pipeline
+	if strings.EqualFold(req.Header.Get("Content-Encoding"), "gzip") {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(reader)
+	}
+
+	return body, nil
+}
+
+func (m *RecorderMiddleware) recordRequest(req *http.Request, body []byte) recordedRequest {
+	recorded := recordedRequest{
+		Method:  req.Method,
+		Url:     m.scrubUrl(req.URL.String()),
+		Headers: m.scrubHeaders(req.Header),
+	}
+
+	if len(body) > 0 {
+		if json.Valid(body) {
+			recorded.Body = string(m.scrubJson(body))
+		} else {
+			digest := sha256.Sum256(body)
+			recorded.Body = "sha256:" + hex.EncodeToString(digest[:])
+		}
+	}
+
+	return recorded
+}
+
+func (m *RecorderMiddleware) recordResponse(resp *http.Response, body []byte) recordedResponse {
+	recorded := recordedResponse{
+		StatusCode: resp.StatusCode,
+		Headers:    m.scrubHeaders(resp.Header),
+	}
+	// Location headers hold URLs with the same tokens as bodies
+	if location := resp.Header.Get("Location"); location != "" {
+		recorded.Headers["Location"] = []string{m.scrubUrl(location)}
+	}
+
+	switch {
+	case len(body) == 0:
+	case json.Valid(body):
+		recorded.Body = string(m.scrubJson(body))
+	case utf8.Valid(body):
+		recorded.Body = string(body)
+	default:
+		recorded.Body = base64.StdEncoding.EncodeToString(body)
+		recorded.BodyEncoding = "base64"
+	}
+
+	return recorded
+}
+
+func (m *RecorderMiddleware) scrubHeaders(headers http.Header) map[string][]string {
+	scrubbed := map[string][]string{}
+	for key, values := range headers {
+		skip := false
+		for _, header := range scrubbedHeaders {
+			if strings.EqualFold(key, header) {
+				skip = true
+			}
+		}
+		if !skip {
+			scrubbed[key] = values
+		}
+	}
+
+	return scrubbed
+}
+
+// scrubUrl redacts sensitive query parameters.
+func (m *RecorderMiddleware) scrubUrl(rawUrl string) string {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || parsed.RawQuery == "" {
+		return rawUrl
+	}
+
+	query := parsed.Query()
+	for key := range query {
+		if m.scrub[strings.ToLower(key)] {
+			query.Set(key, redactedValue)
+		}
+	}
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
+
+// scrubJson redacts sensitive properties at any depth, as well as
+// tokens embedded in URL-valued strings such as uploadUrl.
+func (m *RecorderMiddleware) scrubJson(body []byte) []byte {
+	var value any
+	if json.Unmarshal(body, &value) != nil {
+		return body
+	}
+
+	var walk func(value any) any
+	walk = func(value any) any {
+		switch v := value.(type) {
+		case map[string]any:
+			for key, child := range v {
+				if m.scrub[strings.ToLower(key)] {
+					v[key] = redactedValue
+				} else {
+					v[key] = walk(child)
+				}
+			}
+		case []any:
+			for i, child := range v {
+				v[i] = walk(child)
+			}
+		case string:
+			if strings.HasPrefix(v, "http://") || strings.HasPrefix(v, "https://") {
+				return m.scrubUrl(v)
+			}
+		}
+		return value
+	}
+
+	scrubbed, err := json.Marshal(walk(value))
+	if err != nil {
+		return body
+	}
+
+	return scrubbed
+}
+
+func (m *RecorderMiddleware) save(recorded *interaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cassette.Interactions = append(m.cassette.Interactions, recorded)
+
+	data, err := json.MarshalIndent(m.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("recorder: serializing cassette: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(m.options.CassettePath), 0700)
+	if err != nil {
+		return fmt.Errorf("recorder: creating cassette directory: %w", err)
+	}
+
+	// Scrubbing is best effort, so keep cassettes private
+	err = os.WriteFile(m.options.CassettePath, data, 0600)
+	if err != nil {
+		return fmt.Errorf("recorder: writing cassette: %w", err)
+	}
+
+	return nil
+}
+
+func (m *RecorderMiddleware) replay(req *http.Request, live recordedRequest) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	liveKey, liveBatchIds := m.matchKey(live)
+
+	// Prefer the first unused match so repeated identical requests
+	// replay in the order they were recorded
+	var match *interaction
+	var recordedBatchIds []string
+	for _, candidate := range m.cassette.Interactions {
+		key, batchIds := m.matchKey(candidate.Request)
+		if key != liveKey {
+			continue
+		}
+		if match == nil || (match.used && !candidate.used) {
+			match = candidate
+			recordedBatchIds = batchIds
+		}
+		if !candidate.used {
+			break
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("recorder: no recorded interaction for %s %s", live.Method, live.Url)
+	}
+	match.used = true
+
+	body := []byte(match.Response.Body)
+	if match.Response.BodyEncoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(match.Response.Body)
+		if err != nil {
+			return nil, fmt.Errorf("recorder: decoding response body: %w", err)
+		}
+		body = decoded
+	}
+	if len(liveBatchIds) > 0 {
+		body = renameBatchResponseIds(body, recordedBatchIds, liveBatchIds)
+	}
+
+	header := http.Header{}
+	for key, values := range match.Response.Headers {
+		header[key] = values
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", match.Response.StatusCode, http.StatusText(match.Response.StatusCode)),
+		StatusCode:    match.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// matchKey builds the string requests are matched on: method, path,
+// query and body, without ignored fields. For $batch requests the
+// generated step IDs are replaced by their position, and the original
+// IDs are returned so responses can be renamed.
+func (m *RecorderMiddleware) matchKey(request recordedRequest) (string, []string) {
+	parsed, err := url.Parse(request.Url)
+	if err != nil {
+		return request.Method + " " + request.Url + "\n" + request.Body, nil
+	}
+
+	body := request.Body
+	var batchIds []string
+	if strings.HasSuffix(parsed.Path, "/$batch") {
+		body, batchIds = m.normalizeBatchBody(body)
+	} else {
+		body = m.normalizeJson(body)
+	}
+
+	return request.Method + " " + parsed.Path + "?" + m.normalizeQuery(parsed.Query()) + "\n" + body, batchIds
+}
+
+func (m *RecorderMiddleware) normalizeQuery(query url.Values) string {
+	for key := range query {
+		if m.ignore[strings.ToLower(key)] {
+			query.Del(key)
+		}
+	}
+
+	// Encode sorts by key
+	return query.Encode()
+}
+
+// normalizeJson removes ignored properties and re-serializes
+// with sorted keys. Non-JSON bodies are returned as is.
+func (m *RecorderMiddleware) normalizeJson(body string) string {
+	var value any
+	if body == "" || json.Unmarshal([]byte(body), &value) != nil {
+		return body
+	}
+
+	normalized, _ := json.Marshal(m.removeIgnored(value))
+	return string(normalized)
+}
+
+func (m *RecorderMiddleware) removeIgnored(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if m.ignore[strings.ToLower(key)] {
+				delete(v, key)
+			} else {
+				v[key] = m.removeIgnored(child)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = m.removeIgnored(child)
+		}
+	}
+	return value
+}
+
+func (m *RecorderMiddleware) normalizeBatchBody(body string) (string, []string) {
+	var batch struct {
+		Requests []map[string]any `json:"requests"`
+	}
+	if json.Unmarshal([]byte(body), &batch) != nil {
+		return body, nil
+	}
+
+	ids := make([]string, len(batch.Requests))
+	positions := map[string]string{}
+	for i, step := range batch.Requests {
+		ids[i], _ = step["id"].(string)
+		positions[ids[i]] = strconv.Itoa(i)
+	}
+
+	for _, step := range batch.Requests {
+		// A step without an ID is left as it is
+		if id, ok := step["id"].(string); ok {
+			step["id"] = positions[id]
+		}
+		if dependsOn, ok := step["dependsOn"].([]any); ok {
+			for i, dependency := range dependsOn {
+				if id, ok := dependency.(string); ok {
+					dependsOn[i] = positions[id]
+				}
+			}
+		}
+		if stepUrl, ok := step["url"].(string); ok {
+			if parsed, err := url.Parse(stepUrl); err == nil {
+				step["url"] = parsed.Path + "?" + m.normalizeQuery(parsed.Query())
+			}
+		}
+	}
+
+	normalized, _ := json.Marshal(m.removeIgnored(map[string]any{"requests": batch.Requests}))
+	return string(normalized), ids
+}
+
+// renameBatchResponseIds maps step IDs in a recorded batch response
+// to the IDs used by the live request at the same position.
+func renameBatchResponseIds(body []byte, recordedIds []string, liveIds []string) []byte {
+	renames := map[string]string{}
+	for i, id := range recordedIds {
+		if i < len(liveIds) {
+			renames[id] = liveIds[i]
+		}
+	}
+
+	var batch map[string]any
+	if json.Unmarshal(body, &batch) != nil {
+		return body
+	}
+
+	responses, _ := batch["responses"].([]any)
+	for _, response := range responses {
+		if step, ok := response.(map[string]any); ok {
+			if id, ok := step["id"].(string); ok {
+				if live, ok := renames[id]; ok {
+					step["id"] = live
+				}
+			}
+		}
+	}
+
+	renamed, err := json.Marshal(batch)
+	if err != nil {
+		return body
+	}
+
+	return renamed
+}
@@ -0,0 +1,240 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package graphhelper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity/cache"
+)
+
+// tokenCacheName names both the persistent token cache and the
+// directory holding the authentication record
+const tokenCacheName = "msgraph-snippets-go"
+
+// userAuthenticator is implemented by the credentials that sign in a
+// user and can describe the account with an AuthenticationRecord
+type userAuthenticator interface {
+	azcore.TokenCredential
+	Authenticate(ctx context.Context, options *policy.TokenRequestOptions) (azidentity.AuthenticationRecord, error)
+}
+
+// cachesSignIn reports whether the mode keeps its sign-in between runs
+func (mode AuthMode) cachesSignIn() bool {
+	return mode == DeviceCodeAuth || mode == InteractiveAuth
+}
+
+// newPersistentCache returns a token cache shared by every run of the
+// sample. If the platform has no secure storage for it, the cache is kept
+// in memory and a warning is printed.
+func newPersistentCache() azidentity.Cache {
+	tokenCache, err := cache.New(&cache.Options{Name: tokenCacheName})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: tokens will not be cached between runs: %v\n", err)
+		return azidentity.Cache{}
+	}
+
+	return tokenCache
+}
+
+func authenticationRecordPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("finding the user configuration directory: %w", err)
+	}
+
+	return filepath.Join(configDir, tokenCacheName, "authentication-record.json"), nil
+}
+
+// LoadAuthenticationRecord returns the account saved by the last sign-in,
+// or a zero AuthenticationRecord if nobody is signed in.
+func LoadAuthenticationRecord() (azidentity.AuthenticationRecord, error) {
+	var record azidentity.AuthenticationRecord
+
+	recordPath, err := authenticationRecordPath()
+	if err != nil {
+		return record, err
+	}
+
+	data, err := os.ReadFile(recordPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return record, nil
+	}
+	if err != nil {
+		return record, fmt.Errorf("reading authentication record: %w", err)
+	}
+
+	err = json.Unmarshal(data, &record)
+	if err != nil {
+		return record, fmt.Errorf("parsing authentication record %s: %w", recordPath, err)
+	}
+
+	return record, nil
+}
+
+// saveAuthenticationRecord writes the record to a file only the current
+// user can read, replacing any earlier record.
+func saveAuthenticationRecord(record azidentity.AuthenticationRecord) error {
+	recordPath, err := authenticationRecordPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encoding authentication record: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(recordPath), 0700)
+	if err != nil {
+		return fmt.Errorf("creating authentication record directory: %w", err)
+	}
+
+	// CreateTemp creates the file with mode 0600
+	file, err := os.CreateTemp(filepath.Dir(recordPath), ".authentication-record-*")
+	if err != nil {
+		return fmt.Errorf("creating authentication record: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing authentication record: %w", err)
+	}
+
+	err = os.Rename(file.Name(), recordPath)
+	if err != nil {
+		return fmt.Errorf("saving authentication record: %w", err)
+	}
+
+	return nil
+}
+
+// Login prompts the user to sign in with the credential selected by
+// AUTH_MODE, even if an account is already saved, and saves the new
+// account so later runs can sign in silently.
+func Login(ctx context.Context) (azidentity.AuthenticationRecord, error) {
+	mode, err := AuthModeFromEnvironment()
+	if err != nil {
+		return azidentity.AuthenticationRecord{}, err
+	}
+	if !mode.cachesSignIn() {
+		return azidentity.AuthenticationRecord{}, fmt.Errorf("AUTH_MODE %s does not support login", mode)
+	}
+
+	credential, err := newCredential(mode, azidentity.AuthenticationRecord{})
+	if err != nil {
+		return azidentity.AuthenticationRecord{}, fmt.Errorf("creating %s credential: %w", mode, err)
+	}
+
+	return authenticate(ctx, credential.(userAuthenticator), scopesFor(mode))
+}
+
+// signInIfNeeded prompts the user to sign in if no account is saved yet.
+// Credentials that sign in lazily on the first request would otherwise
+// never produce a record to save.
+func signInIfNeeded(ctx context.Context, credential azcore.TokenCredential, scopes []string) error {
+	authenticator, ok := credential.(userAuthenticator)
+	if !ok {
+		return nil
+	}
+
+	record, err := LoadAuthenticationRecord()
+	if err != nil || record.HomeAccountID != "" {
+		return err
+	}
+
+	_, err = authenticate(ctx, authenticator, scopes)
+	return err
+}
+
+func authenticate(ctx context.Context, authenticator userAuthenticator, scopes []string) (azidentity.AuthenticationRecord, error) {
+	record, err := authenticator.Authenticate(ctx, &policy.TokenRequestOptions{Scopes: scopes})
+	if err != nil {
+		return record, fmt.Errorf("signing in: %w", err)
+	}
+
+	err = saveAuthenticationRecord(record)
+	if err != nil {
+		return record, err
+	}
+
+	return record, nil
+}
+
+// Logout deletes the saved account and the cached tokens. It returns
+// false if there was neither a saved account nor a token cache file.
+//
+// On macOS the tokens are kept in the keychain, which Logout cannot
+// clear, so it prints a warning with the command that deletes them.
+func Logout() (bool, error) {
+	recordPath, err := authenticationRecordPath()
+	if err != nil {
+		return false, err
+	}
+
+	removedRecord := true
+	err = os.Remove(recordPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		removedRecord = false
+	} else if err != nil {
+		return false, fmt.Errorf("deleting authentication record: %w", err)
+	}
+
+	// Cleared even without a record, as a failed sign-in can leave tokens
+	removedCache, err := deleteTokenCache()
+	if err != nil {
+		return removedRecord, err
+	}
+
+	if runtime.GOOS == "darwin" {
+		fmt.Fprintf(os.Stderr, "Warning: cached tokens remain in the keychain. To delete them, run\n"+
+			"  security delete-generic-password -s %s -a MSALCache\n", tokenCacheName)
+	}
+
+	return removedRecord || removedCache, nil
+}
+
+// deleteTokenCache deletes the files of the persistent token cache and
+// reports whether there were any. The cache package has no way to clear
+// a cache, so this relies on it storing the cache in .IdentityService
+// under the user cache directory on Linux and Windows. On Linux the
+// file is encrypted with a key in the user keyring, which is useless
+// without the file. On macOS there are no such files.
+func deleteTokenCache() (bool, error) {
+	if runtime.GOOS == "darwin" {
+		return false, nil
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return false, fmt.Errorf("finding the token cache: %w", err)
+	}
+
+	removed := false
+	for _, name := range []string{tokenCacheName, tokenCacheName + ".cae"} {
+		err = os.Remove(filepath.Join(cacheDir, ".IdentityService", name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return removed, fmt.Errorf("deleting token cache: %w", err)
+		}
+		removed = true
+	}
+
+	return removed, nil
+}
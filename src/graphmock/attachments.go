@@ -0,0 +1,92 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package graphmock
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// maxAttachmentPost is the largest attachment that can be
+// posted in one request. Larger ones need an upload session.
+const maxAttachmentPost = 3 * 1024 * 1024
+
+// attachmentParent returns the path, relative to BaseUrl, of the message
+// or event whose attachments r addresses, and whether that item exists
+func (s *Server) attachmentParent(r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if strings.HasPrefix(r.URL.Path, "/v1.0/me/events/") {
+		for _, event := range s.events {
+			if event["id"] == id {
+				return "/me/events/" + id, true
+			}
+		}
+		return "", false
+	}
+
+	return "/me/messages/" + id, s.findMessage(id) >= 0
+}
+
+// addAttachment stores attachment on the item with parentId and returns its ID
+func (s *Server) addAttachment(parentId string, attachment map[string]any) string {
+	id := s.newId("attachment")
+	attachment["id"] = id
+	attachment["@odata.type"] = "#microsoft.graph.fileAttachment"
+	s.attachments[parentId] = append(s.attachments[parentId], attachment)
+
+	return id
+}
+
+func (s *Server) createAttachment(w http.ResponseWriter, r *http.Request) {
+	body, err := readJson(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "ErrorInvalidRequest", "invalid attachment body")
+		return
+	}
+	if body["@odata.type"] != "#microsoft.graph.fileAttachment" {
+		writeError(w, http.StatusBadRequest, "ErrorInvalidRequest", "Only file attachments are supported")
+		return
+	}
+	encoded, _ := body["contentBytes"].(string)
+	content, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "ErrorInvalidRequest", "contentBytes is not valid base64")
+		return
+	}
+	if len(content) > maxAttachmentPost {
+		writeError(w, http.StatusRequestEntityTooLarge, "ErrorRequestEntityTooLarge",
+			"The attachment is too large to post. Use an upload session.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.attachmentParent(r); !ok {
+		writeError(w, http.StatusNotFound, "ErrorItemNotFound", "The specified object was not found in the store.")
+		return
+	}
+
+	attachment := map[string]any{
+		"name":         body["name"],
+		"contentType":  body["contentType"],
+		"isInline":     body["isInline"] == true,
+		"contentId":    body["contentId"],
+		"size":         len(content),
+		"contentBytes": encoded,
+	}
+	s.addAttachment(r.PathValue("id"), attachment)
+
+	writeJson(w, http.StatusCreated, attachment)
+}
+
+// Attachments returns the attachments of the message or event with
+// the given ID. Each includes its content as base64 in contentBytes.
+func (s *Server) Attachments(itemId string) []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.attachments[itemId]
+}
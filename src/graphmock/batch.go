@@ -0,0 +1,153 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package graphmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// maxBatchSteps is the Graph limit on requests in a single batch.
+const maxBatchSteps = 20
+
+type batchStep struct {
+	Id        string            `json:"id"`
+	Method    string            `json:"method"`
+	Url       string            `json:"url"`
+	Headers   map[string]string `json:"headers"`
+	Body      any               `json:"body"`
+	DependsOn []string          `json:"dependsOn"`
+}
+
+type batchStepResponse struct {
+	Id      string            `json:"id"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    any               `json:"body,omitempty"`
+}
+
+// batch executes each step against the mock's own routes. Steps run in
+// request order, except that a step always runs after the steps it depends
+// on. Steps whose dependency failed return 424 Failed Dependency.
+func (s *Server) batch(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Requests []batchStep `json:"requests"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", "invalid batch body")
+		return
+	}
+
+	if len(request.Requests) > maxBatchSteps {
+		writeError(w, http.StatusBadRequest, "BadRequest",
+			fmt.Sprintf("The number of batch requests exceeds the limit of %d.", maxBatchSteps))
+		return
+	}
+
+	steps := map[string]batchStep{}
+	for _, step := range request.Requests {
+		if _, exists := steps[step.Id]; exists || step.Id == "" {
+			writeError(w, http.StatusBadRequest, "BadRequest", "batch request ids must be unique and non-empty")
+			return
+		}
+		steps[step.Id] = step
+	}
+	for _, step := range request.Requests {
+		for _, dependency := range step.DependsOn {
+			if _, exists := steps[dependency]; !exists {
+				writeError(w, http.StatusBadRequest, "BadRequest",
+					fmt.Sprintf("Request %s depends on unknown request %s.", step.Id, dependency))
+				return
+			}
+		}
+	}
+
+	responses := map[string]batchStepResponse{}
+	inProgress := map[string]bool{}
+	var run func(step batchStep) batchStepResponse
+	run = func(step batchStep) batchStepResponse {
+		if response, done := responses[step.Id]; done {
+			return response
+		}
+		if inProgress[step.Id] {
+			return errorStepResponse(step.Id, http.StatusBadRequest, "BadRequest", "circular dependency")
+		}
+		inProgress[step.Id] = true
+
+		var response batchStepResponse
+		for _, dependency := range step.DependsOn {
+			result := run(steps[dependency])
+			if result.Status >= 400 {
+				response = errorStepResponse(step.Id, http.StatusFailedDependency, "FailedDependency",
+					"Dependent request "+dependency+" failed.")
+			}
+		}
+		if response.Id == "" {
+			response = s.runBatchStep(step)
+		}
+
+		responses[step.Id] = response
+		return response
+	}
+
+	result := []batchStepResponse{}
+	for _, step := range request.Requests {
+		result = append(result, run(step))
+	}
+
+	writeJson(w, http.StatusOK, map[string]any{"responses": result})
+}
+
+func errorStepResponse(id string, status int, code string, message string) batchStepResponse {
+	return batchStepResponse{
+		Id:      id,
+		Status:  status,
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body: map[string]any{
+			"error": map[string]any{"code": code, "message": message},
+		},
+	}
+}
+
+func (s *Server) runBatchStep(step batchStep) batchStepResponse {
+	var body bytes.Buffer
+	if step.Body != nil {
+		json.NewEncoder(&body).Encode(step.Body)
+	}
+
+	url := step.Url
+	if !strings.HasPrefix(url, "/") {
+		url = "/" + url
+	}
+
+	request := httptest.NewRequest(step.Method, "/v1.0"+url, &body)
+	for key, value := range step.Headers {
+		request.Header.Set(key, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	s.serve(recorder, request)
+
+	response := batchStepResponse{
+		Id:      step.Id,
+		Status:  recorder.Code,
+		Headers: map[string]string{},
+	}
+	for key := range recorder.Header() {
+		response.Headers[key] = recorder.Header().Get(key)
+	}
+	if recorder.Body.Len() > 0 {
+		var decoded any
+		if json.Unmarshal(recorder.Body.Bytes(), &decoded) == nil {
+			response.Body = decoded
+		}
+	}
+
+	return response
+}
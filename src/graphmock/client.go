@@ -0,0 +1,29 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package graphmock
+
+import (
+	"sdksnippets/graphhelper"
+	"testing"
+
+	khttp "github.com/microsoft/kiota-http-go"
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+)
+
+// NewTestClient starts a Server that is closed when t ends, and returns
+// it with a client created by graphhelper.NewOfflineGraphServiceClient
+// that sends requests to it through middleware.
+func NewTestClient(t testing.TB, middleware ...khttp.Middleware) (*graph.GraphServiceClient, *Server) {
+	t.Helper()
+
+	server := NewServer()
+	t.Cleanup(server.Close)
+
+	graphClient, err := graphhelper.NewOfflineGraphServiceClient(server.BaseUrl(), middleware...)
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+
+	return graphClient, server
+}
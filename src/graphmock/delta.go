@@ -0,0 +1,99 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package graphmock
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+var maxPageSizeExpression = regexp.MustCompile(`odata\.maxpagesize=(\d+)`)
+
+// removedItem is a deleted item that delta queries still report
+type removedItem struct {
+	id      string
+	version int
+}
+
+// changeMessage records that the message with id was created or
+// updated. Callers must hold s.mu.
+func (s *Server) changeMessage(id string) {
+	s.version++
+	s.messageVersions[id] = s.version
+}
+
+// removeMessage records that the message with id was deleted.
+// Callers must hold s.mu.
+func (s *Server) removeMessage(id string) {
+	s.version++
+	delete(s.messageVersions, id)
+	s.removedMessages = append(s.removedMessages, removedItem{id: id, version: s.version})
+}
+
+// messagesDelta serves messages/delta() for any mail folder, as every message
+// is in every folder. Without a $deltatoken every message is returned;
+// with one, only the messages changed or deleted after the round that
+// issued it. Pages hold up to the odata.maxpagesize of the Prefer header.
+func (s *Server) messagesDelta(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	since := -1
+	if token := query.Get("$deltatoken"); token != "" {
+		var err error
+		since, err = strconv.Atoi(token)
+		if err != nil || since < 0 {
+			writeError(w, http.StatusBadRequest, "BadRequest", "invalid $deltatoken")
+			return
+		}
+	}
+	skip, err := intParameter(query.Get("$skip"), 0)
+	if err != nil || skip < 0 {
+		writeError(w, http.StatusBadRequest, "BadRequest", "invalid value for $skip")
+		return
+	}
+	pageSize := 10
+	if match := maxPageSizeExpression.FindStringSubmatch(r.Header.Get("Prefer")); match != nil {
+		pageSize, _ = strconv.Atoi(match[1])
+		pageSize = max(1, min(pageSize, maxPageSize))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changes []map[string]any
+	for _, message := range s.messages {
+		if since < 0 || s.messageVersions[message["id"].(string)] > since {
+			changes = append(changes, selectProperties(message, query.Get("$select")))
+		}
+	}
+	if since >= 0 {
+		for _, removed := range s.removedMessages {
+			if removed.version > since {
+				changes = append(changes, map[string]any{
+					"id":       removed.id,
+					"@removed": map[string]any{"reason": "deleted"},
+				})
+			}
+		}
+	}
+
+	end := min(skip+pageSize, len(changes))
+	page := []map[string]any{}
+	if skip < len(changes) {
+		page = changes[skip:end]
+	}
+
+	response := map[string]any{"value": page}
+	if end < len(changes) {
+		query.Set("$skip", strconv.Itoa(end))
+		response["@odata.nextLink"] = s.URL + r.URL.Path + "?" + query.Encode()
+	} else {
+		query.Del("$skip")
+		query.Set("$deltatoken", strconv.Itoa(s.version))
+		response["@odata.deltaLink"] = s.URL + r.URL.Path + "?" + query.Encode()
+	}
+
+	writeJson(w, http.StatusOK, response)
+}
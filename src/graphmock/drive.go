@@ -0,0 +1,294 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package graphmock
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// drivePath returns the path of an item addressed as root:/{path}:,
+// relative to the drive root
+func drivePath(itemId string) (string, bool) {
+	if !strings.HasPrefix(itemId, "root:/") || !strings.HasSuffix(itemId, ":") {
+		return "", false
+	}
+
+	return strings.Trim(strings.TrimSuffix(strings.TrimPrefix(itemId, "root:/"), ":"), "/"), true
+}
+
+// itemPath returns the path, relative to the drive root, of an item
+// addressed as root:/{path}: or {parent-id}:/{name}:. The item need
+// not exist, but its parent must. Callers must hold s.mu.
+func (s *Server) itemPath(itemId string) (string, bool) {
+	if itemPath, ok := drivePath(itemId); ok {
+		return itemPath, true
+	}
+
+	parentId, name, found := strings.Cut(strings.TrimSuffix(itemId, ":"), ":/")
+	if !found || !strings.HasSuffix(itemId, ":") || name == "" || strings.Contains(name, "/") {
+		return "", false
+	}
+	parent, ok := s.findDriveItem(parentId)
+	if !ok || !parent.folder {
+		return "", false
+	}
+
+	return strings.TrimPrefix(path.Join(parent.path, name), "/"), true
+}
+
+// availablePath returns itemPath, or if an item already has that
+// path, the first of "name 1.ext", "name 2.ext" and so on that is free,
+// as Graph does for the rename conflict behavior. Callers must hold s.mu.
+func (s *Server) availablePath(itemPath string) string {
+	extension := path.Ext(itemPath)
+	base := strings.TrimSuffix(itemPath, extension)
+	for i := 1; ; i++ {
+		if _, exists := s.driveItems[itemPath]; !exists {
+			return itemPath
+		}
+		itemPath = fmt.Sprintf("%s %d%s", base, i, extension)
+	}
+}
+
+// findDriveItem returns the item addressed by itemId, which is root,
+// root:/{path}: or an item ID. The root folder has the empty path.
+// Callers must hold s.mu.
+func (s *Server) findDriveItem(itemId string) (*driveItem, bool) {
+	if itemId == "root" {
+		return &driveItem{id: "root", name: "root", folder: true}, true
+	}
+	if itemPath, ok := drivePath(itemId); ok {
+		if itemPath == "" {
+			return s.findDriveItem("root")
+		}
+		item, ok := s.driveItems[itemPath]
+		return item, ok
+	}
+
+	for _, item := range s.driveItems {
+		if item.id == itemId {
+			return item, true
+		}
+	}
+
+	return nil, false
+}
+
+func (s *Server) checkDrive(w http.ResponseWriter, r *http.Request) bool {
+	if r.PathValue("driveId") != s.driveId {
+		writeError(w, http.StatusNotFound, "itemNotFound", "Drive not found")
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) getDriveItem(w http.ResponseWriter, r *http.Request) {
+	if !s.checkDrive(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.findDriveItem(r.PathValue("itemId"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "itemNotFound", "The resource could not be found.")
+		return
+	}
+
+	writeJson(w, http.StatusOK, item.toJson())
+}
+
+// createDriveItemChild creates a folder in the parent folder
+func (s *Server) createDriveItemChild(w http.ResponseWriter, r *http.Request) {
+	if !s.checkDrive(w, r) {
+		return
+	}
+
+	body, err := readJson(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "invalid item body")
+		return
+	}
+	name, _ := body["name"].(string)
+	if name == "" || strings.Contains(name, "/") {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "invalid item name")
+		return
+	}
+	if _, ok := body["folder"]; !ok {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "Only folders can be created as children")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parent, ok := s.findDriveItem(r.PathValue("itemId"))
+	if !ok || !parent.folder {
+		writeError(w, http.StatusNotFound, "itemNotFound", "The parent folder could not be found.")
+		return
+	}
+
+	itemPath := strings.TrimPrefix(path.Join(parent.path, name), "/")
+	if existing, exists := s.driveItems[itemPath]; exists {
+		if body["@microsoft.graph.conflictBehavior"] == "fail" || !existing.folder {
+			writeError(w, http.StatusConflict, "nameAlreadyExists", "The specified item name already exists")
+			return
+		}
+		writeJson(w, http.StatusOK, existing.toJson())
+		return
+	}
+
+	item := &driveItem{
+		id:           s.newId("item"),
+		name:         name,
+		path:         itemPath,
+		folder:       true,
+		lastModified: time.Now().UTC(),
+	}
+	s.driveItems[itemPath] = item
+
+	writeJson(w, http.StatusCreated, item.toJson())
+}
+
+// putDriveItemContent creates or replaces a file with the request body
+func (s *Server) putDriveItemContent(w http.ResponseWriter, r *http.Request) {
+	if !s.checkDrive(w, r) {
+		return
+	}
+
+	itemPath, ok := drivePath(r.PathValue("itemId"))
+	if !ok || itemPath == "" {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "Only path-based item addressing is supported")
+		return
+	}
+
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "could not read request body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, exists := s.driveItems[itemPath]
+	if exists && item.folder {
+		writeError(w, http.StatusConflict, "nameAlreadyExists", "A folder with the specified name already exists")
+		return
+	}
+	status := http.StatusOK
+	if !exists {
+		item = &driveItem{
+			id:   s.newId("item"),
+			name: path.Base(itemPath),
+			path: itemPath,
+		}
+		s.driveItems[itemPath] = item
+		status = http.StatusCreated
+	}
+	item.content = content
+	item.lastModified = time.Now().UTC()
+
+	writeJson(w, status, item.toJson())
+}
+
+// getDriveItemContent redirects to the download URL of a file,
+// as Graph does
+func (s *Server) getDriveItemContent(w http.ResponseWriter, r *http.Request) {
+	if !s.checkDrive(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	item, ok := s.findDriveItem(r.PathValue("itemId"))
+	s.mu.Unlock()
+	if !ok || item.folder {
+		writeError(w, http.StatusNotFound, "itemNotFound", "The resource could not be found.")
+		return
+	}
+
+	http.Redirect(w, r, s.URL+"/download/"+item.id, http.StatusFound)
+}
+
+// downloadDriveItem serves the content of a file, honouring Range headers
+func (s *Server) downloadDriveItem(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	item, ok := s.findDriveItem(r.PathValue("id"))
+	if !ok || item.folder {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "itemNotFound", "The resource could not be found.")
+		return
+	}
+	// Uploads replace content rather than modifying it
+	content, lastModified := item.content, item.lastModified
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", lastModified, bytes.NewReader(content))
+}
+
+// SetDriveItemContent creates or replaces the file at itemPath, relative
+// to the drive root, and returns its ID. Missing folders are not created.
+func (s *Server) SetDriveItemContent(itemPath string, content []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	itemPath = strings.Trim(itemPath, "/")
+	item, exists := s.driveItems[itemPath]
+	if !exists {
+		item = &driveItem{
+			id:   s.newId("item"),
+			name: path.Base(itemPath),
+			path: itemPath,
+		}
+		s.driveItems[itemPath] = item
+	}
+	item.content = content
+	item.lastModified = time.Now().UTC()
+
+	return item.id
+}
+
+func (s *Server) checkSite(w http.ResponseWriter, r *http.Request) bool {
+	if r.PathValue("siteId") != s.siteId {
+		writeError(w, http.StatusNotFound, "itemNotFound", "Site not found")
+		return false
+	}
+
+	return true
+}
+
+// siteDrive returns the drive as the document library of the site.
+// The mock has one drive, which is also the user's OneDrive.
+func (s *Server) siteDrive() map[string]any {
+	return map[string]any{
+		"id":        s.driveId,
+		"driveType": "documentLibrary",
+		"name":      "Documents",
+	}
+}
+
+func (s *Server) getSiteDrive(w http.ResponseWriter, r *http.Request) {
+	if !s.checkSite(w, r) {
+		return
+	}
+
+	writeJson(w, http.StatusOK, s.siteDrive())
+}
+
+func (s *Server) listSiteDrives(w http.ResponseWriter, r *http.Request) {
+	if !s.checkSite(w, r) {
+		return
+	}
+
+	writeJson(w, http.StatusOK, map[string]any{"value": []any{s.siteDrive()}})
+}
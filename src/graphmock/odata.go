@@ -0,0 +1,149 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package graphmock
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const maxPageSize = 1000
+
+var (
+	eqExpression  = regexp.MustCompile(`^([\w/]+) eq '([^']*)'$`)
+	anyExpression = regexp.MustCompile(`^([\w/]+)/[Aa]ny\((\w+):(\w+) eq '([^']*)'\)$`)
+)
+
+// writeCollection writes a page of items, applying $filter, $select, $top
+// and $skip from the request. If more items remain, an @odata.nextLink
+// pointing back at the server is included.
+func (s *Server) writeCollection(w http.ResponseWriter, r *http.Request, items []map[string]any, defaultTop int) {
+	query := r.URL.Query()
+
+	filtered, err := filterItems(items, query.Get("$filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+
+	top, err := intParameter(query.Get("$top"), defaultTop)
+	if err != nil || top < 1 || top > maxPageSize {
+		writeError(w, http.StatusBadRequest, "BadRequest", "invalid value for $top")
+		return
+	}
+	skip, err := intParameter(query.Get("$skip"), 0)
+	if err != nil || skip < 0 {
+		writeError(w, http.StatusBadRequest, "BadRequest", "invalid value for $skip")
+		return
+	}
+
+	end := min(skip+top, len(filtered))
+	page := []map[string]any{}
+	if skip < len(filtered) {
+		for _, item := range filtered[skip:end] {
+			page = append(page, selectProperties(item, query.Get("$select")))
+		}
+	}
+
+	response := map[string]any{"value": page}
+	if end < len(filtered) {
+		query.Set("$top", strconv.Itoa(top))
+		query.Set("$skip", strconv.Itoa(end))
+		response["@odata.nextLink"] = s.URL + r.URL.Path + "?" + query.Encode()
+	}
+
+	writeJson(w, http.StatusOK, response)
+}
+
+func intParameter(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+// selectProperties applies a $select value to an item.
+// The id property is always included.
+func selectProperties(item map[string]any, selectValue string) map[string]any {
+	if selectValue == "" {
+		return copyMap(item)
+	}
+
+	selected := map[string]any{"id": item["id"]}
+	for _, property := range strings.Split(selectValue, ",") {
+		property = strings.TrimSpace(property)
+		if value, ok := item[property]; ok {
+			selected[property] = value
+		}
+	}
+
+	return copyMap(selected)
+}
+
+// filterItems applies a $filter value to items. Only equality on string
+// properties, lambda any() on string collections, and "and" are supported.
+func filterItems(items []map[string]any, filter string) ([]map[string]any, error) {
+	if filter == "" {
+		return items, nil
+	}
+
+	var predicates []func(item map[string]any) bool
+	for _, expression := range strings.Split(filter, " and ") {
+		expression = strings.TrimSpace(expression)
+		if match := anyExpression.FindStringSubmatch(expression); match != nil && match[2] == match[3] {
+			path, value := match[1], match[4]
+			predicates = append(predicates, func(item map[string]any) bool {
+				collection, _ := propertyValue(item, path).([]any)
+				for _, element := range collection {
+					if element == value {
+						return true
+					}
+				}
+				return false
+			})
+		} else if match := eqExpression.FindStringSubmatch(expression); match != nil {
+			path, value := match[1], match[2]
+			predicates = append(predicates, func(item map[string]any) bool {
+				return propertyValue(item, path) == value
+			})
+		} else {
+			return nil, fmt.Errorf("unsupported filter expression: %s", expression)
+		}
+	}
+
+	var filtered []map[string]any
+	for _, item := range items {
+		matches := true
+		for _, predicate := range predicates {
+			if !predicate(item) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			filtered = append(filtered, item)
+		}
+	}
+
+	return filtered, nil
+}
+
+// propertyValue resolves a slash-separated property path
+// such as sender/emailAddress/address.
+func propertyValue(item map[string]any, path string) any {
+	var value any = item
+	for _, segment := range strings.Split(path, "/") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[segment]
+	}
+
+	return value
+}
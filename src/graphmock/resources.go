@@ -0,0 +1,268 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package graphmock
+
+import (
+	"net/http"
+	"time"
+)
+
+const graphDateTimeFormat = "2006-01-02T15:04:05.0000000"
+
+func (s *Server) getMe(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJson(w, http.StatusOK, selectProperties(s.user, r.URL.Query().Get("$select")))
+}
+
+func (s *Server) listMessages(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writeCollection(w, r, s.messages, 10)
+}
+
+func (s *Server) createMessage(w http.ResponseWriter, r *http.Request) {
+	message, err := readJson(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", "invalid message body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message["id"] = s.newId("message")
+	message["isDraft"] = true
+	message["createdDateTime"] = time.Now().UTC().Format(time.RFC3339)
+	s.messages = append(s.messages, message)
+	s.changeMessage(message["id"].(string))
+
+	writeJson(w, http.StatusCreated, message)
+}
+
+func (s *Server) findMessage(id string) int {
+	for i, message := range s.messages {
+		if message["id"] == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (s *Server) getMessage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findMessage(r.PathValue("id"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "ErrorItemNotFound", "The specified object was not found in the store.")
+		return
+	}
+
+	message := selectProperties(s.messages[i], r.URL.Query().Get("$select"))
+	if r.URL.Query().Get("$expand") == "attachments" {
+		attachments := []any{}
+		for _, attachment := range s.attachments[r.PathValue("id")] {
+			attachments = append(attachments, attachment)
+		}
+		message["attachments"] = attachments
+	}
+
+	writeJson(w, http.StatusOK, message)
+}
+
+func (s *Server) updateMessage(w http.ResponseWriter, r *http.Request) {
+	update, err := readJson(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", "invalid message body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findMessage(r.PathValue("id"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "ErrorItemNotFound", "The specified object was not found in the store.")
+		return
+	}
+
+	for key, value := range update {
+		if key != "id" {
+			s.messages[i][key] = value
+		}
+	}
+	s.changeMessage(r.PathValue("id"))
+
+	writeJson(w, http.StatusOK, s.messages[i])
+}
+
+func (s *Server) deleteMessage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findMessage(r.PathValue("id"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "ErrorItemNotFound", "The specified object was not found in the store.")
+		return
+	}
+
+	s.messages = append(s.messages[:i], s.messages[i+1:]...)
+	delete(s.attachments, r.PathValue("id"))
+	s.removeMessage(r.PathValue("id"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listEvents(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writeCollection(w, r, s.events, 10)
+}
+
+func (s *Server) createEvent(w http.ResponseWriter, r *http.Request) {
+	event, err := readJson(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", "invalid event body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event["id"] = s.newId("event")
+	s.events = append(s.events, event)
+
+	writeJson(w, http.StatusCreated, event)
+}
+
+func (s *Server) calendarView(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	viewStart, err := time.Parse(time.RFC3339, query.Get("startDateTime"))
+	if err != nil {
+		viewStart, err = time.Parse("2006-01-02T15:04:05", query.Get("startDateTime"))
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "ErrorInvalidParameter", "startDateTime is missing or invalid")
+		return
+	}
+	viewEnd, err := time.Parse(time.RFC3339, query.Get("endDateTime"))
+	if err != nil {
+		viewEnd, err = time.Parse("2006-01-02T15:04:05", query.Get("endDateTime"))
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "ErrorInvalidParameter", "endDateTime is missing or invalid")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var inView []map[string]any
+	for _, event := range s.events {
+		start, ok := eventTime(event, "start")
+		if !ok {
+			continue
+		}
+		end, ok := eventTime(event, "end")
+		if !ok {
+			continue
+		}
+		if start.Before(viewEnd) && end.After(viewStart) {
+			inView = append(inView, event)
+		}
+	}
+
+	s.writeCollection(w, r, inView, 10)
+}
+
+// eventTime reads the start or end dateTimeTimeZone of an event.
+func eventTime(event map[string]any, property string) (time.Time, bool) {
+	dateTime, _ := propertyValue(event, property+"/dateTime").(string)
+	timeZone, _ := propertyValue(event, property+"/timeZone").(string)
+
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	for _, layout := range []string{graphDateTimeFormat, "2006-01-02T15:04:05"} {
+		parsed, err := time.ParseInLocation(layout, dateTime, location)
+		if err == nil {
+			return parsed, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func (s *Server) listCalendars(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writeCollection(w, r, s.calendars, 10)
+}
+
+func (s *Server) createCalendar(w http.ResponseWriter, r *http.Request) {
+	calendar, err := readJson(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", "invalid calendar body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.calendars {
+		if existing["name"] == calendar["name"] {
+			writeError(w, http.StatusConflict, "ErrorFolderExists", "A folder with the specified name already exists.")
+			return
+		}
+	}
+
+	calendar["id"] = s.newId("calendar")
+	s.calendars = append(s.calendars, calendar)
+
+	writeJson(w, http.StatusCreated, calendar)
+}
+
+func (s *Server) listGroups(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writeCollection(w, r, s.groups, 100)
+}
+
+func (s *Server) updateTeam(w http.ResponseWriter, r *http.Request) {
+	update, err := readJson(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", "invalid team body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	team, ok := s.teams[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NotFound", "No team found with Group Id "+r.PathValue("id"))
+		return
+	}
+
+	for key, value := range update {
+		team[key] = value
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getDrive(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, map[string]any{
+		"id":        s.driveId,
+		"driveType": "business",
+		"name":      "OneDrive",
+	})
+}
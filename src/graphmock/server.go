@@ -0,0 +1,312 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+// Package graphmock provides an in-memory stand-in for the parts of
+// Microsoft Graph that the snippets use, so they can run without a tenant.
+package graphmock
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a mock Microsoft Graph server backed by httptest.
+// All routes are served under the /v1.0 prefix.
+type Server struct {
+	*httptest.Server
+
+	mux *http.ServeMux
+
+	mu             sync.Mutex
+	nextId         int
+	user           map[string]any
+	messages       []map[string]any
+	attachments    map[string][]map[string]any
+	events         []map[string]any
+	calendars      []map[string]any
+	groups         []map[string]any
+	teams          map[string]map[string]any
+	driveId        string
+	siteId         string
+	driveItems     map[string]*driveItem
+	uploadSessions map[string]*uploadSession
+	failures       map[string]*injectedFailure
+	latency        time.Duration
+
+	// version counts message changes, and messageVersions holds the
+	// version of the last change to each message, for delta queries
+	version         int
+	messageVersions map[string]int
+	removedMessages []removedItem
+}
+
+// injectedFailure is a response returned instead of
+// running the handler, set up by FailNext
+type injectedFailure struct {
+	remaining  int
+	status     int
+	retryAfter int
+}
+
+// NewServer starts a mock server seeded with a user, messages,
+// events and groups. Call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		mux:            http.NewServeMux(),
+		attachments:    map[string][]map[string]any{},
+		teams:          map[string]map[string]any{},
+		driveId:        "mock-drive-id",
+		siteId:         "mock-site-id",
+		driveItems:     map[string]*driveItem{},
+		uploadSessions: map[string]*uploadSession{},
+		failures:       map[string]*injectedFailure{},
+
+		messageVersions: map[string]int{},
+	}
+	s.seed()
+	s.routes()
+	s.Server = httptest.NewServer(decompress(http.HandlerFunc(s.serve)))
+
+	return s
+}
+
+// BaseUrl returns the service root to use with a Graph request adapter.
+func (s *Server) BaseUrl() string {
+	return s.URL + "/v1.0"
+}
+
+// FailNext makes the next count requests to path, relative to BaseUrl,
+// fail with status and a Retry-After header of retryAfter seconds. This
+// applies to requests sent directly and to steps in a $batch request.
+func (s *Server) FailNext(path string, count int, status int, retryAfter int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures["/v1.0"+path] = &injectedFailure{
+		remaining:  count,
+		status:     status,
+		retryAfter: retryAfter,
+	}
+}
+
+// SetLatency delays every response by latency, to
+// simulate the round trip to the real service.
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latency = latency
+}
+
+// serve returns any failure set up by FailNext, or routes the request
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	latency := s.latency
+	s.mu.Unlock()
+	time.Sleep(latency)
+
+	s.mu.Lock()
+	failure, ok := s.failures[r.URL.Path]
+	if ok && failure.remaining > 0 {
+		failure.remaining--
+		s.mu.Unlock()
+
+		w.Header().Set("Retry-After", strconv.Itoa(failure.retryAfter))
+		code := strings.ReplaceAll(http.StatusText(failure.status), " ", "")
+		writeError(w, failure.status, code, "The mock server was told to fail this request")
+		return
+	}
+	s.mu.Unlock()
+
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /v1.0/me", s.getMe)
+	s.mux.HandleFunc("GET /v1.0/me/messages", s.listMessages)
+	s.mux.HandleFunc("POST /v1.0/me/messages", s.createMessage)
+	s.mux.HandleFunc("GET /v1.0/me/messages/{id}", s.getMessage)
+	s.mux.HandleFunc("PATCH /v1.0/me/messages/{id}", s.updateMessage)
+	s.mux.HandleFunc("DELETE /v1.0/me/messages/{id}", s.deleteMessage)
+	s.mux.HandleFunc("GET /v1.0/me/mailFolders/{folderId}/messages/delta()", s.messagesDelta)
+	s.mux.HandleFunc("POST /v1.0/me/messages/{id}/attachments", s.createAttachment)
+	s.mux.HandleFunc("POST /v1.0/me/messages/{id}/attachments/createUploadSession", s.createAttachmentUploadSession)
+	s.mux.HandleFunc("GET /v1.0/me/calendarView", s.calendarView)
+	s.mux.HandleFunc("GET /v1.0/me/events", s.listEvents)
+	s.mux.HandleFunc("POST /v1.0/me/events", s.createEvent)
+	s.mux.HandleFunc("POST /v1.0/me/events/{id}/attachments", s.createAttachment)
+	s.mux.HandleFunc("POST /v1.0/me/events/{id}/attachments/createUploadSession", s.createAttachmentUploadSession)
+	s.mux.HandleFunc("GET /v1.0/me/calendars", s.listCalendars)
+	s.mux.HandleFunc("POST /v1.0/me/calendars", s.createCalendar)
+	s.mux.HandleFunc("GET /v1.0/groups", s.listGroups)
+	s.mux.HandleFunc("PATCH /v1.0/teams/{id}", s.updateTeam)
+	s.mux.HandleFunc("GET /v1.0/me/drive", s.getDrive)
+	s.mux.HandleFunc("GET /v1.0/sites/{siteId}/drive", s.getSiteDrive)
+	s.mux.HandleFunc("GET /v1.0/sites/{siteId}/drives", s.listSiteDrives)
+	s.mux.HandleFunc("GET /v1.0/drives/{driveId}/items/{itemId}", s.getDriveItem)
+	s.mux.HandleFunc("POST /v1.0/drives/{driveId}/items/{itemId}/children", s.createDriveItemChild)
+	s.mux.HandleFunc("GET /v1.0/drives/{driveId}/items/{itemId}/content", s.getDriveItemContent)
+	s.mux.HandleFunc("PUT /v1.0/drives/{driveId}/items/{itemId}/content", s.putDriveItemContent)
+	s.mux.HandleFunc("POST /v1.0/drives/{driveId}/items/{itemId}/createUploadSession", s.createDriveItemUploadSession)
+	s.mux.HandleFunc("POST /v1.0/$batch", s.batch)
+	s.mux.HandleFunc("GET /upload/{id}", s.getUploadSession)
+	s.mux.HandleFunc("PUT /upload/{id}", s.uploadRange)
+	s.mux.HandleFunc("DELETE /upload/{id}", s.deleteUploadSession)
+	s.mux.HandleFunc("GET /download/{id}", s.downloadDriveItem)
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "Request_UnsupportedQuery",
+			fmt.Sprintf("%s %s is not supported by the mock server", r.Method, r.URL.Path))
+	})
+}
+
+func (s *Server) seed() {
+	s.user = map[string]any{
+		"id":                "mock-user-id",
+		"displayName":       "Megan Bowen",
+		"jobTitle":          "Marketing Manager",
+		"mail":              "megan@contoso.com",
+		"userPrincipalName": "megan@contoso.com",
+	}
+
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i := 1; i <= 30; i++ {
+		subject := fmt.Sprintf("Message %d", i)
+		if i == 7 {
+			subject = "Hello world"
+		}
+		s.messages = append(s.messages, map[string]any{
+			"id":               s.newId("message"),
+			"subject":          subject,
+			"receivedDateTime": start.Add(time.Duration(i) * time.Hour).Format(time.RFC3339),
+			"isDraft":          false,
+			"body": map[string]any{
+				"contentType": "text",
+				"content":     "Body of " + subject,
+			},
+			"sender": map[string]any{
+				"emailAddress": map[string]any{
+					"name":    "Alex Wilber",
+					"address": "alex@contoso.com",
+				},
+			},
+		})
+	}
+
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for i, subject := range []string{"Standup", "Design review"} {
+		eventStart := midnight.Add(time.Duration(10+i*3) * time.Hour)
+		s.events = append(s.events, newEvent(s.newId("event"), subject, eventStart, eventStart.Add(time.Hour)))
+	}
+
+	s.calendars = append(s.calendars, map[string]any{
+		"id":   s.newId("calendar"),
+		"name": "Calendar",
+	})
+
+	teamId := s.newId("group")
+	s.groups = []map[string]any{
+		{
+			"id":                          teamId,
+			"displayName":                 "Mark 8 Project Team",
+			"resourceProvisioningOptions": []any{"Team"},
+			"groupTypes":                  []any{"Unified"},
+			"mailEnabled":                 true,
+			"securityEnabled":             false,
+		},
+		{
+			"id":                          s.newId("group"),
+			"displayName":                 "Sales and Marketing",
+			"resourceProvisioningOptions": []any{},
+			"groupTypes":                  []any{"Unified"},
+			"mailEnabled":                 true,
+			"securityEnabled":             false,
+		},
+	}
+	s.teams[teamId] = map[string]any{
+		"id":          teamId,
+		"displayName": "Mark 8 Project Team",
+	}
+}
+
+func newEvent(id string, subject string, start time.Time, end time.Time) map[string]any {
+	return map[string]any{
+		"id":      id,
+		"subject": subject,
+		"start": map[string]any{
+			"dateTime": start.Format(graphDateTimeFormat),
+			"timeZone": "UTC",
+		},
+		"end": map[string]any{
+			"dateTime": end.Format(graphDateTimeFormat),
+			"timeZone": "UTC",
+		},
+	}
+}
+
+// newId returns a unique ID with the given prefix.
+// Callers must hold s.mu or be running before the server starts.
+func (s *Server) newId(prefix string) string {
+	s.nextId++
+	return fmt.Sprintf("%s-%04d", prefix, s.nextId)
+}
+
+// decompress undoes the gzip request compression applied
+// by the kiota compression handler.
+func decompress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+			reader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "BadRequest", "invalid gzip body")
+				return
+			}
+			r.Body = io.NopCloser(reader)
+			r.Header.Del("Content-Encoding")
+			r.ContentLength = -1
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJson(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError writes an OData error response.
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	writeJson(w, status, map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
+
+func readJson(r *http.Request) (map[string]any, error) {
+	body := map[string]any{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// copyMap returns a deep copy of a JSON object.
+func copyMap(value map[string]any) map[string]any {
+	data, _ := json.Marshal(value)
+	copied := map[string]any{}
+	json.Unmarshal(data, &copied)
+	return copied
+}
@@ -0,0 +1,297 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package graphmock
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"sdksnippets/quickxorhash"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// uploadSessionLifetime matches the expiration Graph gives new sessions.
+const uploadSessionLifetime = 24 * time.Hour
+
+var contentRangeExpression = regexp.MustCompile(`^bytes (\d+)-(\d+)/(\d+)$`)
+
+type driveItem struct {
+	id           string
+	name         string
+	path         string
+	folder       bool
+	content      []byte
+	lastModified time.Time
+}
+
+func (item *driveItem) toJson() map[string]any {
+	if item.folder {
+		return map[string]any{
+			"id":                   item.id,
+			"name":                 item.name,
+			"size":                 0,
+			"lastModifiedDateTime": item.lastModified.Format(time.RFC3339),
+			"folder":               map[string]any{},
+		}
+	}
+
+	sha1Hash := sha1.Sum(item.content)
+	sha256Hash := sha256.Sum256(item.content)
+	quickXorHash := quickxorhash.Sum(item.content)
+
+	return map[string]any{
+		"id":                   item.id,
+		"name":                 item.name,
+		"cTag":                 fmt.Sprintf(`"c:{%s},%s"`, item.id, hex.EncodeToString(sha1Hash[:4])),
+		"size":                 len(item.content),
+		"lastModifiedDateTime": item.lastModified.Format(time.RFC3339),
+		"file": map[string]any{
+			"mimeType": "application/octet-stream",
+			"hashes": map[string]any{
+				"quickXorHash": quickxorhash.EncodeToString(quickXorHash[:]),
+				"sha1Hash":     strings.ToUpper(hex.EncodeToString(sha1Hash[:])),
+				"sha256Hash":   strings.ToUpper(hex.EncodeToString(sha256Hash[:])),
+			},
+		},
+	}
+}
+
+// uploadSession tracks one createUploadSession. Ranges must be sent in
+// order, so the next expected range always starts at len(content).
+type uploadSession struct {
+	id         string
+	expiration time.Time
+	// size is learned from the first Content-Range header
+	size    int64
+	content []byte
+
+	// Either drivePath or parentPath identifies the target
+	drivePath string
+	fileName  string
+	// parentPath is the message or event an attachment is uploaded
+	// to, and attachment holds the properties of the attachment
+	parentPath string
+	attachment map[string]any
+}
+
+func (session *uploadSession) toJson(uploadUrl string) map[string]any {
+	return map[string]any{
+		"uploadUrl":          uploadUrl,
+		"expirationDateTime": session.expiration.Format(time.RFC3339),
+		"nextExpectedRanges": []any{fmt.Sprintf("%d-", len(session.content))},
+	}
+}
+
+func (s *Server) uploadUrl(session *uploadSession) string {
+	return s.URL + "/upload/" + session.id
+}
+
+func (s *Server) createDriveItemUploadSession(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("driveId") != s.driveId {
+		writeError(w, http.StatusNotFound, "itemNotFound", "Drive not found")
+		return
+	}
+
+	body, err := readJson(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "invalid upload session body")
+		return
+	}
+	conflictBehavior, _ := propertyValue(body, "item/@microsoft.graph.conflictBehavior").(string)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	itemPath, ok := s.itemPath(r.PathValue("itemId"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalidRequest",
+			"Only root:/{path}: and {parent-id}:/{name}: item addressing is supported")
+		return
+	}
+
+	if _, exists := s.driveItems[itemPath]; exists {
+		switch conflictBehavior {
+		case "fail":
+			writeError(w, http.StatusConflict, "nameAlreadyExists", "The specified item name already exists")
+			return
+		case "rename":
+			itemPath = s.availablePath(itemPath)
+		}
+	}
+
+	session := &uploadSession{
+		id:         s.newId("session"),
+		expiration: time.Now().Add(uploadSessionLifetime).UTC(),
+		drivePath:  itemPath,
+		fileName:   path.Base(itemPath),
+	}
+	s.uploadSessions[session.id] = session
+
+	writeJson(w, http.StatusOK, session.toJson(s.uploadUrl(session)))
+}
+
+func (s *Server) createAttachmentUploadSession(w http.ResponseWriter, r *http.Request) {
+	body, err := readJson(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "ErrorInvalidRequest", "invalid upload session body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parentPath, ok := s.attachmentParent(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "ErrorItemNotFound", "The specified object was not found in the store.")
+		return
+	}
+
+	item, _ := body["AttachmentItem"].(map[string]any)
+	if item == nil {
+		item, _ = body["attachmentItem"].(map[string]any)
+	}
+	name, _ := item["name"].(string)
+
+	session := &uploadSession{
+		id:         s.newId("session"),
+		expiration: time.Now().Add(uploadSessionLifetime).UTC(),
+		fileName:   name,
+		parentPath: parentPath,
+		attachment: map[string]any{
+			"name":        name,
+			"contentType": item["contentType"],
+			"isInline":    item["isInline"] == true,
+			"contentId":   item["contentId"],
+		},
+	}
+	s.uploadSessions[session.id] = session
+
+	writeJson(w, http.StatusCreated, session.toJson(s.uploadUrl(session)))
+}
+
+func (s *Server) getUploadSession(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.uploadSessions[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "itemNotFound", "The upload session was not found")
+		return
+	}
+
+	writeJson(w, http.StatusOK, session.toJson(s.uploadUrl(session)))
+}
+
+func (s *Server) deleteUploadSession(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.uploadSessions[r.PathValue("id")]; !ok {
+		writeError(w, http.StatusNotFound, "itemNotFound", "The upload session was not found")
+		return
+	}
+
+	delete(s.uploadSessions, r.PathValue("id"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) uploadRange(w http.ResponseWriter, r *http.Request) {
+	match := contentRangeExpression.FindStringSubmatch(r.Header.Get("Content-Range"))
+	if match == nil {
+		writeError(w, http.StatusBadRequest, "invalidRange", "Missing or invalid Content-Range header")
+		return
+	}
+	start, _ := strconv.ParseInt(match[1], 10, 64)
+	end, _ := strconv.ParseInt(match[2], 10, 64)
+	total, _ := strconv.ParseInt(match[3], 10, 64)
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "could not read request body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.uploadSessions[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "itemNotFound", "The upload session was not found")
+		return
+	}
+	if time.Now().After(session.expiration) {
+		delete(s.uploadSessions, session.id)
+		writeError(w, http.StatusNotFound, "itemNotFound", "The upload session has expired")
+		return
+	}
+
+	if session.size == 0 {
+		session.size = total
+	}
+	if total != session.size || end < start || end >= total || int64(len(data)) != end-start+1 {
+		writeError(w, http.StatusBadRequest, "invalidRange", "Content-Range does not match the session or the body")
+		return
+	}
+	if start != int64(len(session.content)) {
+		writeError(w, http.StatusRequestedRangeNotSatisfiable, "invalidRange",
+			fmt.Sprintf("The uploaded fragment does not start at the next expected position %d", len(session.content)))
+		return
+	}
+
+	session.content = append(session.content, data...)
+	if int64(len(session.content)) < session.size {
+		writeJson(w, http.StatusAccepted, session.toJson(s.uploadUrl(session)))
+		return
+	}
+
+	// Upload complete
+	delete(s.uploadSessions, session.id)
+
+	if session.parentPath != "" {
+		attachment := session.attachment
+		attachment["size"] = len(session.content)
+		attachment["contentBytes"] = base64.StdEncoding.EncodeToString(session.content)
+		// Graph returns no body, only the URL of the attachment
+		attachmentId := s.addAttachment(path.Base(session.parentPath), attachment)
+		w.Header().Set("Location", s.BaseUrl()+session.parentPath+"/attachments/"+attachmentId)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	item, exists := s.driveItems[session.drivePath]
+	if !exists {
+		item = &driveItem{
+			id:   s.newId("item"),
+			name: session.fileName,
+			path: session.drivePath,
+		}
+		s.driveItems[session.drivePath] = item
+	}
+	item.content = session.content
+	item.lastModified = time.Now().UTC()
+
+	writeJson(w, http.StatusCreated, item.toJson())
+}
+
+// DriveItemContent returns the content uploaded to the given path,
+// relative to the drive root.
+func (s *Server) DriveItemContent(itemPath string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.driveItems[itemPath]
+	if !ok {
+		return nil, false
+	}
+
+	return item.content, true
+}
@@ -5,20 +5,22 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sdksnippets/bandwidth"
 	"sdksnippets/graphhelper"
+	"sdksnippets/progress"
 	"sdksnippets/snippets"
+	"sdksnippets/transfer"
 
 	"github.com/joho/godotenv"
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
 )
 
 func main() {
-	fmt.Println("Microsoft Graph Go SDK Snippets")
-	fmt.Println()
-
-	logger := log.New(os.Stdout, "graph-debug: ", log.Ldate|log.Ltime)
+	logger := log.New(os.Stderr, "graph-debug: ", log.Ldate|log.Ltime)
 
 	godotenv.Load(".env.local")
 	err := godotenv.Load()
@@ -26,17 +28,54 @@ func main() {
 		log.Fatal("Error loading .env")
 	}
 
+	// With no arguments, fall back to the interactive menu
+	if len(os.Args) < 2 {
+		runInteractive(logger)
+		return
+	}
+
+	err = runCommand(os.Args[1:], logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+
+		var usageErr *usageError
+		if errors.As(err, &usageErr) {
+			fmt.Fprintln(os.Stderr)
+			printUsage(os.Stderr)
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+func newUserClient(logger *log.Logger) (*graph.GraphServiceClient, error) {
 	userClient, err := graphhelper.NewUserGraphServiceClient(logger)
 	if err != nil {
-		log.Fatalf("Error creating user client: %v\n", err)
+		return nil, fmt.Errorf("creating user client: %w", err)
 	}
 
-	user, err := userClient.Me().Get(context.Background(), nil)
+	return userClient, nil
+}
+
+func runInteractive(logger *log.Logger) {
+	fmt.Println("Microsoft Graph Go SDK Snippets")
+	fmt.Println()
+
+	userClient, err := newUserClient(logger)
 	if err != nil {
-		log.Fatalf("Error getting user: %v\n", err)
+		log.Fatalf("Error %v\n", err)
 	}
 
-	fmt.Printf("Hello %s!\n", *user.GetDisplayName())
+	// App-only credentials have no signed-in user to greet
+	authMode, _ := graphhelper.AuthModeFromEnvironment()
+	if authMode.IsDelegated() {
+		user, err := userClient.Me().Get(context.Background(), nil)
+		if err != nil {
+			log.Fatalf("Error getting user: %v\n", err)
+		}
+
+		fmt.Printf("Hello %s!\n", *user.GetDisplayName())
+	}
 
 	var choice int64 = -1
 
@@ -53,23 +92,33 @@ func main() {
 			choice = -1
 		}
 
+		err = nil
 		switch choice {
 		case 0:
 			// Exit the program
 			fmt.Println("Goodbye...")
 		case 1:
-			snippets.RunBatchSamples(userClient)
+			err = snippets.RunBatchSamples(userClient)
 		case 2:
-			snippets.RunRequestSamples(userClient)
+			err = snippets.RunRequestSamples(userClient)
 		case 3:
 			largeFile := os.Getenv("LARGE_FILE_PATH")
-			snippets.RunUploadSamples(userClient, largeFile)
+			var limiter *bandwidth.Limiter
+			limiter, err = bandwidth.ParseLimit(os.Getenv("BANDWIDTH_LIMIT"))
+			if err == nil {
+				err = snippets.RunUploadSamples(userClient, largeFile, transfer.Destination{Path: defaultUploadPath},
+					progress.NewBar(os.Stderr), limiter)
+			}
 		case 4:
-			snippets.RunPagingSamples(userClient)
+			err = snippets.RunPagingSamples(userClient, defaultPageSize)
 		default:
 			fmt.Println("Invalid choice! Please try again.")
 		}
 
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+
 		if choice == 0 {
 			break
 		}
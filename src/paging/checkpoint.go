@@ -0,0 +1,335 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package paging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoft/kiota-abstractions-go/serialization"
+	khttp "github.com/microsoft/kiota-http-go"
+	graphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
+)
+
+// Checkpoint is a position in a paged collection.
+type Checkpoint struct {
+	// PageLink is the URL of the page holding the next item: the URL
+	// of the first request or an @odata.nextLink.
+	PageLink string `json:"pageLink"`
+	// Offset is the index of the next item on that page.
+	Offset int `json:"offset"`
+}
+
+// CheckpointStore saves checkpoints under a key chosen by the caller.
+type CheckpointStore interface {
+	// Load returns the checkpoint saved under key, if any.
+	Load(key string) (Checkpoint, bool, error)
+	Save(key string, checkpoint Checkpoint) error
+	// Delete removes the checkpoint saved under key, if any.
+	Delete(key string) error
+}
+
+// WithCheckpointInterval makes Resumable save a checkpoint every n
+// items instead of before every item. After a restart up to n-1 items
+// are then returned again.
+func WithCheckpointInterval(n int) Option {
+	return func(o *options) {
+		o.checkpointInterval = n
+	}
+}
+
+// Resumable iterates like FromRequest, and saves its position to store
+// under key so that a later run, even in a new process, can continue
+// where it stopped. If store has a checkpoint for key, request is not
+// sent; iteration resumes from the checkpoint's page and item instead,
+// still using the headers and options of request.
+//
+// The checkpoint is saved before each item is returned, so an item that
+// was returned when the loop was broken or the process ended is returned
+// again on resume. Once every item has been returned the checkpoint is
+// deleted, and the next run starts over. The checkpoint's offset only
+// skips items of its own page, so if that page is now shorter, the
+// resumed run continues from the start of the next page.
+//
+// WithPrefetch is not supported, and makes Resumable return an error.
+func Resumable[T any](ctx context.Context, client Client, store CheckpointStore, key string,
+	request *abstractions.RequestInformation, factory serialization.ParsableFactory, opts ...Option) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		if newOptions(opts).prefetch > 0 {
+			yield(zero, errors.New("paging: Resumable does not support WithPrefetch"))
+			return
+		}
+
+		checkpoint, found, err := store.Load(key)
+		if err != nil {
+			yield(zero, fmt.Errorf("loading checkpoint %q: %w", key, err))
+			return
+		}
+
+		var firstPage serialization.Parsable
+		if found {
+			firstPage, err = getPage(ctx, client, checkpoint.PageLink, request.Headers, request.GetRequestOptions(), factory)
+		} else {
+			checkpoint, err = firstCheckpoint(request)
+			if err == nil {
+				firstPage, err = client.GetAdapter().Send(ctx, request, factory, errorMapping)
+			}
+		}
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+
+		allOpts := append([]Option{
+			WithHeaders(request.Headers),
+			WithRequestOptions(request.GetRequestOptions()...),
+		}, opts...)
+		pageIterator, err := newPageIterator[T](client, firstPage, factory, allOpts)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+
+		interval := max(newOptions(opts).checkpointInterval, 1)
+
+		// A new page has started when the next link changes
+		nextLink := stringValue(pageIterator.GetOdataNextLink())
+		skip := checkpoint.Offset
+		sinceSave := 0
+		stopped := false
+		var saveErr error
+
+		err = pageIterator.Iterate(ctx, func(item T) bool {
+			if link := stringValue(pageIterator.GetOdataNextLink()); link != nextLink {
+				checkpoint = Checkpoint{PageLink: nextLink}
+				nextLink = link
+				// The offset counts items of the checkpoint's page only
+				skip = 0
+			}
+			if skip > 0 {
+				skip--
+				return true
+			}
+
+			if sinceSave%interval == 0 {
+				saveErr = store.Save(key, checkpoint)
+				if saveErr != nil {
+					return false
+				}
+			}
+			sinceSave++
+
+			if !yield(item, nil) {
+				stopped = true
+				return false
+			}
+			checkpoint.Offset++
+			return true
+		})
+
+		switch {
+		case stopped:
+		case saveErr != nil:
+			yield(zero, fmt.Errorf("saving checkpoint %q: %w", key, saveErr))
+		case err != nil:
+			yield(zero, err)
+		default:
+			err = store.Delete(key)
+			if err != nil {
+				yield(zero, fmt.Errorf("deleting checkpoint %q: %w", key, err))
+			}
+		}
+	}
+}
+
+func firstCheckpoint(request *abstractions.RequestInformation) (Checkpoint, error) {
+	uri, err := request.GetUri()
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	// Store /me rather than the placeholder the request builders use
+	return Checkpoint{
+		PageLink: khttp.ReplacePathTokens(uri.String(), graphcore.ReplacementPairs),
+	}, nil
+}
+
+// getPage sends a GET to link with headers and requestOptions
+func getPage(ctx context.Context, client Client, link string, headers *abstractions.RequestHeaders,
+	requestOptions []abstractions.RequestOption, factory serialization.ParsableFactory) (serialization.Parsable, error) {
+	pageUrl, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("parsing page link: %w", err)
+	}
+
+	pageRequest := abstractions.NewRequestInformation()
+	pageRequest.Method = abstractions.GET
+	pageRequest.SetUri(*pageUrl)
+	if headers != nil {
+		pageRequest.Headers.AddAll(headers)
+	}
+	pageRequest.AddRequestOptions(requestOptions)
+
+	return client.GetAdapter().Send(ctx, pageRequest, factory, errorMapping)
+}
+
+func stringValue(link *string) string {
+	if link == nil {
+		return ""
+	}
+
+	return *link
+}
+
+// MemoryCheckpointStore keeps checkpoints for the life of the process.
+type MemoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]Checkpoint
+}
+
+// NewMemoryCheckpointStore creates an empty MemoryCheckpointStore.
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: map[string]Checkpoint{}}
+}
+
+func (s *MemoryCheckpointStore) Load(key string) (Checkpoint, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoint, ok := s.checkpoints[key]
+	return checkpoint, ok, nil
+}
+
+func (s *MemoryCheckpointStore) Save(key string, checkpoint Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checkpoints[key] = checkpoint
+	return nil
+}
+
+func (s *MemoryCheckpointStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.checkpoints, key)
+	return nil
+}
+
+// FileCheckpointStore keeps every checkpoint in one JSON file. Next links
+// can carry skip tokens, so the file is readable only by its owner.
+type FileCheckpointStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileCheckpointStore creates a store backed by the file at path,
+// which is created when the first checkpoint is saved.
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{path: path}
+}
+
+func (s *FileCheckpointStore) Load(key string) (Checkpoint, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.read()
+	if err != nil {
+		return Checkpoint{}, false, err
+	}
+
+	checkpoint, ok := checkpoints[key]
+	return checkpoint, ok, nil
+}
+
+func (s *FileCheckpointStore) Save(key string, checkpoint Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	checkpoints[key] = checkpoint
+	return s.write(checkpoints)
+}
+
+func (s *FileCheckpointStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := checkpoints[key]; !ok {
+		return nil
+	}
+
+	delete(checkpoints, key)
+	return s.write(checkpoints)
+}
+
+func (s *FileCheckpointStore) read() (map[string]Checkpoint, error) {
+	checkpoints := map[string]Checkpoint{}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return checkpoints, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &checkpoints)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", s.path, err)
+	}
+
+	return checkpoints, nil
+}
+
+// write replaces the file in one step, so a crash
+// never leaves a partly written file behind
+func (s *FileCheckpointStore) write(checkpoints map[string]Checkpoint) error {
+	data, err := json.MarshalIndent(checkpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	// CreateTemp creates the file with mode 0600
+	file, err := os.CreateTemp(dir, filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), s.path)
+}
@@ -0,0 +1,179 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package paging_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sdksnippets/paging"
+	"testing"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+func TestResumableContinuesFromSameItem(t *testing.T) {
+	graphClient, _, log := newMockClient(t)
+	store := paging.NewMemoryCheckpointStore()
+
+	// Stop on the 10th item, which is on the second page
+	var ids []string
+	for message, err := range paging.Resumable[*models.Message](context.Background(), graphClient, store, "messages",
+		messagesRequest(t, graphClient, 7), models.CreateMessageCollectionResponseFromDiscriminatorValue) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) == 9 {
+			break
+		}
+		ids = append(ids, *message.GetId())
+	}
+
+	checkpoint, found, _ := store.Load("messages")
+	if !found || checkpoint.Offset != 2 {
+		t.Fatalf("got checkpoint %+v, found %v, want offset 2", checkpoint, found)
+	}
+
+	// The resumed run starts at the item the first run stopped on
+	requestsBefore := len(log.requests)
+	for message, err := range paging.Resumable[*models.Message](context.Background(), graphClient, store, "messages",
+		messagesRequest(t, graphClient, 7), models.CreateMessageCollectionResponseFromDiscriminatorValue) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, *message.GetId())
+	}
+
+	if len(ids) != 30 {
+		t.Fatalf("got %d messages, want 30", len(ids))
+	}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			t.Errorf("message %s returned twice", id)
+		}
+		seen[id] = true
+	}
+
+	resumed := log.requests[requestsBefore]
+	if resumed.URL.Query().Get("$skip") != "7" {
+		t.Errorf("resumed from %s, want the second page", resumed.URL)
+	}
+	if resumed.Header.Get("Prefer") == "" {
+		t.Error("resumed request is missing the Prefer header")
+	}
+
+	if _, found, _ := store.Load("messages"); found {
+		t.Error("checkpoint was not deleted after the last item")
+	}
+}
+
+func TestResumableSkipsOnlyOnCheckpointPage(t *testing.T) {
+	graphClient, server, _ := newMockClient(t)
+	store := paging.NewMemoryCheckpointStore()
+
+	// Saved when the first page held more than the 3 items it has now
+	err := store.Save("messages", paging.Checkpoint{PageLink: server.BaseUrl() + "/me/messages?%24top=3", Offset: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for message, err := range paging.Resumable[*models.Message](context.Background(), graphClient, store, "messages",
+		messagesRequest(t, graphClient, 3), models.CreateMessageCollectionResponseFromDiscriminatorValue) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, *message.GetId())
+	}
+
+	// The rest of the first page is skipped, and all of the second returned
+	if len(ids) != 27 {
+		t.Errorf("got %d messages, want 27", len(ids))
+	}
+}
+
+func TestResumableRejectsPrefetch(t *testing.T) {
+	graphClient, _, _ := newMockClient(t)
+
+	var firstErr error
+	for _, err := range paging.Resumable[*models.Message](context.Background(), graphClient,
+		paging.NewMemoryCheckpointStore(), "messages", messagesRequest(t, graphClient, 10),
+		models.CreateMessageCollectionResponseFromDiscriminatorValue, paging.WithPrefetch(2)) {
+		firstErr = err
+		break
+	}
+	if firstErr == nil {
+		t.Error("WithPrefetch was accepted")
+	}
+}
+
+func TestResumableSavesEveryInterval(t *testing.T) {
+	graphClient, _, _ := newMockClient(t)
+	store := paging.NewMemoryCheckpointStore()
+
+	count := 0
+	for _, err := range paging.Resumable[*models.Message](context.Background(), graphClient, store, "messages",
+		messagesRequest(t, graphClient, 10), models.CreateMessageCollectionResponseFromDiscriminatorValue,
+		paging.WithCheckpointInterval(4)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count == 6 {
+			break
+		}
+		count++
+	}
+
+	// Saved before items 0 and 4, so items 4 and 5 are returned again
+	checkpoint, _, _ := store.Load("messages")
+	if checkpoint.Offset != 4 {
+		t.Errorf("got offset %d, want 4", checkpoint.Offset)
+	}
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "checkpoints.json")
+	store := paging.NewFileCheckpointStore(path)
+
+	_, found, err := store.Load("messages")
+	if err != nil || found {
+		t.Fatalf("Load on a missing file: found %v, err %v", found, err)
+	}
+
+	want := paging.Checkpoint{PageLink: "https://graph.microsoft.com/v1.0/me/messages?$skiptoken=abc", Offset: 3}
+	err = store.Save("messages", want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Save("events", paging.Checkpoint{PageLink: "https://graph.microsoft.com/v1.0/me/events"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("got mode %v, want 0600", info.Mode().Perm())
+	}
+
+	// A new store reads what the first one saved
+	got, found, err := paging.NewFileCheckpointStore(path).Load("messages")
+	if err != nil || !found || got != want {
+		t.Errorf("got %+v, found %v, err %v, want %+v", got, found, err, want)
+	}
+
+	err = store.Delete("messages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := store.Load("messages"); found {
+		t.Error("checkpoint still present after Delete")
+	}
+	if _, found, _ := store.Load("events"); !found {
+		t.Error("Delete removed another key")
+	}
+}
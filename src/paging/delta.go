@@ -0,0 +1,224 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package paging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoft/kiota-abstractions-go/serialization"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+// ChangeKind says how an item in a delta response changed.
+type ChangeKind int
+
+const (
+	// Added is an item returned by a full enumeration: the first
+	// round of a sync, or the round after a resync.
+	Added ChangeKind = iota
+	// Updated is an item returned by a later round. Graph returns
+	// created and changed items alike, so it may be new.
+	Updated
+	// Deleted is an item removed from the collection. Only its
+	// ID is set.
+	Deleted
+	// Resync is sent, without an item, before a full enumeration that
+	// replaces an expired delta link. Items not returned again by that
+	// enumeration were deleted while the link was expired.
+	Resync
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Updated:
+		return "updated"
+	case Deleted:
+		return "deleted"
+	case Resync:
+		return "resync"
+	}
+
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// DeltaItem is implemented by the models returned by delta queries.
+type DeltaItem interface {
+	GetId() *string
+	GetAdditionalData() map[string]any
+}
+
+// Change is one item returned by a delta query.
+type Change[T DeltaItem] struct {
+	Kind ChangeKind
+	Id   string
+	Item T
+	// RemovedReason is the reason of the @removed annotation of a
+	// Deleted item: "deleted" if it is gone for good, or "changed" if
+	// it left the collection, for example by moving to another folder.
+	RemovedReason string
+}
+
+// DeltaResult counts the changes handled by Sync.
+type DeltaResult struct {
+	Added   int
+	Updated int
+	Deleted int
+	// Resynced is true if the saved delta link had expired
+	// and the collection was enumerated again
+	Resynced bool
+}
+
+// driveItemDeletion is implemented by models.DriveItem, which
+// reports deletions with a deleted facet instead of @removed
+type driveItemDeletion interface {
+	GetDeleted() models.Deletedable
+}
+
+// Sync runs one round of a delta query and calls handler with every
+// change it returns. request is the delta request, such as one built by
+// Me().MailFolders().ByMailFolderId("inbox").Messages().Delta(), and
+// factory creates its response type.
+//
+// The @odata.deltaLink that ends the round is saved to store under key,
+// and the next call to Sync starts from it to get only the changes made
+// since. Without a saved link, request is sent and every item is returned
+// as Added. If Graph responds that the saved link has expired with 410
+// Gone, the handler receives a Resync change and the collection is
+// enumerated again from request.
+//
+// An error from handler ends the round without saving, so the next call
+// returns the same changes again.
+func Sync[T DeltaItem](ctx context.Context, client Client, store CheckpointStore, key string,
+	request *abstractions.RequestInformation, factory serialization.ParsableFactory,
+	handler func(change Change[T]) error, opts ...Option) (DeltaResult, error) {
+	var result DeltaResult
+
+	checkpoint, found, err := store.Load(key)
+	if err != nil {
+		return result, fmt.Errorf("loading delta link %q: %w", key, err)
+	}
+
+	deltaLink, err := syncRound(ctx, client, checkpoint.PageLink, request, factory, handler, &result, opts)
+	if isGone(err) && found {
+		result = DeltaResult{Resynced: true}
+		err = handler(Change[T]{Kind: Resync})
+		if err != nil {
+			return result, err
+		}
+		deltaLink, err = syncRound(ctx, client, "", request, factory, handler, &result, opts)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	err = store.Save(key, Checkpoint{PageLink: deltaLink})
+	if err != nil {
+		return result, fmt.Errorf("saving delta link %q: %w", key, err)
+	}
+
+	return result, nil
+}
+
+// syncRound follows the pages of one round from deltaLink, or from
+// request if deltaLink is empty, and returns the link that ends it
+func syncRound[T DeltaItem](ctx context.Context, client Client, deltaLink string,
+	request *abstractions.RequestInformation, factory serialization.ParsableFactory,
+	handler func(change Change[T]) error, result *DeltaResult, opts []Option) (string, error) {
+	var firstPage serialization.Parsable
+	var err error
+	if deltaLink != "" {
+		firstPage, err = getPage(ctx, client, deltaLink, request.Headers, request.GetRequestOptions(), factory)
+	} else {
+		firstPage, err = client.GetAdapter().Send(ctx, request, factory, errorMapping)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	allOpts := append([]Option{
+		WithHeaders(request.Headers),
+		WithRequestOptions(request.GetRequestOptions()...),
+	}, opts...)
+	pageIterator, err := newPageIterator[T](client, firstPage, factory, allOpts)
+	if err != nil {
+		return "", err
+	}
+
+	var handlerErr error
+	err = pageIterator.Iterate(ctx, func(item T) bool {
+		change := newChange(item, deltaLink == "")
+		handlerErr = handler(change)
+		if handlerErr != nil {
+			handlerErr = fmt.Errorf("handling %s item %s: %w", change.Kind, change.Id, handlerErr)
+			return false
+		}
+
+		switch change.Kind {
+		case Added:
+			result.Added++
+		case Updated:
+			result.Updated++
+		case Deleted:
+			result.Deleted++
+		}
+		return true
+	})
+	if handlerErr != nil {
+		return "", handlerErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	nextDeltaLink := stringValue(pageIterator.GetOdataDeltaLink())
+	if nextDeltaLink == "" {
+		return "", errors.New("delta response ended without an @odata.deltaLink")
+	}
+
+	return nextDeltaLink, nil
+}
+
+func newChange[T DeltaItem](item T, full bool) Change[T] {
+	change := Change[T]{Kind: Updated, Id: stringValue(item.GetId()), Item: item}
+	if full {
+		change.Kind = Added
+	}
+
+	if removed, ok := item.GetAdditionalData()["@removed"]; ok {
+		change.Kind = Deleted
+		change.RemovedReason = removedReason(removed)
+	} else if deletable, ok := any(item).(driveItemDeletion); ok && deletable.GetDeleted() != nil {
+		change.Kind = Deleted
+		change.RemovedReason = "deleted"
+	}
+
+	return change
+}
+
+// removedReason reads the reason from an @removed annotation,
+// which the JSON parser stores as a map of string pointers
+func removedReason(removed any) string {
+	annotation, _ := removed.(map[string]any)
+	switch reason := annotation["reason"].(type) {
+	case *string:
+		return stringValue(reason)
+	case string:
+		return reason
+	}
+
+	return ""
+}
+
+// isGone reports whether err is a 410 Gone response, which Graph
+// returns when a delta link is too old to continue from
+func isGone(err error) bool {
+	var apiError abstractions.ApiErrorable
+	return errors.As(err, &apiError) && apiError.GetStatusCode() == http.StatusGone
+}
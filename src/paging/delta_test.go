@@ -0,0 +1,135 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package paging_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sdksnippets/paging"
+	"testing"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+)
+
+// deltaRequest creates GET /me/mailFolders/inbox/messages/delta with a page size
+func deltaRequest(t *testing.T, graphClient *graph.GraphServiceClient) *abstractions.RequestInformation {
+	t.Helper()
+
+	headers := abstractions.NewRequestHeaders()
+	headers.Add("Prefer", "odata.maxpagesize=7")
+	request, err := graphClient.Me().MailFolders().ByMailFolderId("inbox").Messages().Delta().
+		ToGetRequestInformation(context.Background(),
+			&users.ItemMailFoldersItemMessagesDeltaRequestBuilderGetRequestConfiguration{
+				Headers: headers,
+			})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return request
+}
+
+// syncInbox runs one delta round and returns the changes by kind
+func syncInbox(t *testing.T, graphClient *graph.GraphServiceClient, store paging.CheckpointStore) (paging.DeltaResult, map[paging.ChangeKind][]paging.Change[models.Messageable]) {
+	t.Helper()
+
+	changes := map[paging.ChangeKind][]paging.Change[models.Messageable]{}
+	result, err := paging.Sync(context.Background(), graphClient, store, "inbox", deltaRequest(t, graphClient),
+		users.CreateItemMailFoldersItemMessagesDeltaGetResponseFromDiscriminatorValue,
+		func(change paging.Change[models.Messageable]) error {
+			changes[change.Kind] = append(changes[change.Kind], change)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result, changes
+}
+
+func TestSyncReturnsOnlyLaterChanges(t *testing.T) {
+	graphClient, _, log := newMockClient(t)
+	store := paging.NewMemoryCheckpointStore()
+
+	result, changes := syncInbox(t, graphClient, store)
+	if result.Added != 30 || len(changes[paging.Added]) != 30 {
+		t.Fatalf("first round: got %+v, want 30 added", result)
+	}
+	if len(log.requests) != 5 {
+		t.Errorf("first round sent %d requests, want 5 pages", len(log.requests))
+	}
+	checkpoint, _, _ := store.Load("inbox")
+	if checkpoint.PageLink == "" {
+		t.Fatal("delta link was not saved")
+	}
+
+	ctx := context.Background()
+	updated := changes[paging.Added][0].Id
+	deleted := changes[paging.Added][1].Id
+	subject := "Updated"
+	update := models.NewMessage()
+	update.SetSubject(&subject)
+	_, err := graphClient.Me().Messages().ByMessageId(updated).Patch(ctx, update, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = graphClient.Me().Messages().ByMessageId(deleted).Delete(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, changes = syncInbox(t, graphClient, store)
+	if result != (paging.DeltaResult{Updated: 1, Deleted: 1}) {
+		t.Fatalf("second round: got %+v, want 1 updated and 1 deleted", result)
+	}
+	if change := changes[paging.Updated][0]; change.Id != updated || *change.Item.GetSubject() != "Updated" {
+		t.Errorf("got update %s %q", change.Id, *change.Item.GetSubject())
+	}
+	if change := changes[paging.Deleted][0]; change.Id != deleted || change.RemovedReason != "deleted" {
+		t.Errorf("got deletion of %s for %q", change.Id, change.RemovedReason)
+	}
+
+	result, _ = syncInbox(t, graphClient, store)
+	if result != (paging.DeltaResult{}) {
+		t.Errorf("third round: got %+v, want no changes", result)
+	}
+}
+
+func TestSyncResyncsWhenDeltaLinkExpires(t *testing.T) {
+	graphClient, server, _ := newMockClient(t)
+	store := paging.NewMemoryCheckpointStore()
+
+	syncInbox(t, graphClient, store)
+	server.FailNext("/me/mailFolders/inbox/messages/delta()", 1, http.StatusGone, 0)
+
+	result, changes := syncInbox(t, graphClient, store)
+	if !result.Resynced || result.Added != 30 {
+		t.Errorf("got %+v, want a resync with 30 added", result)
+	}
+	if len(changes[paging.Resync]) != 1 {
+		t.Errorf("handler got %d resync changes, want 1", len(changes[paging.Resync]))
+	}
+}
+
+func TestSyncKeepsDeltaLinkWhenHandlerFails(t *testing.T) {
+	graphClient, _, _ := newMockClient(t)
+	store := paging.NewMemoryCheckpointStore()
+
+	handlerErr := errors.New("disk full")
+	_, err := paging.Sync(context.Background(), graphClient, store, "inbox", deltaRequest(t, graphClient),
+		users.CreateItemMailFoldersItemMessagesDeltaGetResponseFromDiscriminatorValue,
+		func(change paging.Change[models.Messageable]) error {
+			return handlerErr
+		})
+	if !errors.Is(err, handlerErr) {
+		t.Fatalf("got error %v, want the handler's error", err)
+	}
+	if _, found, _ := store.Load("inbox"); found {
+		t.Error("delta link was saved after the handler failed")
+	}
+}
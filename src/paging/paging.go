@@ -0,0 +1,144 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+// Package paging iterates over Microsoft Graph collections that span
+// several pages with range-over-func loops, and keeps collections in
+// sync with delta queries.
+package paging
+
+import (
+	"context"
+	"iter"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoft/kiota-abstractions-go/serialization"
+	graphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+)
+
+// Client is implemented by graph.GraphServiceClient and
+// graph.GraphBaseServiceClient.
+type Client interface {
+	GetAdapter() abstractions.RequestAdapter
+}
+
+// Option configures the requests for pages after the first, when they
+// are fetched, and how Resumable saves checkpoints.
+type Option func(*options)
+
+type options struct {
+	headers            *abstractions.RequestHeaders
+	requestOptions     []abstractions.RequestOption
+	checkpointInterval int
+	prefetch           int
+}
+
+// WithHeaders sends headers with every next page request. Use it to
+// repeat headers such as Prefer that were sent with the first request.
+func WithHeaders(headers *abstractions.RequestHeaders) Option {
+	return func(o *options) {
+		o.headers = headers
+	}
+}
+
+// WithRequestOptions adds requestOptions to every next page request.
+func WithRequestOptions(requestOptions ...abstractions.RequestOption) Option {
+	return func(o *options) {
+		o.requestOptions = requestOptions
+	}
+}
+
+// errorMapping parses Graph error responses, as the request builders do
+var errorMapping = abstractions.ErrorMappings{
+	"XXX": odataerrors.CreateODataErrorFromDiscriminatorValue,
+}
+
+func init() {
+	// Without a registered mapping, PageIterator returns next page
+	// errors as a generic ApiError. The registration fails harmlessly
+	// if the application registered its own mapping first.
+	graphcore.RegisterError(graphcore.PageIteratorErrorRegistryKey, errorMapping)
+}
+
+// All iterates over the items on firstPage and every page after it.
+// factory creates the collection response type of firstPage, such as
+// models.CreateMessageCollectionResponseFromDiscriminatorValue, and T is
+// the item type returned by its GetValue, such as *models.Message.
+//
+// Pages are fetched as the loop reaches them, or ahead of it with
+// WithPrefetch, and breaking out of the loop stops fetching. A failed
+// page request is yielded as an error and ends the loop.
+func All[T any](ctx context.Context, client Client, firstPage serialization.Parsable,
+	factory serialization.ParsableFactory, opts ...Option) iter.Seq2[T, error] {
+	if o := newOptions(opts); o.prefetch > 0 {
+		return prefetchAll[T](ctx, client, firstPage, factory, o)
+	}
+
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		pageIterator, err := newPageIterator[T](client, firstPage, factory, opts)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+
+		stopped := false
+		err = pageIterator.Iterate(ctx, func(item T) bool {
+			stopped = !yield(item, nil)
+			return !stopped
+		})
+		if err != nil && !stopped {
+			yield(zero, err)
+		}
+	}
+}
+
+// FromRequest sends request and iterates over every item it returns,
+// like All. The headers and request options of request are sent with
+// every next page request.
+func FromRequest[T any](ctx context.Context, client Client, request *abstractions.RequestInformation,
+	factory serialization.ParsableFactory, opts ...Option) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		firstPage, err := client.GetAdapter().Send(ctx, request, factory, errorMapping)
+		if err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+
+		allOpts := append([]Option{
+			WithHeaders(request.Headers),
+			WithRequestOptions(request.GetRequestOptions()...),
+		}, opts...)
+		all := All[T](ctx, client, firstPage, factory, allOpts...)
+		all(yield)
+	}
+}
+
+func newPageIterator[T any](client Client, firstPage serialization.Parsable,
+	factory serialization.ParsableFactory, opts []Option) (*graphcore.PageIterator[T], error) {
+	pageIterator, err := graphcore.NewPageIterator[T](firstPage, client.GetAdapter(), factory)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	if o.headers != nil {
+		pageIterator.SetHeaders(o.headers)
+	}
+	if len(o.requestOptions) > 0 {
+		pageIterator.SetReqOptions(o.requestOptions)
+	}
+
+	return pageIterator, nil
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
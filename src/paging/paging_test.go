@@ -0,0 +1,178 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package paging_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sdksnippets/graphmock"
+	"sdksnippets/paging"
+	"sync"
+	"testing"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	khttp "github.com/microsoft/kiota-http-go"
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+)
+
+// requestLog records the requests sent through the pipeline
+type requestLog struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	// sent, if set, receives a value as each request is sent
+	sent chan struct{}
+}
+
+func (l *requestLog) Intercept(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request) (*http.Response, error) {
+	l.mu.Lock()
+	l.requests = append(l.requests, req.Clone(req.Context()))
+	l.mu.Unlock()
+	if l.sent != nil {
+		l.sent <- struct{}{}
+	}
+
+	return pipeline.Next(req, middlewareIndex)
+}
+
+func (l *requestLog) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.requests)
+}
+
+func newMockClient(t testing.TB) (*graph.GraphServiceClient, *graphmock.Server, *requestLog) {
+	t.Helper()
+
+	log := &requestLog{}
+	graphClient, server := graphmock.NewTestClient(t, log)
+
+	return graphClient, server, log
+}
+
+// messagesRequest creates GET /me/messages with a page size and a Prefer header
+func messagesRequest(t testing.TB, graphClient *graph.GraphServiceClient, pageSize int32) *abstractions.RequestInformation {
+	t.Helper()
+
+	headers := abstractions.NewRequestHeaders()
+	headers.Add("Prefer", "outlook.body-content-type=\"text\"")
+	request, err := graphClient.Me().Messages().ToGetRequestInformation(context.Background(),
+		&users.ItemMessagesRequestBuilderGetRequestConfiguration{
+			Headers: headers,
+			QueryParameters: &users.ItemMessagesRequestBuilderGetQueryParameters{
+				Top: &pageSize,
+			},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return request
+}
+
+func TestAllVisitsEveryItem(t *testing.T) {
+	graphClient, _, _ := newMockClient(t)
+
+	pageSize := int32(7)
+	firstPage, err := graphClient.Me().Messages().Get(context.Background(),
+		&users.ItemMessagesRequestBuilderGetRequestConfiguration{
+			QueryParameters: &users.ItemMessagesRequestBuilderGetQueryParameters{
+				Top: &pageSize,
+			},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for message, err := range paging.All[*models.Message](context.Background(), graphClient, firstPage,
+		models.CreateMessageCollectionResponseFromDiscriminatorValue) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[*message.GetId()] = true
+	}
+
+	if len(seen) != 30 {
+		t.Errorf("got %d distinct messages, want 30", len(seen))
+	}
+}
+
+func TestFromRequestRepeatsHeaders(t *testing.T) {
+	graphClient, _, log := newMockClient(t)
+
+	count := 0
+	for _, err := range paging.FromRequest[*models.Message](context.Background(), graphClient,
+		messagesRequest(t, graphClient, 10), models.CreateMessageCollectionResponseFromDiscriminatorValue) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		count++
+	}
+
+	if count != 30 {
+		t.Errorf("got %d messages, want 30", count)
+	}
+	if len(log.requests) != 3 {
+		t.Fatalf("sent %d requests, want 3", len(log.requests))
+	}
+	for _, request := range log.requests {
+		if request.Header.Get("Prefer") != "outlook.body-content-type=\"text\"" {
+			t.Errorf("request to %s has Prefer header %q", request.URL, request.Header.Get("Prefer"))
+		}
+	}
+}
+
+func TestBreakStopsFetching(t *testing.T) {
+	graphClient, _, log := newMockClient(t)
+
+	count := 0
+	for _, err := range paging.FromRequest[*models.Message](context.Background(), graphClient,
+		messagesRequest(t, graphClient, 5), models.CreateMessageCollectionResponseFromDiscriminatorValue) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		count++
+		if count == 7 {
+			break
+		}
+	}
+
+	// The first page and the one holding the 7th message
+	if len(log.requests) != 2 {
+		t.Errorf("sent %d requests, want 2", len(log.requests))
+	}
+}
+
+func TestPageErrorEndsLoop(t *testing.T) {
+	graphClient, server, _ := newMockClient(t)
+
+	request := messagesRequest(t, graphClient, 10)
+	count := 0
+	var iterationErr error
+	for _, err := range paging.FromRequest[*models.Message](context.Background(), graphClient,
+		request, models.CreateMessageCollectionResponseFromDiscriminatorValue) {
+		if err != nil {
+			iterationErr = err
+			continue
+		}
+		count++
+		if count == 1 {
+			// Fail the request for the second page
+			server.FailNext("/me/messages", 1, http.StatusForbidden, 0)
+		}
+	}
+
+	var odataErr *odataerrors.ODataError
+	if !errors.As(iterationErr, &odataErr) || odataErr.GetStatusCode() != http.StatusForbidden {
+		t.Fatalf("got error %v, want a 403 ODataError", iterationErr)
+	}
+	if count != 10 {
+		t.Errorf("got %d messages before the error, want 10", count)
+	}
+}
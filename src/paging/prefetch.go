@@ -0,0 +1,129 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package paging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/microsoft/kiota-abstractions-go/serialization"
+	graphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
+)
+
+// WithPrefetch makes All and FromRequest fetch pages in the background
+// while the loop handles the items of earlier pages, so the time spent
+// waiting for Graph and the time spent in the loop overlap. Up to pages
+// fetched pages are held waiting for the loop; once that many are waiting,
+// fetching pauses until the loop catches up.
+func WithPrefetch(pages int) Option {
+	return func(o *options) {
+		o.prefetch = pages
+	}
+}
+
+// fetchedPage is a page read by the prefetching goroutine
+type fetchedPage[T any] struct {
+	items []T
+	last  bool
+	err   error
+}
+
+// prefetchAll is All with WithPrefetch. A goroutine follows the next
+// links and sends pages on a channel with room for o.prefetch pages.
+func prefetchAll[T any](ctx context.Context, client Client, firstPage serialization.Parsable,
+	factory serialization.ParsableFactory, o options) func(yield func(T, error) bool) {
+	return func(yield func(T, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		pages := make(chan fetchedPage[T], o.prefetch)
+		go fetchPages(ctx, client, firstPage, factory, o, pages)
+
+		// Stop the goroutine and wait for it when the loop ends early
+		defer func() {
+			cancel()
+			for range pages {
+			}
+		}()
+
+		var zero T
+		for page := range pages {
+			if page.err != nil {
+				yield(zero, page.err)
+				return
+			}
+			for _, item := range page.items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if page.last {
+				return
+			}
+		}
+
+		// The goroutine gave up without an error because ctx was cancelled
+		yield(zero, ctx.Err())
+	}
+}
+
+// fetchPages sends the items of page and of every page after it to
+// pages, then closes it. It stops after sending an error, or when ctx
+// is cancelled.
+func fetchPages[T any](ctx context.Context, client Client, page serialization.Parsable,
+	factory serialization.ParsableFactory, o options, pages chan<- fetchedPage[T]) {
+	defer close(pages)
+
+	send := func(fetched fetchedPage[T]) bool {
+		select {
+		case pages <- fetched:
+			return fetched.err == nil
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		items, nextLink, err := readPage[T](page)
+		if !send(fetchedPage[T]{items: items, last: nextLink == "", err: err}) || nextLink == "" {
+			return
+		}
+
+		page, err = getPage(ctx, client, nextLink, o.headers, o.requestOptions, factory)
+		if err != nil {
+			send(fetchedPage[T]{err: err})
+			return
+		}
+	}
+}
+
+// readPage returns the items and next link of a collection response,
+// read the way graphcore.PageIterator reads them
+func readPage[T any](page serialization.Parsable) ([]T, string, error) {
+	if page == nil {
+		return nil, "", errors.New("page cannot be nil")
+	}
+	pageWithNextLink, ok := page.(graphcore.PageWithOdataNextLink)
+	if !ok {
+		return nil, "", fmt.Errorf("%T has no next link", page)
+	}
+
+	method := reflect.ValueOf(page).MethodByName("GetValue")
+	if !method.IsValid() {
+		return nil, "", fmt.Errorf("%T has no value property", page)
+	}
+	value := method.Call(nil)[0]
+
+	items := make([]T, 0, value.Len())
+	for i := range value.Len() {
+		element := value.Index(i).Interface()
+		item, ok := element.(T)
+		if !ok {
+			return nil, "", fmt.Errorf("page item %d is %T, want %s", i, element, reflect.TypeFor[T]())
+		}
+		items = append(items, item)
+	}
+
+	return items, stringValue(pageWithNextLink.GetOdataNextLink()), nil
+}
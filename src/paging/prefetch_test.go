@@ -0,0 +1,131 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package paging_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sdksnippets/paging"
+	"testing"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+func TestPrefetchVisitsEveryItemInOrder(t *testing.T) {
+	graphClient, _, log := newMockClient(t)
+
+	var subjects []string
+	for message, err := range paging.FromRequest[*models.Message](context.Background(), graphClient,
+		messagesRequest(t, graphClient, 7), models.CreateMessageCollectionResponseFromDiscriminatorValue,
+		paging.WithPrefetch(2)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		subjects = append(subjects, *message.GetSubject())
+	}
+
+	if len(subjects) != 30 {
+		t.Fatalf("got %d messages, want 30", len(subjects))
+	}
+	for i, subject := range subjects {
+		if i != 6 && subject != fmt.Sprintf("Message %d", i+1) {
+			t.Errorf("message %d is %q", i, subject)
+		}
+	}
+	for _, request := range log.requests[1:] {
+		if request.Header.Get("Prefer") == "" {
+			t.Errorf("next page request %s is missing the Prefer header", request.URL)
+		}
+	}
+}
+
+func TestPrefetchStopsWhenBufferIsFull(t *testing.T) {
+	graphClient, _, log := newMockClient(t)
+	log.sent = make(chan struct{}, 10)
+	// The mock server answers at once, so a short deadline is enough
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+
+	for _, err := range paging.FromRequest[*models.Message](ctx, graphClient,
+		messagesRequest(t, graphClient, 5), models.CreateMessageCollectionResponseFromDiscriminatorValue,
+		paging.WithPrefetch(1)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The goroutine fetches as far ahead as it can. It holds one
+		// page waiting in the buffer and one it cannot send yet.
+		for i := range 3 {
+			select {
+			case <-log.sent:
+			case <-ctx.Done():
+				t.Fatalf("sent %d requests while handling the first page, want 3", i)
+			}
+		}
+		break
+	}
+
+	// Breaking waits for the goroutine to end, so no request can follow
+	if got := log.count(); got != 3 {
+		t.Errorf("sent %d requests in all, want 3", got)
+	}
+}
+
+func TestPrefetchEndsWhenContextIsCancelled(t *testing.T) {
+	graphClient, _, _ := newMockClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	count := 0
+	var lastErr error
+	for _, err := range paging.FromRequest[*models.Message](ctx, graphClient,
+		messagesRequest(t, graphClient, 5), models.CreateMessageCollectionResponseFromDiscriminatorValue,
+		paging.WithPrefetch(1)) {
+		if err != nil {
+			lastErr = err
+			break
+		}
+		count++
+		if count == 5 {
+			cancel()
+		}
+	}
+
+	if !errors.Is(lastErr, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", lastErr)
+	}
+	if count >= 30 {
+		t.Errorf("visited all %d messages after cancelling", count)
+	}
+}
+
+// BenchmarkIterateMessages pages through the mock server's messages the
+// way IterateAllMessages does, with a round trip of 2ms per page and
+// 400µs of work per item, with and without prefetching.
+func BenchmarkIterateMessages(b *testing.B) {
+	for _, prefetch := range []int{0, 1, 4} {
+		b.Run(fmt.Sprintf("prefetch=%d", prefetch), func(b *testing.B) {
+			graphClient, server, _ := newMockClient(b)
+			server.SetLatency(2 * time.Millisecond)
+
+			for b.Loop() {
+				count := 0
+				for _, err := range paging.FromRequest[*models.Message](context.Background(), graphClient,
+					messagesRequest(b, graphClient, 5), models.CreateMessageCollectionResponseFromDiscriminatorValue,
+					paging.WithPrefetch(prefetch)) {
+					if err != nil {
+						b.Fatal(err)
+					}
+					time.Sleep(400 * time.Microsecond)
+					count++
+				}
+				if count != 30 {
+					b.Fatalf("got %d messages, want 30", count)
+				}
+			}
+		})
+	}
+}
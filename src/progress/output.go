@@ -0,0 +1,159 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Style is a way of showing progress.
+type Style string
+
+const (
+	// BarStyle draws a progress bar on one terminal line
+	BarStyle Style = "bar"
+	// JSONStyle writes each report as a JSON object on its own line
+	JSONStyle Style = "json"
+	// QuietStyle shows nothing
+	QuietStyle Style = "quiet"
+)
+
+// NewOutput returns an Output of style that writes to w.
+func NewOutput(style Style, w io.Writer) (Output, error) {
+	switch style {
+	case BarStyle:
+		return NewBar(w), nil
+	case JSONStyle:
+		return NewJSON(w), nil
+	case QuietStyle:
+		return Quiet{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown progress style %q", style)
+}
+
+// barWidth is the number of characters between the brackets of a bar
+const barWidth = 30
+
+// Bar draws a progress bar, redrawing the same line for each report.
+type Bar struct {
+	w io.Writer
+	// lastLength is the length of the line last drawn,
+	// so a shorter one can clear it
+	lastLength int
+}
+
+// NewBar returns a Bar that draws to w, usually os.Stdout or os.Stderr.
+func NewBar(w io.Writer) *Bar {
+	return &Bar{w: w}
+}
+
+// Report draws stats, and ends the line if they are the last.
+func (b *Bar) Report(stats Stats) {
+	filled := int(stats.Percent() * barWidth / 100)
+	line := fmt.Sprintf("[%s%s] %3.0f%% %s/%s %s/s",
+		strings.Repeat("=", filled), strings.Repeat(" ", barWidth-filled), stats.Percent(),
+		formatBytes(float64(stats.Bytes)), formatBytes(float64(stats.TotalBytes)), formatBytes(stats.Rate))
+	if stats.Files > 1 {
+		line += fmt.Sprintf(" %d/%d files", stats.FilesDone, stats.Files)
+	}
+	if stats.FilesFailed > 0 {
+		line += fmt.Sprintf(" %d failed", stats.FilesFailed)
+	}
+	if !stats.Done {
+		eta := "--"
+		if stats.ETA > 0 {
+			eta = stats.ETA.Round(time.Second).String()
+		}
+		line += " ETA " + eta
+	}
+	line += " elapsed " + stats.Elapsed.Round(time.Second).String()
+
+	padding := max(0, b.lastLength-len(line))
+	b.lastLength = len(line)
+	fmt.Fprintf(b.w, "\r%s%s", line, strings.Repeat(" ", padding))
+	if stats.Done {
+		fmt.Fprintln(b.w)
+		b.lastLength = 0
+	}
+}
+
+// formatBytes formats a number of bytes with a binary unit, as in 1.5 MiB
+func formatBytes(bytes float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	unit := 0
+	for bytes >= 1024 && unit < len(units)-1 {
+		bytes /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%.0f %s", bytes, units[unit])
+	}
+
+	return fmt.Sprintf("%.1f %s", bytes, units[unit])
+}
+
+// Event is a report written by JSON.
+type Event struct {
+	// Type is "progress", or "done" for the last report
+	Type           string  `json:"type"`
+	Name           string  `json:"name,omitempty"`
+	Bytes          int64   `json:"bytes"`
+	TotalBytes     int64   `json:"totalBytes"`
+	Files          int     `json:"files"`
+	FilesDone      int     `json:"filesDone"`
+	FilesFailed    int     `json:"filesFailed"`
+	Percent        float64 `json:"percent"`
+	BytesPerSecond float64 `json:"bytesPerSecond"`
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+	// EtaSeconds is omitted until the rate is known
+	EtaSeconds *float64 `json:"etaSeconds,omitempty"`
+}
+
+// JSON writes each report as an Event on its own line, for programs
+// that read the output.
+type JSON struct {
+	encoder *json.Encoder
+}
+
+// NewJSON returns a JSON output that writes to w.
+func NewJSON(w io.Writer) *JSON {
+	return &JSON{encoder: json.NewEncoder(w)}
+}
+
+// Report writes stats as an Event.
+func (j *JSON) Report(stats Stats) {
+	event := Event{
+		Type:           "progress",
+		Name:           stats.Name,
+		Bytes:          stats.Bytes,
+		TotalBytes:     stats.TotalBytes,
+		Files:          stats.Files,
+		FilesDone:      stats.FilesDone,
+		FilesFailed:    stats.FilesFailed,
+		Percent:        stats.Percent(),
+		BytesPerSecond: stats.Rate,
+		ElapsedSeconds: stats.Elapsed.Seconds(),
+	}
+	if stats.Done {
+		event.Type = "done"
+	}
+	if stats.ETA > 0 {
+		eta := stats.ETA.Seconds()
+		event.EtaSeconds = &eta
+	}
+
+	// Progress is best effort, so a failed write is not an error
+	j.encoder.Encode(event)
+}
+
+// Quiet shows nothing.
+type Quiet struct{}
+
+// Report does nothing.
+func (Quiet) Report(Stats) {}
@@ -0,0 +1,272 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+// Package progress reports the progress of uploads and downloads, one
+// file or many at a time, with their throughput and time remaining.
+package progress
+
+import (
+	"sync"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go-core/fileuploader"
+)
+
+// Stats is a snapshot of the progress of every transfer of a Reporter.
+type Stats struct {
+	// Name is the transfer that changed most recently
+	Name       string
+	Bytes      int64
+	TotalBytes int64
+	Files      int
+	FilesDone  int
+	// FilesFailed counts transfers that ended without completing.
+	// Their remaining bytes are no longer in TotalBytes.
+	FilesFailed int
+	Elapsed     time.Duration
+	// Rate is the number of bytes transferred per second,
+	// measured over the window of the Reporter
+	Rate float64
+	// ETA is the estimated time until every transfer completes.
+	// It is zero if nothing has been transferred yet.
+	ETA time.Duration
+	// Done is set on the last report, made by Finish
+	Done bool
+}
+
+// Percent returns how much of TotalBytes has been transferred,
+// from 0 to 100.
+func (s Stats) Percent() float64 {
+	if s.TotalBytes <= 0 {
+		if s.Done {
+			return 100
+		}
+		return 0
+	}
+
+	return min(100, float64(s.Bytes)*100/float64(s.TotalBytes))
+}
+
+// Output shows the progress of transfers.
+type Output interface {
+	Report(stats Stats)
+}
+
+// Options configures a Reporter.
+type Options struct {
+	// Interval is the least time between reports. The default is
+	// half a second, and a negative interval reports every change.
+	Interval time.Duration
+	// Window is the time the rate is measured over. The
+	// default is five seconds.
+	Window time.Duration
+	// Now returns the current time. The default is time.Now.
+	Now func() time.Time
+}
+
+// sample is the number of bytes transferred at a moment
+type sample struct {
+	at    time.Time
+	bytes int64
+}
+
+// Reporter tracks any number of concurrent transfers and reports
+// their combined progress to an Output.
+type Reporter struct {
+	output  Output
+	options Options
+
+	mu         sync.Mutex
+	started    time.Time
+	stats      Stats
+	samples    []sample
+	lastReport time.Time
+}
+
+// NewReporter returns a Reporter that reports to output. The time
+// elapsed is measured from when NewReporter is called.
+func NewReporter(output Output, options Options) *Reporter {
+	if options.Interval == 0 {
+		options.Interval = 500 * time.Millisecond
+	}
+	if options.Window <= 0 {
+		options.Window = 5 * time.Second
+	}
+	if options.Now == nil {
+		options.Now = time.Now
+	}
+
+	started := options.Now()
+	return &Reporter{
+		output:  output,
+		options: options,
+		started: started,
+		samples: []sample{{at: started}},
+	}
+}
+
+// Start adds a transfer of size bytes and returns it. Transfers can be
+// started while others are running, and TotalBytes grows as they are.
+// If the size is not known, it can be 0, and it is then taken from the
+// total passed to the transfer's progress callbacks.
+func (r *Reporter) Start(name string, size int64) *Transfer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stats.Name = name
+	r.stats.Files++
+	r.stats.TotalBytes += size
+	return &Transfer{reporter: r, name: name, size: size}
+}
+
+// Finish makes the last report, with Done set.
+func (r *Reporter) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stats.Done = true
+	r.report(r.options.Now())
+}
+
+// Stats returns the current progress.
+func (r *Reporter) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.snapshot(r.options.Now())
+}
+
+// update applies change to the stats of name's transfer. It reports
+// if the interval has passed since the last report.
+func (r *Reporter) update(name string, change func(stats *Stats)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.options.Now()
+	r.stats.Name = name
+	change(&r.stats)
+
+	r.samples = append(r.samples, sample{at: now, bytes: r.stats.Bytes})
+	// Keep the newest sample from before the window as the start of it
+	windowStart := now.Add(-r.options.Window)
+	for len(r.samples) > 2 && !r.samples[1].at.After(windowStart) {
+		r.samples = r.samples[1:]
+	}
+
+	if now.Sub(r.lastReport) >= r.options.Interval {
+		r.report(now)
+	}
+}
+
+func (r *Reporter) report(now time.Time) {
+	r.lastReport = now
+	r.output.Report(r.snapshot(now))
+}
+
+func (r *Reporter) snapshot(now time.Time) Stats {
+	stats := r.stats
+	stats.Elapsed = now.Sub(r.started)
+
+	first := r.samples[0]
+	if seconds := now.Sub(first.at).Seconds(); seconds > 0 {
+		stats.Rate = float64(stats.Bytes-first.bytes) / seconds
+	}
+	if stats.Rate > 0 && stats.TotalBytes > stats.Bytes && !stats.Done {
+		stats.ETA = time.Duration(float64(stats.TotalBytes-stats.Bytes) / stats.Rate * float64(time.Second))
+	}
+
+	return stats
+}
+
+// Transfer is one file being uploaded or downloaded.
+type Transfer struct {
+	reporter *Reporter
+	name     string
+	size     int64
+
+	mu          sync.Mutex
+	transferred int64
+	done        bool
+}
+
+// Set records that transferred bytes of the file have been sent or
+// received. Values lower than an earlier one are ignored.
+func (t *Transfer) Set(transferred int64) {
+	t.set(transferred, 0)
+}
+
+// set records transferred bytes, and size if the size was not known
+func (t *Transfer) set(transferred int64, size int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.done {
+		return
+	}
+	var added int64
+	if t.size == 0 && size > 0 {
+		t.size = size
+		added = size
+	}
+	delta := max(0, transferred-t.transferred)
+	if delta == 0 && added == 0 {
+		return
+	}
+	t.transferred += delta
+	t.reporter.update(t.name, func(stats *Stats) {
+		stats.TotalBytes += added
+		stats.Bytes += delta
+	})
+}
+
+// Done records that the file is complete, counting any of its
+// bytes that were not reported. Set, Done and Fail do nothing
+// after Done or Fail.
+func (t *Transfer) Done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.done {
+		return
+	}
+	t.done = true
+	remaining := max(0, t.size-t.transferred)
+	t.transferred = t.size
+	t.reporter.update(t.name, func(stats *Stats) {
+		stats.Bytes += remaining
+		stats.FilesDone++
+	})
+}
+
+// Fail records that the file will not complete. The bytes already
+// transferred still count towards the rate.
+func (t *Transfer) Fail() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.done {
+		return
+	}
+	t.done = true
+	remaining := max(0, t.size-t.transferred)
+	t.reporter.update(t.name, func(stats *Stats) {
+		stats.TotalBytes -= remaining
+		stats.FilesFailed++
+	})
+}
+
+// UploadProgress returns a callback for fileuploader upload tasks, which
+// report the offset of the last byte of each slice they send.
+func (t *Transfer) UploadProgress() fileuploader.ProgressCallBack {
+	return func(current int64, total int64) {
+		t.set(current+1, total)
+	}
+}
+
+// DownloadProgress returns a callback for downloads that report the
+// number of bytes received, such as transfer.DownloadItem.
+func (t *Transfer) DownloadProgress() fileuploader.ProgressCallBack {
+	return func(current int64, total int64) {
+		t.set(current, total)
+	}
+}
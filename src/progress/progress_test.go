@@ -0,0 +1,182 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package progress_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"sdksnippets/progress"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clock is a time that only moves when advanced
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// recorder keeps every report
+type recorder struct {
+	reports []progress.Stats
+}
+
+func (r *recorder) Report(stats progress.Stats) {
+	r.reports = append(r.reports, stats)
+}
+
+func TestReporter(t *testing.T) {
+	c := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	output := &recorder{}
+	reporter := progress.NewReporter(output, progress.Options{Interval: time.Second, Now: c.Now})
+
+	upload := reporter.Start("a.bin", 1000).UploadProgress()
+	c.advance(2 * time.Second)
+	// Slices end at byte offsets, so 200 bytes end at 199
+	upload(199, 1000)
+	c.advance(500 * time.Millisecond)
+	upload(299, 1000)
+
+	// The second update came within the interval
+	if len(output.reports) != 1 {
+		t.Fatalf("got %d reports, want 1", len(output.reports))
+	}
+	stats := reporter.Stats()
+	if stats.Bytes != 300 || stats.TotalBytes != 1000 || stats.Elapsed != 2500*time.Millisecond {
+		t.Errorf("stats = %+v", stats)
+	}
+	if stats.Rate != 120 || stats.ETA != 700*time.Second/120 {
+		t.Errorf("rate %v and ETA %v, want 120 and %v", stats.Rate, stats.ETA, 700*time.Second/120)
+	}
+
+	reporter.Finish()
+	last := output.reports[len(output.reports)-1]
+	if !last.Done || last.ETA != 0 || last.Percent() != 30 {
+		t.Errorf("last report = %+v", last)
+	}
+}
+
+func TestReporterRateWindow(t *testing.T) {
+	c := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	reporter := progress.NewReporter(progress.Quiet{}, progress.Options{Window: 4 * time.Second, Now: c.Now})
+	transfer := reporter.Start("a.bin", 100000)
+
+	// A resumed download reports what it already had at once
+	c.advance(time.Second)
+	transfer.Set(50000)
+	for range 10 {
+		c.advance(time.Second)
+		transfer.Set(reporter.Stats().Bytes + 100)
+	}
+
+	if stats := reporter.Stats(); stats.Rate != 100 {
+		t.Errorf("rate = %v, want 100 once the burst is out of the window", stats.Rate)
+	}
+}
+
+func TestReporterMultipleFiles(t *testing.T) {
+	c := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	reporter := progress.NewReporter(progress.Quiet{}, progress.Options{Now: c.Now})
+
+	// Sizes can be learned from the callbacks
+	download := reporter.Start("a.bin", 0)
+	download.DownloadProgress()(100, 400)
+	second := reporter.Start("b.bin", 300)
+	third := reporter.Start("c.bin", 500)
+	third.Set(50)
+
+	download.Done()
+	second.Done()
+	third.Fail()
+	// Nothing is counted after a transfer ends
+	third.Set(500)
+	download.DownloadProgress()(400, 400)
+
+	stats := reporter.Stats()
+	want := progress.Stats{Name: "c.bin", Bytes: 750, TotalBytes: 750, Files: 3, FilesDone: 2, FilesFailed: 1}
+	stats.Elapsed, stats.Rate, stats.ETA = 0, 0, 0
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestBar(t *testing.T) {
+	var out bytes.Buffer
+	bar := progress.NewBar(&out)
+
+	bar.Report(progress.Stats{
+		Bytes:      3 * 1024 * 1024,
+		TotalBytes: 10 * 1024 * 1024,
+		Files:      4,
+		FilesDone:  1,
+		Rate:       1024 * 1024,
+		ETA:        7 * time.Second,
+		Elapsed:    3 * time.Second,
+	})
+	first := out.String()
+	want := "\r[=========                     ]  30% 3.0 MiB/10.0 MiB 1.0 MiB/s 1/4 files ETA 7s elapsed 3s"
+	if first != want {
+		t.Errorf("bar = %q, want %q", first, want)
+	}
+
+	out.Reset()
+	bar.Report(progress.Stats{Bytes: 500, TotalBytes: 500, Files: 1, FilesDone: 1, Done: true})
+	last := out.String()
+	// The shorter line clears the longer one and ends it
+	if !strings.HasPrefix(last, "\r[==============================] 100% 500 B/500 B 0 B/s elapsed 0s ") ||
+		len(last) != len(first)+1 || !strings.HasSuffix(last, " \n") {
+		t.Errorf("last bar = %q", last)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var out bytes.Buffer
+	output, err := progress.NewOutput(progress.JSONStyle, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output.Report(progress.Stats{Name: "a.bin", TotalBytes: 200})
+	output.Report(progress.Stats{Name: "a.bin", Bytes: 100, TotalBytes: 200, Rate: 50, ETA: 2 * time.Second})
+	output.Report(progress.Stats{Name: "a.bin", Bytes: 200, TotalBytes: 200, Done: true})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+	if strings.Contains(lines[0], "etaSeconds") {
+		t.Errorf("first event has an ETA before the rate is known: %s", lines[0])
+	}
+
+	var event progress.Event
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != "progress" || event.Percent != 50 || event.BytesPerSecond != 50 ||
+		event.EtaSeconds == nil || *event.EtaSeconds != 2 {
+		t.Errorf("event = %+v", event)
+	}
+	if err := json.Unmarshal([]byte(lines[2]), &event); err != nil || event.Type != "done" {
+		t.Errorf("last event = %s, want done", lines[2])
+	}
+}
+
+func TestNewOutput(t *testing.T) {
+	for _, style := range []progress.Style{progress.BarStyle, progress.JSONStyle, progress.QuietStyle} {
+		if _, err := progress.NewOutput(style, &bytes.Buffer{}); err != nil {
+			t.Errorf("%s: %v", style, err)
+		}
+	}
+	if _, err := progress.NewOutput("fancy", &bytes.Buffer{}); err == nil {
+		t.Error("unknown style was accepted")
+	}
+}
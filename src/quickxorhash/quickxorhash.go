@@ -0,0 +1,95 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+// Package quickxorhash implements QuickXorHash, the hash OneDrive reports
+// in the quickXorHash property of a drive item's file hashes. OneDrive for
+// Business and SharePoint only report this hash.
+//
+// Each input byte is XORed into a 160-bit register, starting 11 bits
+// after the previous byte and wrapping around. The length of the input
+// is then XORed into the last 64 bits. The hash is usually shown base64
+// encoded, as EncodeToString does.
+package quickxorhash
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"hash"
+)
+
+const (
+	// Size is the size of the hash in bytes
+	Size = 20
+	// BlockSize is the block size of the hash in bytes. Input
+	// of any length is processed without buffering.
+	BlockSize = 64
+
+	widthInBits = Size * 8
+	shift       = 11
+)
+
+type digest struct {
+	register [Size]byte
+	length   uint64
+}
+
+// New returns a new hash.Hash computing QuickXorHash.
+func New() hash.Hash {
+	return &digest{}
+}
+
+// Sum returns the QuickXorHash of data.
+func Sum(data []byte) [Size]byte {
+	var d digest
+	d.Write(data)
+
+	var sum [Size]byte
+	d.Sum(sum[:0])
+	return sum
+}
+
+// EncodeToString returns the base64 encoding of sum,
+// which is how Graph reports the hash.
+func EncodeToString(sum []byte) string {
+	return base64.StdEncoding.EncodeToString(sum)
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	bit := int((d.length * shift) % widthInBits)
+	for _, b := range p {
+		// The byte may straddle two register bytes, the second
+		// of which wraps around to the start
+		index, offset := bit/8, bit%8
+		value := uint16(b) << offset
+		d.register[index] ^= byte(value)
+		d.register[(index+1)%Size] ^= byte(value >> 8)
+
+		bit = (bit + shift) % widthInBits
+	}
+	d.length += uint64(len(p))
+
+	return len(p), nil
+}
+
+func (d *digest) Sum(b []byte) []byte {
+	sum := d.register
+	var length [8]byte
+	binary.LittleEndian.PutUint64(length[:], d.length)
+	for i, value := range length {
+		sum[Size-len(length)+i] ^= value
+	}
+
+	return append(b, sum[:]...)
+}
+
+func (d *digest) Reset() {
+	*d = digest{}
+}
+
+func (d *digest) Size() int {
+	return Size
+}
+
+func (d *digest) BlockSize() int {
+	return BlockSize
+}
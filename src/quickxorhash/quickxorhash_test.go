@@ -0,0 +1,65 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package quickxorhash_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"sdksnippets/quickxorhash"
+	"slices"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+	// The 15th byte starts at bit 154, so it wraps around into the first byte
+	wrapped := make([]byte, 15)
+	wrapped[14] = 0xff
+	wrappedSum := make([]byte, quickxorhash.Size)
+	wrappedSum[0], wrappedSum[12], wrappedSum[19] = 0x03, 0x0f, 0xfc
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{"empty", nil, "AAAAAAAAAAAAAAAAAAAAAAAAAAA="},
+		{"one byte", []byte{0x4a}, "SgAAAAAAAAAAAAAAAQAAAAAAAAA="},
+		// 0x03 starts at bit 11, the fourth bit of the second byte
+		{"two bytes", []byte{0xc4, 0x03}, base64.StdEncoding.EncodeToString(
+			[]byte{0xc4, 0x18, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0})},
+		{"wrapped", wrapped, base64.StdEncoding.EncodeToString(wrappedSum)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sum := quickxorhash.Sum(test.input)
+			if got := quickxorhash.EncodeToString(sum[:]); got != test.want {
+				t.Errorf("Sum = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteInPieces(t *testing.T) {
+	content := make([]byte, 10000)
+	for i := range content {
+		content[i] = byte(i*31 + i/7)
+	}
+	want := quickxorhash.Sum(content)
+
+	for _, pieceSize := range []int{1, 7, 20, 160, 4096} {
+		h := quickxorhash.New()
+		for piece := range slices.Chunk(content, pieceSize) {
+			h.Write(piece)
+		}
+		if got := h.Sum(nil); !bytes.Equal(got, want[:]) {
+			t.Errorf("pieces of %d: Sum = %x, want %x", pieceSize, got, want)
+		}
+
+		h.Reset()
+		h.Write(content)
+		if got := h.Sum(nil); !bytes.Equal(got, want[:]) {
+			t.Errorf("pieces of %d: Sum after Reset = %x, want %x", pieceSize, got, want)
+		}
+	}
+}
@@ -6,7 +6,7 @@ package snippets
 import (
 	"context"
 	"fmt"
-	"log"
+	"sdksnippets/batching"
 	"time"
 
 	graph "github.com/microsoftgraph/msgraph-sdk-go"
@@ -16,19 +16,24 @@ import (
 	"github.com/thlib/go-timezone-local/tzlocal"
 )
 
-func RunBatchSamples(graphClient *graph.GraphServiceClient) {
-	SimpleBatch(graphClient)
-	DependentBatch(graphClient)
+func RunBatchSamples(graphClient *graph.GraphServiceClient) error {
+	_, err := SimpleBatch(graphClient)
+	if err != nil {
+		return err
+	}
+
+	_, err = DependentBatch(graphClient)
+	return err
 }
 
-func SimpleBatch(graphClient *graph.GraphServiceClient) {
+func SimpleBatch(graphClient *graph.GraphServiceClient) (graphcore.BatchResponse, error) {
 	// <SimpleBatchSnippet>
 	// Use the request builder to generate a regular
 	// request to /me
 	meRequest, err := graphClient.Me().
 		ToGetRequestInformation(context.Background(), nil)
 	if err != nil {
-		log.Fatalf("Error creating GET /me request: %v\n", err)
+		return nil, fmt.Errorf("creating GET /me request: %w", err)
 	}
 
 	now := time.Now()
@@ -53,7 +58,7 @@ func SimpleBatch(graphClient *graph.GraphServiceClient) {
 				QueryParameters: &query,
 			})
 	if err != nil {
-		log.Fatalf("Error creating GET /me/calendarView request: %v\n", err)
+		return nil, fmt.Errorf("creating GET /me/calendarView request: %w", err)
 	}
 
 	// Build the batch
@@ -63,23 +68,23 @@ func SimpleBatch(graphClient *graph.GraphServiceClient) {
 	// with no specified order of execution
 	meRequestItem, err := batch.AddBatchRequestStep(*meRequest)
 	if err != nil {
-		log.Fatalf("Error adding GET /me request to batch: %v\n", err)
+		return nil, fmt.Errorf("adding GET /me request to batch: %w", err)
 	}
 	eventsRequestItem, err := batch.AddBatchRequestStep(*eventsRequest)
 	if err != nil {
-		log.Fatalf("Error adding GET /me/calendarView request to batch: %v\n", err)
+		return nil, fmt.Errorf("adding GET /me/calendarView request to batch: %w", err)
 	}
 
 	batchResponse, err := batch.Send(context.Background(), graphClient.GetAdapter())
 	if err != nil {
-		log.Fatalf("Error sending batch: %v\n", err)
+		return nil, fmt.Errorf("sending batch: %w", err)
 	}
 
 	// De-serialize response based on known return type
 	user, err := graphcore.GetBatchResponseById[models.Userable](
 		batchResponse, *meRequestItem.GetId(), models.CreateUserFromDiscriminatorValue)
 	if err != nil {
-		log.Fatalf("Error reading GET /me response: %v\n", err)
+		return nil, fmt.Errorf("reading GET /me response: %w", err)
 	}
 	fmt.Printf("Hello %s\n", *(user.GetDisplayName()))
 
@@ -88,18 +93,23 @@ func SimpleBatch(graphClient *graph.GraphServiceClient) {
 		batchResponse, *eventsRequestItem.GetId(),
 		models.CreateEventCollectionResponseFromDiscriminatorValue)
 	if err != nil {
-		log.Fatalf("Error reading GET /me/calendarView response: %v\n", err)
+		return nil, fmt.Errorf("reading GET /me/calendarView response: %w", err)
 	}
 	fmt.Printf("You have %d events on your calendar today\n", len(events.GetValue()))
 	// </SimpleBatchSnippet>
+
+	return batchResponse, nil
 }
 
-func DependentBatch(graphClient *graph.GraphServiceClient) {
+func DependentBatch(graphClient *graph.GraphServiceClient) (graphcore.BatchResponse, error) {
 	// <DependentBatchSnippet>
 	now := time.Now()
 	nowMidnight := time.Date(now.Year(), now.Month(), now.Day(),
 		0, 0, 0, 0, time.Local)
-	timeZone, _ := tzlocal.RuntimeTZ()
+	timeZone, err := tzlocal.RuntimeTZ()
+	if err != nil {
+		return nil, fmt.Errorf("getting local time zone: %w", err)
+	}
 
 	// 5:00 PM
 	startDateTime := nowMidnight.Add(time.Hour * 17)
@@ -128,7 +138,7 @@ func DependentBatch(graphClient *graph.GraphServiceClient) {
 		Events().
 		ToPostRequestInformation(context.Background(), newEvent, nil)
 	if err != nil {
-		log.Fatalf("Error creating POST /me/events request: %v\n", err)
+		return nil, fmt.Errorf("creating POST /me/events request: %w", err)
 	}
 
 	viewStart := nowMidnight.UTC().Format(time.RFC3339)
@@ -149,7 +159,7 @@ func DependentBatch(graphClient *graph.GraphServiceClient) {
 				QueryParameters: &query,
 			})
 	if err != nil {
-		log.Fatalf("Error creating GET /me/calendarView request: %v\n", err)
+		return nil, fmt.Errorf("creating GET /me/calendarView request: %w", err)
 	}
 
 	// Build the batch
@@ -161,19 +171,19 @@ func DependentBatch(graphClient *graph.GraphServiceClient) {
 	// First request, no dependency
 	addEventRequestItem, err := batch.AddBatchRequestStep(*addEventRequest)
 	if err != nil {
-		log.Fatalf("Error adding POST /me/events request to batch: %v\n", err)
+		return nil, fmt.Errorf("adding POST /me/events request to batch: %w", err)
 	}
 
 	// Second request, depends on addEventRequestId
 	eventsRequestItem, err := batch.AddBatchRequestStep(*eventsRequest)
 	if err != nil {
-		log.Fatalf("Error creating GET /me/calendarView request to batch: %v\n", err)
+		return nil, fmt.Errorf("adding GET /me/calendarView request to batch: %w", err)
 	}
 	eventsRequestItem.DependsOnItem(addEventRequestItem)
 
 	batchResponse, err := batch.Send(context.Background(), graphClient.GetAdapter())
 	if err != nil {
-		log.Fatalf("Error sending batch: %v\n", err)
+		return nil, fmt.Errorf("sending batch: %w", err)
 	}
 
 	// De-serialize response based on known return type
@@ -181,7 +191,7 @@ func DependentBatch(graphClient *graph.GraphServiceClient) {
 		batchResponse, *addEventRequestItem.GetId(),
 		models.CreateEventFromDiscriminatorValue)
 	if err != nil {
-		log.Fatalf("Error reading POST /me/events response: %v\n", err)
+		return nil, fmt.Errorf("reading POST /me/events response: %w", err)
 	}
 	fmt.Printf("New event created with ID: %s\n", *(event.GetId()))
 
@@ -190,8 +200,136 @@ func DependentBatch(graphClient *graph.GraphServiceClient) {
 		batchResponse, *eventsRequestItem.GetId(),
 		models.CreateEventCollectionResponseFromDiscriminatorValue)
 	if err != nil {
-		log.Fatalf("Error reading GET /me/calendarView response: %v\n", err)
+		return nil, fmt.Errorf("reading GET /me/calendarView response: %w", err)
 	}
 	fmt.Printf("You have %d events on your calendar today\n", len(events.GetValue()))
 	// </DependentBatchSnippet>
+
+	return batchResponse, nil
+}
+
+func ChunkedBatch(graphClient *graph.GraphServiceClient) (*batching.Result, error) {
+	// <ChunkedBatchSnippet>
+	// Get the IDs of the 25 most recent messages
+	top := int32(25)
+	messages, err := graphClient.Me().
+		Messages().
+		Get(context.Background(),
+			&users.ItemMessagesRequestBuilderGetRequestConfiguration{
+				QueryParameters: &users.ItemMessagesRequestBuilderGetQueryParameters{
+					Select: []string{"id"},
+					Top:    &top,
+				},
+			})
+	if err != nil {
+		return nil, fmt.Errorf("getting messages: %w", err)
+	}
+
+	// batching.Batch accepts more than the 20 requests
+	// Graph allows in one batch, and splits them as needed
+	batch := batching.NewBatch(graphClient.GetAdapter())
+
+	query := users.ItemMessagesMessageItemRequestBuilderGetQueryParameters{
+		Select: []string{"subject"},
+	}
+	for _, message := range messages.GetValue() {
+		messageRequest, err := graphClient.Me().
+			Messages().
+			ByMessageId(*message.GetId()).
+			ToGetRequestInformation(context.Background(),
+				&users.ItemMessagesMessageItemRequestBuilderGetRequestConfiguration{
+					QueryParameters: &query,
+				})
+		if err != nil {
+			return nil, fmt.Errorf("creating GET /me/messages/{id} request: %w", err)
+		}
+
+		// Use the message ID as the step ID
+		err = batch.AddStep(*message.GetId(), *messageRequest)
+		if err != nil {
+			return nil, fmt.Errorf("adding GET /me/messages/{id} request to batch: %w", err)
+		}
+	}
+
+	batchResponse, err := batch.Send(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("sending batch: %w", err)
+	}
+
+	// Responses are keyed by the step IDs given to AddStep
+	for _, message := range messages.GetValue() {
+		result, err := graphcore.GetBatchResponseById[models.Messageable](
+			batchResponse, *message.GetId(), models.CreateMessageFromDiscriminatorValue)
+		if err != nil {
+			return nil, fmt.Errorf("reading GET /me/messages/{id} response: %w", err)
+		}
+		fmt.Printf("Message: %s\n", *(result.GetSubject()))
+	}
+	// </ChunkedBatchSnippet>
+
+	return batchResponse, nil
+}
+
+func TypedBatch(graphClient *graph.GraphServiceClient) (*batching.Result, error) {
+	// <TypedBatchSnippet>
+	meRequest, err := graphClient.Me().
+		ToGetRequestInformation(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating GET /me request: %w", err)
+	}
+
+	messagesRequest, err := graphClient.Me().
+		Messages().
+		ToGetRequestInformation(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating GET /me/messages request: %w", err)
+	}
+
+	batch := batching.NewBatch(graphClient.GetAdapter())
+
+	// Register the factory for each response with its request
+	meStep, err := batching.AddTypedStep[models.Userable](
+		batch, "me", *meRequest, models.CreateUserFromDiscriminatorValue)
+	if err != nil {
+		return nil, fmt.Errorf("adding GET /me request to batch: %w", err)
+	}
+	messagesStep, err := batching.AddTypedStep[models.MessageCollectionResponseable](
+		batch, "messages", *messagesRequest,
+		models.CreateMessageCollectionResponseFromDiscriminatorValue)
+	if err != nil {
+		return nil, fmt.Errorf("adding GET /me/messages request to batch: %w", err)
+	}
+
+	batchResponse, err := batch.Send(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("sending batch: %w", err)
+	}
+
+	// A failed step does not prevent reading the others. A step that
+	// succeeds without a body, such as a 204, has a nil Value.
+	me := meStep.Result(batchResponse)
+	if me.Succeeded() && me.Value != nil && me.Value.GetDisplayName() != nil {
+		fmt.Printf("Hello %s\n", *(me.Value.GetDisplayName()))
+	} else if me.Succeeded() {
+		fmt.Printf("GET /me returned status %d without a display name\n", me.Status)
+	} else {
+		fmt.Printf("GET /me failed with status %d: %v\n", me.Status, me.Err())
+	}
+
+	messages := messagesStep.Result(batchResponse)
+	if messages.Succeeded() && messages.Value != nil {
+		fmt.Printf("You have at least %d messages\n", len(messages.Value.GetValue()))
+	} else if messages.Succeeded() {
+		fmt.Printf("GET /me/messages returned status %d without a body\n", messages.Status)
+	} else if messages.ODataError != nil && messages.ODataError.GetErrorEscaped() != nil &&
+		messages.ODataError.GetErrorEscaped().GetMessage() != nil {
+		// The step's body was a Graph error with a message
+		fmt.Printf("GET /me/messages failed with status %d: %s\n",
+			messages.Status, *(messages.ODataError.GetErrorEscaped().GetMessage()))
+	} else {
+		fmt.Printf("GET /me/messages failed: %v\n", messages.Err())
+	}
+	// </TypedBatchSnippet>
+
+	return batchResponse, nil
 }
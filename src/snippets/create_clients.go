@@ -12,68 +12,102 @@ import (
 	graph "github.com/microsoftgraph/msgraph-sdk-go"
 )
 
-func NewGraphClientWithClientSecret() *graph.GraphServiceClient {
+func NewGraphClientWithClientSecret() (*graph.GraphServiceClient, error) {
 	// <ClientSecretSnippet>
-	cred, _ := azidentity.NewClientSecretCredential(
+	cred, err := azidentity.NewClientSecretCredential(
 		"TENANT_ID",
 		"CLIENT_ID",
 		"CLIENT_SECRET",
 		nil,
 	)
+	if err != nil {
+		return nil, fmt.Errorf("creating client secret credential: %w", err)
+	}
 
-	graphClient, _ := graph.NewGraphServiceClientWithCredentials(
+	graphClient, err := graph.NewGraphServiceClientWithCredentials(
 		cred, []string{"https://graph.microsoft.com/.default"})
 	// </ClientSecretSnippet>
 
-	return graphClient
+	if err != nil {
+		return nil, fmt.Errorf("creating graph client: %w", err)
+	}
+
+	return graphClient, nil
 }
 
-func NewGraphClientWithClientCertificate() *graph.GraphServiceClient {
+func NewGraphClientWithClientCertificate() (*graph.GraphServiceClient, error) {
 	// <ClientCertificateSnippet>
 	// Load certificate
-	certFile, _ := os.Open("certificate.pem")
-	info, _ := certFile.Stat()
+	certFile, err := os.Open("certificate.pem")
+	if err != nil {
+		return nil, fmt.Errorf("opening certificate: %w", err)
+	}
+	info, err := certFile.Stat()
+	if err != nil {
+		certFile.Close()
+		return nil, fmt.Errorf("reading certificate size: %w", err)
+	}
 	certBytes := make([]byte, info.Size())
-	certFile.Read(certBytes)
+	_, err = certFile.Read(certBytes)
 	certFile.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading certificate: %w", err)
+	}
 
-	certs, key, _ := azidentity.ParseCertificates(certBytes, nil)
+	certs, key, err := azidentity.ParseCertificates(certBytes, nil)
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate: %w", err)
+	}
 
-	cred, _ := azidentity.NewClientCertificateCredential(
+	cred, err := azidentity.NewClientCertificateCredential(
 		"TENANT_ID",
 		"CLIENT_ID",
 		certs,
 		key,
 		nil,
 	)
+	if err != nil {
+		return nil, fmt.Errorf("creating client certificate credential: %w", err)
+	}
 
-	graphClient, _ := graph.NewGraphServiceClientWithCredentials(
+	graphClient, err := graph.NewGraphServiceClientWithCredentials(
 		cred, []string{"https://graph.microsoft.com/.default"})
 	// </ClientCertificateSnippet>
 
-	return graphClient
+	if err != nil {
+		return nil, fmt.Errorf("creating graph client: %w", err)
+	}
+
+	return graphClient, nil
 }
 
-func NewGraphClientWithOnBehalfOf() *graph.GraphServiceClient {
+func NewGraphClientWithOnBehalfOf() (*graph.GraphServiceClient, error) {
 	// <OnBehalfOfSnippet>
-	cred, _ := azidentity.NewOnBehalfOfCredentialWithSecret(
+	cred, err := azidentity.NewOnBehalfOfCredentialWithSecret(
 		"TENANT_ID",
 		"CLIENT_ID",
 		"USER_ASSERTION_STRING",
 		"CLIENT_SECRET",
 		nil,
 	)
+	if err != nil {
+		return nil, fmt.Errorf("creating on-behalf-of credential: %w", err)
+	}
 
-	graphClient, _ := graph.NewGraphServiceClientWithCredentials(
+	graphClient, err := graph.NewGraphServiceClientWithCredentials(
 		cred, []string{"https://graph.microsoft.com/.default"})
 	// </OnBehalfOfSnippet>
 
-	return graphClient
+	if err != nil {
+		return nil, fmt.Errorf("creating graph client: %w", err)
+	}
+
+	return graphClient, nil
 }
 
-func NewGraphClientWithDeviceCode() *graph.GraphServiceClient {
+func NewGraphClientWithDeviceCode() (*graph.GraphServiceClient, error) {
 	// <DeviceCodeSnippet>
-	cred, _ := azidentity.NewDeviceCodeCredential(&azidentity.DeviceCodeCredentialOptions{
+	cred, err := azidentity.NewDeviceCodeCredential(&azidentity.DeviceCodeCredentialOptions{
 		TenantID: "TENANT_ID",
 		ClientID: "CLIENT_ID",
 		UserPrompt: func(ctx context.Context, message azidentity.DeviceCodeMessage) error {
@@ -81,42 +115,63 @@ func NewGraphClientWithDeviceCode() *graph.GraphServiceClient {
 			return nil
 		},
 	})
+	if err != nil {
+		return nil, fmt.Errorf("creating device code credential: %w", err)
+	}
 
-	graphClient, _ := graph.NewGraphServiceClientWithCredentials(
+	graphClient, err := graph.NewGraphServiceClientWithCredentials(
 		cred, []string{"User.Read"})
 	// </DeviceCodeSnippet>
 
-	return graphClient
+	if err != nil {
+		return nil, fmt.Errorf("creating graph client: %w", err)
+	}
+
+	return graphClient, nil
 }
 
-func NewGraphClientWithInteractive() *graph.GraphServiceClient {
+func NewGraphClientWithInteractive() (*graph.GraphServiceClient, error) {
 	// <InteractiveSnippet>
-	cred, _ := azidentity.NewInteractiveBrowserCredential(&azidentity.InteractiveBrowserCredentialOptions{
+	cred, err := azidentity.NewInteractiveBrowserCredential(&azidentity.InteractiveBrowserCredentialOptions{
 		TenantID:    "TENANT_ID",
 		ClientID:    "CLIENT_ID",
 		RedirectURL: "REDIRECT_URL",
 	})
+	if err != nil {
+		return nil, fmt.Errorf("creating interactive browser credential: %w", err)
+	}
 
-	graphClient, _ := graph.NewGraphServiceClientWithCredentials(
+	graphClient, err := graph.NewGraphServiceClientWithCredentials(
 		cred, []string{"User.Read"})
 	// </InteractiveSnippet>
 
-	return graphClient
+	if err != nil {
+		return nil, fmt.Errorf("creating graph client: %w", err)
+	}
+
+	return graphClient, nil
 }
 
-func NewGraphClientWithUserNamePassword() *graph.GraphServiceClient {
+func NewGraphClientWithUserNamePassword() (*graph.GraphServiceClient, error) {
 	// <UserNamePasswordSnippet>
-	cred, _ := azidentity.NewUsernamePasswordCredential(
+	cred, err := azidentity.NewUsernamePasswordCredential(
 		"TENANT_ID",
 		"CLIENT_ID",
 		"USER_NAME",
 		"PASSWORD",
 		nil,
 	)
+	if err != nil {
+		return nil, fmt.Errorf("creating username password credential: %w", err)
+	}
 
-	graphClient, _ := graph.NewGraphServiceClientWithCredentials(
+	graphClient, err := graph.NewGraphServiceClientWithCredentials(
 		cred, []string{"User.Read"})
 	// </UserNamePasswordSnippet>
 
-	return graphClient
+	if err != nil {
+		return nil, fmt.Errorf("creating graph client: %w", err)
+	}
+
+	return graphClient, nil
 }
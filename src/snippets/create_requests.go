@@ -5,7 +5,7 @@ package snippets
 
 import (
 	"context"
-	"log"
+	"fmt"
 
 	abstractions "github.com/microsoft/kiota-abstractions-go"
 	graph "github.com/microsoftgraph/msgraph-sdk-go"
@@ -14,16 +14,16 @@ import (
 	"github.com/microsoftgraph/msgraph-sdk-go/users"
 )
 
-func RunRequestSamples(graphClient *graph.GraphServiceClient) {
+func RunRequestSamples(graphClient *graph.GraphServiceClient) error {
 	// Create a new message
 	msg := models.NewMessage()
 	subject := "Temporary"
 	msg.SetSubject(&subject)
 	tempMessage, err := graphClient.Me().Messages().Post(context.Background(), msg, nil)
 	if err != nil {
-		log.Fatalf("Error creating message: %v\n", err)
+		return fmt.Errorf("creating message: %w", err)
 	}
-	messageId := tempMessage.GetId()
+	messageId := *tempMessage.GetId()
 
 	filterValue := "resourceProvisioningOptions/Any(x:x eq 'Team')"
 	query := groups.GroupsRequestBuilderGetQueryParameters{
@@ -36,32 +36,58 @@ func RunRequestSamples(graphClient *graph.GraphServiceClient) {
 	// Get a team to update
 	teams, err := graphClient.Groups().Get(context.Background(), &options)
 	if err != nil {
-		log.Fatalf("Error getting teams: %v\n", err)
-	}
-	teamId := teams.GetValue()[0].GetId()
-
-	MakeReadRequest(graphClient)
-	MakeSelectRequest(graphClient)
-	MakeListRequest(graphClient)
-	MakeItemByIdRequest(graphClient, *messageId)
-	MakeExpandRequest(graphClient, *messageId)
-	MakeDeleteRequest(graphClient, *messageId)
-	MakeCreateRequest(graphClient)
-	MakeUpdateRequest(graphClient, *teamId)
-	MakeHeadersRequest(graphClient)
-	MakeQueryParametersRequest(graphClient)
+		return fmt.Errorf("getting teams: %w", err)
+	}
+	if len(teams.GetValue()) == 0 {
+		return fmt.Errorf("getting teams: no teams found")
+	}
+	teamId := *teams.GetValue()[0].GetId()
+
+	if _, err = MakeReadRequest(graphClient); err != nil {
+		return err
+	}
+	if _, err = MakeSelectRequest(graphClient); err != nil {
+		return err
+	}
+	if _, err = MakeListRequest(graphClient); err != nil {
+		return err
+	}
+	if _, err = MakeItemByIdRequest(graphClient, messageId); err != nil {
+		return err
+	}
+	if _, err = MakeExpandRequest(graphClient, messageId); err != nil {
+		return err
+	}
+	if err = MakeDeleteRequest(graphClient, messageId); err != nil {
+		return err
+	}
+	if _, err = MakeCreateRequest(graphClient); err != nil {
+		return err
+	}
+	if _, err = MakeUpdateRequest(graphClient, teamId); err != nil {
+		return err
+	}
+	if _, err = MakeHeadersRequest(graphClient); err != nil {
+		return err
+	}
+	_, err = MakeQueryParametersRequest(graphClient)
+	return err
 }
 
-func MakeReadRequest(graphClient *graph.GraphServiceClient) models.Userable {
+func MakeReadRequest(graphClient *graph.GraphServiceClient) (models.Userable, error) {
 	// <ReadRequestSnippet>
 	// GET https://graph.microsoft.com/v1.0/me
-	result, _ := graphClient.Me().Get(context.Background(), nil)
+	result, err := graphClient.Me().Get(context.Background(), nil)
 	// </ReadRequestSnippet>
 
-	return result
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+
+	return result, nil
 }
 
-func MakeSelectRequest(graphClient *graph.GraphServiceClient) models.Userable {
+func MakeSelectRequest(graphClient *graph.GraphServiceClient) (models.Userable, error) {
 	// <SelectRequestSnippet>
 	// GET https://graph.microsoft.com/v1.0/me?$select=displayName,jobTitle
 
@@ -74,13 +100,17 @@ func MakeSelectRequest(graphClient *graph.GraphServiceClient) models.Userable {
 		QueryParameters: &query,
 	}
 
-	result, _ := graphClient.Me().Get(context.Background(), &options)
+	result, err := graphClient.Me().Get(context.Background(), &options)
 	// </SelectRequestSnippet>
 
-	return result
+	if err != nil {
+		return nil, fmt.Errorf("getting user with $select: %w", err)
+	}
+
+	return result, nil
 }
 
-func MakeListRequest(graphClient *graph.GraphServiceClient) models.MessageCollectionResponseable {
+func MakeListRequest(graphClient *graph.GraphServiceClient) (models.MessageCollectionResponseable, error) {
 	// <ListRequestSnippet>
 	// GET https://graph.microsoft.com/v1.0/me/messages?
 	// $select=subject,sender&$filter=subject eq 'Hello world'
@@ -96,25 +126,33 @@ func MakeListRequest(graphClient *graph.GraphServiceClient) models.MessageCollec
 		QueryParameters: &query,
 	}
 
-	result, _ := graphClient.Me().Messages().
+	result, err := graphClient.Me().Messages().
 		Get(context.Background(), &options)
 	// </ListRequestSnippet>
 
-	return result
+	if err != nil {
+		return nil, fmt.Errorf("listing messages: %w", err)
+	}
+
+	return result, nil
 }
 
-func MakeItemByIdRequest(graphClient *graph.GraphServiceClient, messageId string) models.Messageable {
+func MakeItemByIdRequest(graphClient *graph.GraphServiceClient, messageId string) (models.Messageable, error) {
 	// <ItemByIdRequestSnippet>
 	// GET https://graph.microsoft.com/v1.0/me/messages/{message-id}
 	// messageId is a string containing the id property of the message
-	result, _ := graphClient.Me().Messages().
+	result, err := graphClient.Me().Messages().
 		ByMessageId(messageId).Get(context.Background(), nil)
 	// </ItemByIdRequestSnippet>
 
-	return result
+	if err != nil {
+		return nil, fmt.Errorf("getting message %s: %w", messageId, err)
+	}
+
+	return result, nil
 }
 
-func MakeExpandRequest(graphClient *graph.GraphServiceClient, messageId string) models.Messageable {
+func MakeExpandRequest(graphClient *graph.GraphServiceClient, messageId string) (models.Messageable, error) {
 	// <ExpandRequestSnippet>
 	// GET https://graph.microsoft.com/v1.0/me/messages/{message-id}?$expand=attachments
 
@@ -127,11 +165,15 @@ func MakeExpandRequest(graphClient *graph.GraphServiceClient, messageId string)
 		QueryParameters: &expand,
 	}
 	// messageId is a string containing the id property of the message
-	result, _ := graphClient.Me().Messages().
+	result, err := graphClient.Me().Messages().
 		ByMessageId(messageId).Get(context.Background(), &options)
 	// </ExpandRequestSnippet>
 
-	return result
+	if err != nil {
+		return nil, fmt.Errorf("getting message %s with $expand: %w", messageId, err)
+	}
+
+	return result, nil
 }
 
 func MakeDeleteRequest(graphClient *graph.GraphServiceClient, messageId string) error {
@@ -142,10 +184,14 @@ func MakeDeleteRequest(graphClient *graph.GraphServiceClient, messageId string)
 		ByMessageId(messageId).Delete(context.Background(), nil)
 	// </DeleteRequestSnippet>
 
-	return err
+	if err != nil {
+		return fmt.Errorf("deleting message %s: %w", messageId, err)
+	}
+
+	return nil
 }
 
-func MakeCreateRequest(graphClient *graph.GraphServiceClient) models.Calendarable {
+func MakeCreateRequest(graphClient *graph.GraphServiceClient) (models.Calendarable, error) {
 	// <CreateRequestSnippet>
 	// POST https://graph.microsoft.com/v1.0/me/calendars
 
@@ -153,13 +199,17 @@ func MakeCreateRequest(graphClient *graph.GraphServiceClient) models.Calendarabl
 	name := "Volunteer"
 	calendar.SetName(&name)
 
-	result, _ := graphClient.Me().Calendars().Post(context.Background(), calendar, nil)
+	result, err := graphClient.Me().Calendars().Post(context.Background(), calendar, nil)
 	// </CreateRequestSnippet>
 
-	return result
+	if err != nil {
+		return nil, fmt.Errorf("creating calendar: %w", err)
+	}
+
+	return result, nil
 }
 
-func MakeUpdateRequest(graphClient *graph.GraphServiceClient, teamId string) {
+func MakeUpdateRequest(graphClient *graph.GraphServiceClient, teamId string) (models.Teamable, error) {
 	// <UpdateRequestSnippet>
 	// PATCH https://graph.microsoft.com/v1.0/teams/{team-id}
 
@@ -172,11 +222,17 @@ func MakeUpdateRequest(graphClient *graph.GraphServiceClient, teamId string) {
 	team := models.NewTeam()
 	team.SetFunSettings(funSettings)
 
-	graphClient.Teams().ByTeamId(teamId).Patch(context.Background(), team, nil)
+	result, err := graphClient.Teams().ByTeamId(teamId).Patch(context.Background(), team, nil)
 	// </UpdateRequestSnippet>
+
+	if err != nil {
+		return nil, fmt.Errorf("updating team %s: %w", teamId, err)
+	}
+
+	return result, nil
 }
 
-func MakeHeadersRequest(graphClient *graph.GraphServiceClient) models.EventCollectionResponseable {
+func MakeHeadersRequest(graphClient *graph.GraphServiceClient) (models.EventCollectionResponseable, error) {
 	// <HeadersRequestSnippet>
 	// GET https://graph.microsoft.com/v1.0/me/events
 
@@ -189,13 +245,17 @@ func MakeHeadersRequest(graphClient *graph.GraphServiceClient) models.EventColle
 		Headers: headers,
 	}
 
-	result, _ := graphClient.Me().Events().Get(context.Background(), &options)
+	result, err := graphClient.Me().Events().Get(context.Background(), &options)
 	// </HeadersRequestSnippet>
 
-	return result
+	if err != nil {
+		return nil, fmt.Errorf("getting events: %w", err)
+	}
+
+	return result, nil
 }
 
-func MakeQueryParametersRequest(graphClient *graph.GraphServiceClient) models.EventCollectionResponseable {
+func MakeQueryParametersRequest(graphClient *graph.GraphServiceClient) (models.EventCollectionResponseable, error) {
 	// <QueryParametersRequestSnippet>
 	// GET https://graph.microsoft.com/v1.0/me/calendarView?
 	// startDateTime=2023-06-14T00:00:00Z&endDateTime=2023-06-15T00:00:00Z
@@ -213,8 +273,12 @@ func MakeQueryParametersRequest(graphClient *graph.GraphServiceClient) models.Ev
 		QueryParameters: &query,
 	}
 
-	result, _ := graphClient.Me().CalendarView().Get(context.Background(), &options)
+	result, err := graphClient.Me().CalendarView().Get(context.Background(), &options)
 	// </QueryParametersRequestSnippet>
 
-	return result
+	if err != nil {
+		return nil, fmt.Errorf("getting calendar view: %w", err)
+	}
+
+	return result, nil
 }
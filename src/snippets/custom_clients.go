@@ -5,6 +5,7 @@ package snippets
 
 // <ImportSnippet>
 import (
+	"fmt"
 	"net/http"
 	"net/url"
 
@@ -19,11 +20,14 @@ import (
 
 // </ImportSnippet>
 
-func NewGraphClientWithChaosHandler(credential azcore.TokenCredential, scopes []string) *graph.GraphServiceClient {
+func NewGraphClientWithChaosHandler(credential azcore.TokenCredential, scopes []string) (*graph.GraphServiceClient, error) {
 	// <ChaosHandlerSnippet>
 	// tokenCredential is one of the credential classes from azidentity
 	// scopes is an array of permission scope strings
-	authProvider, _ := authentication.NewAzureIdentityAuthenticationProviderWithScopes(credential, scopes)
+	authProvider, err := authentication.NewAzureIdentityAuthenticationProviderWithScopes(credential, scopes)
+	if err != nil {
+		return nil, fmt.Errorf("creating authentication provider: %w", err)
+	}
 
 	// Get default middleware from SDK
 	defaultClientOptions := graph.GetDefaultClientOptions()
@@ -38,20 +42,26 @@ func NewGraphClientWithChaosHandler(credential azcore.TokenCredential, scopes []
 
 	// Create the adapter
 	// Passing nil values causes the adapter to use default implementations
-	adapter, _ :=
+	adapter, err :=
 		graph.NewGraphRequestAdapterWithParseNodeFactoryAndSerializationWriterFactoryAndHttpClient(
 			authProvider, nil, nil, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("creating request adapter: %w", err)
+	}
 
 	graphClient := graph.NewGraphServiceClient(adapter)
 	// </ChaosHandlerSnippet>
 
-	return graphClient
+	return graphClient, nil
 }
 
-func NewGraphClientWithProxy(scopes []string) *graph.GraphServiceClient {
+func NewGraphClientWithProxy(scopes []string) (*graph.GraphServiceClient, error) {
 	// <ProxySnippet>
 	proxyAddress := "http://proxy-url"
-	proxyUrl, _ := url.Parse(proxyAddress)
+	proxyUrl, err := url.Parse(proxyAddress)
+	if err != nil {
+		return nil, fmt.Errorf("parsing proxy address: %w", err)
+	}
 
 	// Setup proxy for the token credential from azidentity
 	authClient := &http.Client{
@@ -60,35 +70,47 @@ func NewGraphClientWithProxy(scopes []string) *graph.GraphServiceClient {
 		},
 	}
 
-	credential, _ := azidentity.NewClientSecretCredential(
+	credential, err := azidentity.NewClientSecretCredential(
 		"CLIENT_ID", "TENANT_ID", "SECRET", &azidentity.ClientSecretCredentialOptions{
 			// Pass the proxied client to the credential
 			ClientOptions: policy.ClientOptions{
 				Transport: authClient,
 			},
 		})
+	if err != nil {
+		return nil, fmt.Errorf("creating client secret credential: %w", err)
+	}
 
 	// scopes is an array of permission scope strings
-	authProvider, _ := authentication.NewAzureIdentityAuthenticationProviderWithScopes(credential, scopes)
+	authProvider, err := authentication.NewAzureIdentityAuthenticationProviderWithScopes(credential, scopes)
+	if err != nil {
+		return nil, fmt.Errorf("creating authentication provider: %w", err)
+	}
 
 	// Get default middleware from SDK
 	defaultClientOptions := graph.GetDefaultClientOptions()
 	defaultMiddleWare := graphcore.GetDefaultMiddlewaresWithOptions(&defaultClientOptions)
 
 	// Create an HTTP client with the middleware
-	httpClient, _ := khttp.GetClientWithProxySettings(proxyAddress, defaultMiddleWare...)
+	httpClient, err := khttp.GetClientWithProxySettings(proxyAddress, defaultMiddleWare...)
+	if err != nil {
+		return nil, fmt.Errorf("creating proxied HTTP client: %w", err)
+	}
 	// For authenticated proxy, use
 	// khttp.GetClientWithAuthenticatedProxySettings(
 	//     proxyAddress, "user", "password", defaultMiddleWare...)
 
 	// Create the adapter
 	// Passing nil values causes the adapter to use default implementations
-	adapter, _ :=
+	adapter, err :=
 		graph.NewGraphRequestAdapterWithParseNodeFactoryAndSerializationWriterFactoryAndHttpClient(
 			authProvider, nil, nil, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("creating request adapter: %w", err)
+	}
 
 	graphClient := graph.NewGraphServiceClient(adapter)
 	// </ProxySnippet>
 
-	return graphClient
+	return graphClient, nil
 }
@@ -0,0 +1,60 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package snippets
+
+import (
+	"context"
+	"fmt"
+	"sdksnippets/paging"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+)
+
+func SyncInboxMessages(graphClient *graph.GraphServiceClient, stateFile string) (paging.DeltaResult, error) {
+	// <DeltaSyncSnippet>
+	// Delta queries on messages size their pages with a Prefer header
+	headers := abstractions.NewRequestHeaders()
+	headers.Add("Prefer", "odata.maxpagesize=10")
+
+	request, err := graphClient.Me().MailFolders().ByMailFolderId("inbox").Messages().Delta().
+		ToGetRequestInformation(context.Background(),
+			&users.ItemMailFoldersItemMessagesDeltaRequestBuilderGetRequestConfiguration{
+				Headers: headers,
+				QueryParameters: &users.ItemMailFoldersItemMessagesDeltaRequestBuilderGetQueryParameters{
+					Select: []string{"subject"},
+				},
+			})
+	if err != nil {
+		return paging.DeltaResult{}, fmt.Errorf("creating delta request: %w", err)
+	}
+
+	// The delta link is saved to stateFile, so each run
+	// only returns the changes made since the last one
+	store := paging.NewFileCheckpointStore(stateFile)
+
+	result, err := paging.Sync(context.Background(), graphClient, store, "inbox", request,
+		users.CreateItemMailFoldersItemMessagesDeltaGetResponseFromDiscriminatorValue,
+		func(change paging.Change[models.Messageable]) error {
+			switch change.Kind {
+			case paging.Resync:
+				fmt.Println("The saved delta link expired, syncing every message again")
+			case paging.Deleted:
+				fmt.Printf("deleted: %s\n", change.Id)
+			default:
+				fmt.Printf("%s: %s\n", change.Kind, *change.Item.GetSubject())
+			}
+			return nil
+		})
+	if err != nil {
+		return result, fmt.Errorf("syncing inbox: %w", err)
+	}
+
+	fmt.Printf("%d added, %d updated, %d deleted\n", result.Added, result.Updated, result.Deleted)
+	// </DeltaSyncSnippet>
+
+	return result, nil
+}
@@ -6,9 +6,13 @@ package snippets
 // <ImportSnippet>
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	graph "github.com/microsoftgraph/msgraph-sdk-go"
 	"github.com/microsoftgraph/msgraph-sdk-go-core/fileuploader"
@@ -19,33 +23,165 @@ import (
 
 // </ImportSnippet>
 
-func RunUploadSamples(graphClient *graph.GraphServiceClient, largeFile string) {
-	itemPath := "Documents/vacation.gif"
+// The helpers of this repository are not part of the published snippets
+import (
+	"sdksnippets/bandwidth"
+	"sdksnippets/progress"
+	"sdksnippets/transfer"
+	"sync"
+)
+
+func RunUploadSamples(graphClient *graph.GraphServiceClient, largeFile string, dest transfer.Destination,
+	output progress.Output, limiter *bandwidth.Limiter) error {
+	// A stream can only be read once, so it is spooled once for both uploads
+	if largeFile == "-" {
+		spoolPath, err := SpoolStream(os.Stdin, dest.FileName())
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(filepath.Dir(spoolPath))
+		largeFile = spoolPath
+	}
 
-	UploadFileToOneDrive(graphClient, largeFile, itemPath)
-	UploadAttachmentToMessage(graphClient, largeFile)
+	_, err := UploadFileToOneDrive(graphClient, largeFile, dest, output, limiter)
+	if err != nil {
+		return err
+	}
+
+	_, err = UploadAttachmentToMessage(graphClient, largeFile, output, limiter)
+	return err
 }
 
-func UploadFileToOneDrive(graphClient *graph.GraphServiceClient, largeFile string, itemPath string) {
-	// <LargeFileUploadSnippet>
-	byteStream, _ := os.Open(largeFile)
+// UploadFileToOneDrive uploads largeFile to dest, in OneDrive
+// or a SharePoint document library.
+func UploadFileToOneDrive(graphClient *graph.GraphServiceClient, largeFile string, dest transfer.Destination,
+	output progress.Output, limiter *bandwidth.Limiter) (models.DriveItemable, error) {
+	file, err := os.Open(largeFile)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", largeFile, err)
+	}
+	defer file.Close()
+
+	uploadProgress, finish := reportUpload(output, filepath.Base(largeFile), 0)
+	item, err := uploadToDestination(graphClient, bandwidth.LimitByteStream(context.Background(), file, limiter),
+		dest, uploadProgress)
+	finish()
+	if err != nil {
+		return nil, err
+	}
+
+	// Check that the content in OneDrive matches the local file
+	err = transfer.VerifyItem(item, largeFile)
+	if err != nil {
+		return nil, err
+	}
 
+	return item, nil
+}
+
+// UploadStreamToOneDrive uploads a stream of unknown length, such as
+// standard input, to dest.
+func UploadStreamToOneDrive(graphClient *graph.GraphServiceClient, stream io.Reader, name string,
+	dest transfer.Destination, output progress.Output, limiter *bandwidth.Limiter) (models.DriveItemable, error) {
+	spoolPath, err := SpoolStream(stream, name)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(filepath.Dir(spoolPath))
+
+	return UploadFileToOneDrive(graphClient, spoolPath, dest, output, limiter)
+}
+
+// SpoolStream reads stream to its end into a file called name in a new
+// temporary folder, and returns the path of the file. The caller removes
+// the folder once the file is uploaded.
+func SpoolStream(stream io.Reader, name string) (string, error) {
+	// <StreamUploadSnippet>
+	// An upload session needs the final size, so the stream is read
+	// to its end first, into a temporary file
+	spoolDir, err := os.MkdirTemp("", "upload-")
+	if err != nil {
+		return "", fmt.Errorf("creating temporary folder: %w", err)
+	}
+	spoolPath := filepath.Join(spoolDir, filepath.Base(name))
+	spoolFile, err := os.Create(spoolPath)
+	if err != nil {
+		os.RemoveAll(spoolDir)
+		return "", fmt.Errorf("creating temporary file: %w", err)
+	}
+	size, err := io.Copy(spoolFile, stream)
+	err = errors.Join(err, spoolFile.Close())
+	if err != nil {
+		os.RemoveAll(spoolDir)
+		return "", fmt.Errorf("reading %s: %w", name, err)
+	}
+
+	// Upload sessions cannot create empty files
+	if size == 0 {
+		os.RemoveAll(spoolDir)
+		return "", fmt.Errorf("%s is empty", name)
+	}
+
+	// The temporary file is then uploaded like any other file
+	// </StreamUploadSnippet>
+
+	return spoolPath, nil
+}
+
+// uploadToDestination uploads byteStream to dest with UploadToOneDrive,
+// and reports the name Graph gave the file if it was renamed
+func uploadToDestination(graphClient *graph.GraphServiceClient, byteStream fileuploader.ByteStream,
+	dest transfer.Destination, progress func(progress int64, total int64)) (models.DriveItemable, error) {
+	err := dest.Validate()
+	if err != nil {
+		return nil, err
+	}
+	driveId, err := dest.ResolveDriveId(context.Background(), graphClient)
+	if err != nil {
+		return nil, err
+	}
+
+	folderId, itemPath := dest.Folder()
+	item, err := UploadToOneDrive(graphClient, byteStream, driveId, folderId, itemPath,
+		string(dest.ConflictBehavior()), progress)
+	if err != nil {
+		return nil, err
+	}
+
+	// With the rename conflict behavior, Graph may choose another name
+	if finalName, renamed := dest.Renamed(item); renamed {
+		fmt.Printf("%s already exists, so the file was named %s\n", dest.FileName(), finalName)
+	}
+
+	return item, nil
+}
+
+// <LargeFileUploadSnippet>
+// UploadToOneDrive uploads byteStream, such as an *os.File, to itemPath
+// in the folder folderId of the drive driveId, and calls progress after
+// each slice is uploaded. Use root as folderId for a path relative to
+// the root of the drive. conflictBehavior is fail, replace or rename.
+func UploadToOneDrive(graphClient *graph.GraphServiceClient, byteStream fileuploader.ByteStream,
+	driveId string, folderId string, itemPath string, conflictBehavior string,
+	progress func(progress int64, total int64)) (models.DriveItemable, error) {
 	// Use properties to specify the conflict behavior
 	itemUploadProperties := models.NewDriveItemUploadableProperties()
-	itemUploadProperties.SetAdditionalData(map[string]any{"@microsoft.graph.conflictBehavior": "replace"})
+	itemUploadProperties.SetAdditionalData(map[string]any{"@microsoft.graph.conflictBehavior": conflictBehavior})
 	uploadSessionRequestBody := drives.NewItemItemsItemCreateUploadSessionPostRequestBody()
 	uploadSessionRequestBody.SetItem(itemUploadProperties)
 
-	// Create the upload session
-	// itemPath does not need to be a path to an existing item
-	myDrive, _ := graphClient.Me().Drive().Get(context.Background(), nil)
-
-	uploadSession, _ := graphClient.Drives().
-		ByDriveId(*myDrive.GetId()).
+	// Create the upload session. The item is addressed by its path in
+	// the folder, such as root:/Documents/report.pdf:, and does not
+	// need to exist yet.
+	uploadSession, err := graphClient.Drives().
+		ByDriveId(driveId).
 		Items().
-		ByDriveItemId("root:/"+itemPath+":").
+		ByDriveItemId(folderId+":/"+itemPath+":").
 		CreateUploadSession().
 		Post(context.Background(), uploadSessionRequestBody, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating upload session: %w", err)
+	}
 
 	// Max slice size must be a multiple of 320 KiB
 	maxSliceSize := int64(320 * 1024)
@@ -57,59 +193,103 @@ func UploadFileToOneDrive(graphClient *graph.GraphServiceClient, largeFile strin
 		models.CreateDriveItemFromDiscriminatorValue,
 		nil)
 
-	// Create a callback that is invoked after each slice is uploaded
-	progress := func(progress int64, total int64) {
-		fmt.Printf("Uploaded %d of %d bytes\n", progress, total)
-	}
-
 	// Upload the file
 	uploadResult := fileUploadTask.Upload(progress)
 
-	if uploadResult.GetUploadSucceeded() {
-		fmt.Printf("Upload complete, item ID: %s\n", *uploadResult.GetItemResponse().GetId())
-	} else {
-		fmt.Print("Upload failed.\n")
+	if !uploadResult.GetUploadSucceeded() {
+		return nil, fmt.Errorf("uploading file: %w",
+			errors.Join(uploadResult.GetResponseErrors()...))
 	}
-	// </LargeFileUploadSnippet>
+
+	item := uploadResult.GetItemResponse()
+	fmt.Printf("Upload complete, item ID: %s\n", *item.GetId())
+	return item, nil
 }
 
+// </LargeFileUploadSnippet>
+
 func ResumeUpload(
 	fileUploadTask fileuploader.LargeFileUploadTask[models.DriveItemable],
-	progress fileuploader.ProgressCallBack) {
+	progress fileuploader.ProgressCallBack) (fileuploader.UploadResult[models.DriveItemable], error) {
 	// <ResumeSnippet>
-	fileUploadTask.Resume(progress)
+	uploadResult, err := fileUploadTask.Resume(progress)
 	// </ResumeSnippet>
+
+	if err != nil {
+		return nil, fmt.Errorf("resuming upload: %w", err)
+	}
+
+	return uploadResult, nil
+}
+
+func UploadAttachmentToMessage(graphClient *graph.GraphServiceClient, largeFile string,
+	output progress.Output, limiter *bandwidth.Limiter) (models.Messageable, error) {
+	file, err := os.Open(largeFile)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", largeFile, err)
+	}
+	defer file.Close()
+
+	uploadProgress, finish := reportUpload(output, filepath.Base(largeFile), 0)
+	defer finish()
+	return UploadLargeAttachment(graphClient, bandwidth.LimitByteStream(context.Background(), file, limiter),
+		filepath.Base(largeFile), uploadProgress)
+}
+
+// UploadStreamAttachmentToMessage attaches a stream of unknown length,
+// such as standard input, to a new draft message.
+func UploadStreamAttachmentToMessage(graphClient *graph.GraphServiceClient, stream io.Reader, name string,
+	output progress.Output, limiter *bandwidth.Limiter) (models.Messageable, error) {
+	// The attachment size is needed up front, as for OneDrive
+	spoolPath, err := SpoolStream(stream, name)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(filepath.Dir(spoolPath))
+
+	return UploadAttachmentToMessage(graphClient, spoolPath, output, limiter)
 }
 
-func UploadAttachmentToMessage(graphClient *graph.GraphServiceClient, largeFile string) {
-	// <UploadAttachmentSnippet>
+// <UploadAttachmentSnippet>
+// UploadLargeAttachment attaches byteStream, such as an *os.File, to a
+// new draft message as fileName, and calls progress after each slice
+// is uploaded.
+func UploadLargeAttachment(graphClient *graph.GraphServiceClient, byteStream fileuploader.ByteStream, fileName string,
+	progress func(progress int64, total int64)) (models.Messageable, error) {
 	// Create message
 	message := models.NewMessage()
 	subject := "Large attachment"
 	message.SetSubject(&subject)
 
-	savedDraft, _ := graphClient.Me().Messages().Post(context.Background(), message, nil)
+	savedDraft, err := graphClient.Me().Messages().Post(context.Background(), message, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating draft message: %w", err)
+	}
 
 	// Set up the attachment
-	byteStream, _ := os.Open(largeFile)
 	largeAttachment := models.NewAttachmentItem()
 	attachmentType := models.FILE_ATTACHMENTTYPE
 	largeAttachment.SetAttachmentType(&attachmentType)
-	fileName := filepath.Base(largeFile)
 	largeAttachment.SetName(&fileName)
-	fileInfo, _ := byteStream.Stat()
+	fileInfo, err := byteStream.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("reading size of %s: %w", fileName, err)
+	}
 	fileSize := fileInfo.Size()
 	largeAttachment.SetSize(&fileSize)
 
 	uploadSessionRequestBody := users.NewItemMessagesItemAttachmentsCreateUploadSessionPostRequestBody()
 	uploadSessionRequestBody.SetAttachmentItem(largeAttachment)
 
-	uploadSession, _ := graphClient.Me().
+	uploadSession, err := graphClient.Me().
 		Messages().
 		ByMessageId(*savedDraft.GetId()).
 		Attachments().
 		CreateUploadSession().
 		Post(context.Background(), uploadSessionRequestBody, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating upload session: %w", err)
+	}
 
 	// Max slice size must be a multiple of 320 KiB
 	maxSliceSize := int64(320 * 1024)
@@ -121,18 +301,173 @@ func UploadAttachmentToMessage(graphClient *graph.GraphServiceClient, largeFile
 		models.CreateFileAttachmentFromDiscriminatorValue,
 		nil)
 
-	// Create a callback that is invoked after each slice is uploaded
-	progress := func(progress int64, total int64) {
-		fmt.Printf("Uploaded %d of %d bytes\n", progress, total)
-	}
-
 	// Upload the file
 	uploadResult := fileUploadTask.Upload(progress)
 
-	if uploadResult.GetUploadSucceeded() {
-		fmt.Print("Upload complete\n")
+	if !uploadResult.GetUploadSucceeded() {
+		return nil, fmt.Errorf("uploading attachment: %w",
+			errors.Join(uploadResult.GetResponseErrors()...))
+	}
+
+	fmt.Print("Upload complete\n")
+	return savedDraft, nil
+}
+
+// </UploadAttachmentSnippet>
+
+// AttachFilesToItem attaches files to a message or an event with
+// AttachFile, and returns the IDs of the created attachments. Files in
+// inline are attached as inline images, which the body refers to as cid:
+// followed by the file name.
+func AttachFilesToItem(graphClient *graph.GraphServiceClient, itemType string, itemId string,
+	files []string, inline []string, limiter *bandwidth.Limiter) ([]string, error) {
+	// The inline images come after the other files, so a file given in
+	// both lists is attached once of each kind
+	var ids []string
+	for i, localFile := range slices.Concat(files, inline) {
+		id, err := attachLocalFile(graphClient, itemType, itemId, localFile, i >= len(files), limiter)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+
+	fmt.Printf("Attached %d files to %s %s\n", len(ids), itemType, itemId)
+	return ids, nil
+}
+
+// attachLocalFile attaches the file at localFile with AttachFile
+func attachLocalFile(graphClient *graph.GraphServiceClient, itemType string, itemId string,
+	localFile string, isInline bool, limiter *bandwidth.Limiter) (string, error) {
+	file, err := os.Open(localFile)
+	if err != nil {
+		return "", fmt.Errorf("opening %s: %w", localFile, err)
+	}
+	defer file.Close()
+
+	return AttachFile(graphClient, itemType, itemId, bandwidth.LimitByteStream(context.Background(), file, limiter),
+		filepath.Base(localFile), isInline)
+}
+
+// AttachFile attaches content, such as an *os.File, to a message or an
+// event as fileName, and returns the ID of the created attachment. An
+// inline image is shown in the body, which refers to it as cid:fileName.
+func AttachFile(graphClient *graph.GraphServiceClient, itemType string, itemId string,
+	content fileuploader.ByteStream, fileName string, isInline bool) (string, error) {
+	// <AttachFilesSnippet>
+	// Messages and events take attachments the same way
+	if itemType != "message" && itemType != "event" {
+		return "", fmt.Errorf("cannot attach files to a %s", itemType)
+	}
+
+	fileInfo, err := content.Stat()
+	if err != nil {
+		return "", fmt.Errorf("reading size of %s: %w", fileName, err)
+	}
+	fileSize := fileInfo.Size()
+
+	// Files under 3 MB are posted directly
+	if fileSize < 3*1024*1024 {
+		contentBytes, err := io.ReadAll(io.NewSectionReader(content, 0, fileSize))
+		if err != nil {
+			return "", fmt.Errorf("reading %s: %w", fileName, err)
+		}
+		fileAttachment := models.NewFileAttachment()
+		fileAttachment.SetName(&fileName)
+		fileAttachment.SetContentBytes(contentBytes)
+		if isInline {
+			fileAttachment.SetIsInline(&isInline)
+			fileAttachment.SetContentId(&fileName)
+		}
+
+		var attachment models.Attachmentable
+		if itemType == "message" {
+			attachment, err = graphClient.Me().Messages().ByMessageId(itemId).
+				Attachments().Post(context.Background(), fileAttachment, nil)
+		} else {
+			attachment, err = graphClient.Me().Events().ByEventId(itemId).
+				Attachments().Post(context.Background(), fileAttachment, nil)
+		}
+		if err != nil {
+			return "", fmt.Errorf("attaching %s: %w", fileName, err)
+		}
+
+		return *attachment.GetId(), nil
+	}
+
+	// Larger files are sent through an upload session
+	largeAttachment := models.NewAttachmentItem()
+	attachmentType := models.FILE_ATTACHMENTTYPE
+	largeAttachment.SetAttachmentType(&attachmentType)
+	largeAttachment.SetName(&fileName)
+	largeAttachment.SetSize(&fileSize)
+	if isInline {
+		largeAttachment.SetIsInline(&isInline)
+		largeAttachment.SetContentId(&fileName)
+	}
+
+	var uploadSession models.UploadSessionable
+	if itemType == "message" {
+		uploadSessionRequestBody := users.NewItemMessagesItemAttachmentsCreateUploadSessionPostRequestBody()
+		uploadSessionRequestBody.SetAttachmentItem(largeAttachment)
+		uploadSession, err = graphClient.Me().Messages().ByMessageId(itemId).
+			Attachments().CreateUploadSession().Post(context.Background(), uploadSessionRequestBody, nil)
 	} else {
-		fmt.Print("Upload failed.\n")
+		uploadSessionRequestBody := users.NewItemEventsItemAttachmentsCreateUploadSessionPostRequestBody()
+		uploadSessionRequestBody.SetAttachmentItem(largeAttachment)
+		uploadSession, err = graphClient.Me().Events().ByEventId(itemId).
+			Attachments().CreateUploadSession().Post(context.Background(), uploadSessionRequestBody, nil)
+	}
+	if err != nil {
+		return "", fmt.Errorf("creating upload session for %s: %w", fileName, err)
+	}
+
+	// Max slice size must be a multiple of 320 KiB
+	maxSliceSize := int64(320 * 1024)
+	fileUploadTask := fileuploader.NewLargeFileUploadTask[models.FileAttachmentable](
+		graphClient.RequestAdapter,
+		uploadSession,
+		content,
+		maxSliceSize,
+		models.CreateFileAttachmentFromDiscriminatorValue,
+		nil)
+
+	uploadResult := fileUploadTask.Upload(func(progress int64, total int64) {})
+	if !uploadResult.GetUploadSucceeded() {
+		return "", fmt.Errorf("uploading %s: %w", fileName,
+			errors.Join(uploadResult.GetResponseErrors()...))
 	}
-	// </UploadAttachmentSnippet>
+
+	// The Location header is the URL of the attachment, which ends
+	// in its ID as /attachments/{id} or, from Outlook, /Attachments('{id}')
+	if uploadResult.GetURI() == nil {
+		return "", fmt.Errorf("uploading %s: no attachment location", fileName)
+	}
+	location := *uploadResult.GetURI()
+	attachmentId := location[strings.LastIndex(location, "/")+1:]
+	if open := strings.Index(attachmentId, "('"); open >= 0 {
+		attachmentId = strings.TrimSuffix(attachmentId[open+2:], "')")
+	}
+	// </AttachFilesSnippet>
+
+	return attachmentId, nil
+}
+
+// reportUpload starts reporting an upload of name to output. It returns
+// the callback to pass to the upload and the function that makes the
+// last report. If size is 0, it is taken from the first callback.
+func reportUpload(output progress.Output, name string, size int64) (func(progress int64, total int64), func()) {
+	reporter := progress.NewReporter(output, progress.Options{})
+	uploadProgress := reporter.Start(name, size).UploadProgress()
+
+	// The last report is made as soon as the last slice is uploaded,
+	// so that it comes before the messages the snippets print
+	var finished sync.Once
+	finish := func() { finished.Do(reporter.Finish) }
+	return func(current int64, total int64) {
+		uploadProgress(current, total)
+		if current+1 == total {
+			finish()
+		}
+	}, finish
 }
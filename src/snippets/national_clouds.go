@@ -5,6 +5,8 @@ package snippets
 
 // <ImportSnippet>
 import (
+	"fmt"
+
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
@@ -14,11 +16,11 @@ import (
 
 // </ImportSnippet>
 
-func NewGraphClientForUsGov() *graph.GraphServiceClient {
+func NewGraphClientForUsGov() (*graph.GraphServiceClient, error) {
 	// <NationalCloudSnippet>
 	// Create the InteractiveBrowserCredential using details
 	// from app registered in the Azure AD for US Government portal
-	credential, _ := azidentity.NewInteractiveBrowserCredential(
+	credential, err := azidentity.NewInteractiveBrowserCredential(
 		&azidentity.InteractiveBrowserCredentialOptions{
 			ClientID: "YOUR_CLIENT_ID",
 			TenantID: "YOUR_TENANT_ID",
@@ -28,13 +30,22 @@ func NewGraphClientForUsGov() *graph.GraphServiceClient {
 			},
 			RedirectURL: "YOUR_REDIRECT_URL",
 		})
+	if err != nil {
+		return nil, fmt.Errorf("creating interactive browser credential: %w", err)
+	}
 
 	// Create the authentication provider
-	authProvider, _ := auth.NewAzureIdentityAuthenticationProviderWithScopes(credential,
+	authProvider, err := auth.NewAzureIdentityAuthenticationProviderWithScopes(credential,
 		[]string{"https://graph.microsoft.us/.default"})
+	if err != nil {
+		return nil, fmt.Errorf("creating authentication provider: %w", err)
+	}
 
 	// Create a request adapter using the auth provider
-	adapter, _ := graph.NewGraphRequestAdapter(authProvider)
+	adapter, err := graph.NewGraphRequestAdapter(authProvider)
+	if err != nil {
+		return nil, fmt.Errorf("creating request adapter: %w", err)
+	}
 
 	// Set the service root to the
 	// Microsoft Graph for US Government L4 endpoint
@@ -45,5 +56,5 @@ func NewGraphClientForUsGov() *graph.GraphServiceClient {
 	graphClient := graph.NewGraphServiceClient(adapter)
 	// </NationalCloudSnippet>
 
-	return graphClient
+	return graphClient, nil
 }
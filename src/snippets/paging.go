@@ -7,7 +7,6 @@ package snippets
 import (
 	"context"
 	"fmt"
-	"log"
 	"time"
 
 	abstractions "github.com/microsoft/kiota-abstractions-go"
@@ -19,17 +18,25 @@ import (
 
 // </ImportSnippet>
 
-func RunPagingSamples(graphClient *graph.GraphServiceClient) {
-	IterateAllMessages(graphClient)
-	IterateAllMessagesWithPause(graphClient)
+func RunPagingSamples(graphClient *graph.GraphServiceClient, pageSize int32) error {
+	_, err := IterateAllMessages(graphClient, pageSize)
+	if err != nil {
+		return err
+	}
+
+	_, err = IterateAllMessagesWithPause(graphClient, pageSize)
+	return err
 }
 
-func IterateAllMessages(graphClient *graph.GraphServiceClient) {
+// IterateAllMessages prints the subject of every message in the
+// mailbox, requesting messagesPerPage messages per page.
+func IterateAllMessages(graphClient *graph.GraphServiceClient, messagesPerPage int32) (int, error) {
 	// <PagingSnippet>
 	headers := abstractions.NewRequestHeaders()
 	headers.Add("Prefer", "outlook.body-content-type=\"text\"")
 
-	var pageSize int32 = 10
+	// messagesPerPage is the number of messages to request per page, such as 10
+	var pageSize int32 = messagesPerPage
 	query := users.ItemMessagesRequestBuilderGetQueryParameters{
 		Select: []string{"body", "sender", "subject"},
 		Top:    &pageSize,
@@ -42,7 +49,7 @@ func IterateAllMessages(graphClient *graph.GraphServiceClient) {
 
 	result, err := graphClient.Me().Messages().Get(context.Background(), &options)
 	if err != nil {
-		log.Fatalf("Error getting messages: %v\n", err)
+		return 0, fmt.Errorf("getting messages: %w", err)
 	}
 
 	// Initialize iterator
@@ -51,7 +58,7 @@ func IterateAllMessages(graphClient *graph.GraphServiceClient) {
 		graphClient.GetAdapter(),
 		models.CreateMessageCollectionResponseFromDiscriminatorValue)
 	if err != nil {
-		log.Fatalf("Error creating page iterator: %v\n", err)
+		return 0, fmt.Errorf("creating page iterator: %w", err)
 	}
 
 	// Any custom headers sent in original request should also be added
@@ -59,22 +66,29 @@ func IterateAllMessages(graphClient *graph.GraphServiceClient) {
 	pageIterator.SetHeaders(headers)
 
 	// Iterate over all pages
+	count := 0
 	err = pageIterator.Iterate(
 		context.Background(),
 		func(message *models.Message) bool {
+			count++
 			fmt.Printf("%s\n", *message.GetSubject())
 			// Return true to continue the iteration
 			return true
 		})
 	if err != nil {
-		log.Fatalf("Error iterating over messages: %v\n", err)
+		return count, fmt.Errorf("iterating over messages: %w", err)
 	}
 	// </PagingSnippet>
+
+	return count, nil
 }
 
-func IterateAllMessagesWithPause(graphClient *graph.GraphServiceClient) {
+// IterateAllMessagesWithPause pages through the mailbox like
+// IterateAllMessages, pausing after 25 messages and then resuming.
+func IterateAllMessagesWithPause(graphClient *graph.GraphServiceClient, messagesPerPage int32) (int, error) {
 	// <ResumePagingSnippet>
-	var pageSize int32 = 10
+	// messagesPerPage is the number of messages to request per page, such as 10
+	var pageSize int32 = messagesPerPage
 	query := users.ItemMessagesRequestBuilderGetQueryParameters{
 		Select: []string{"body", "sender", "subject"},
 		Top:    &pageSize,
@@ -86,7 +100,7 @@ func IterateAllMessagesWithPause(graphClient *graph.GraphServiceClient) {
 
 	result, err := graphClient.Me().Messages().Get(context.Background(), &options)
 	if err != nil {
-		log.Fatalf("Error getting messages: %v\n", err)
+		return 0, fmt.Errorf("getting messages: %w", err)
 	}
 
 	// Initialize iterator
@@ -95,7 +109,7 @@ func IterateAllMessagesWithPause(graphClient *graph.GraphServiceClient) {
 		graphClient.GetAdapter(),
 		models.CreateMessageCollectionResponseFromDiscriminatorValue)
 	if err != nil {
-		log.Fatalf("Error creating page iterator: %v\n", err)
+		return 0, fmt.Errorf("creating page iterator: %w", err)
 	}
 
 	// Pause iterating after 25
@@ -112,7 +126,7 @@ func IterateAllMessagesWithPause(graphClient *graph.GraphServiceClient) {
 			return count < pauseAfter
 		})
 	if err != nil {
-		log.Fatalf("Error iterating over messages: %v\n", err)
+		return count, fmt.Errorf("iterating over messages: %w", err)
 	}
 
 	// Pause 5 seconds
@@ -130,12 +144,14 @@ func IterateAllMessagesWithPause(graphClient *graph.GraphServiceClient) {
 			return true
 		})
 	if err != nil {
-		log.Fatalf("Error iterating over messages: %v\n", err)
+		return count, fmt.Errorf("iterating over messages: %w", err)
 	}
 	// </ResumePagingSnippet>
+
+	return count, nil
 }
 
-func ManuallyPageAllMessages(graphClient *graph.GraphBaseServiceClient) {
+func ManuallyPageAllMessages(graphClient *graph.GraphBaseServiceClient) (int, error) {
 	// <ManualPagingSnippet>
 	var pageSize int32 = 10
 	query := users.ItemMessagesRequestBuilderGetQueryParameters{
@@ -148,11 +164,13 @@ func ManuallyPageAllMessages(graphClient *graph.GraphBaseServiceClient) {
 
 	result, err := graphClient.Me().Messages().Get(context.Background(), &options)
 	if err != nil {
-		log.Fatalf("Error getting messages: %v\n", err)
+		return 0, fmt.Errorf("getting messages: %w", err)
 	}
 
+	count := 0
 	for {
 		for _, message := range result.GetValue() {
+			count++
 			fmt.Printf("%s\n", *message.GetSubject())
 		}
 
@@ -162,11 +180,13 @@ func ManuallyPageAllMessages(graphClient *graph.GraphBaseServiceClient) {
 				WithUrl(*nextPageUrl).
 				Get(context.Background(), nil)
 			if err != nil {
-				log.Fatalf("Error getting messages: %v\n", err)
+				return count, fmt.Errorf("getting messages: %w", err)
 			}
 		} else {
 			break
 		}
 	}
 	// </ManualPagingSnippet>
+
+	return count, nil
 }
@@ -0,0 +1,104 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package snippets
+
+import (
+	"context"
+	"fmt"
+	"sdksnippets/paging"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+)
+
+func RangeOverAllMessages(graphClient *graph.GraphServiceClient) (int, error) {
+	// <RangePagingSnippet>
+	headers := abstractions.NewRequestHeaders()
+	headers.Add("Prefer", "outlook.body-content-type=\"text\"")
+
+	var pageSize int32 = 10
+	query := users.ItemMessagesRequestBuilderGetQueryParameters{
+		Select: []string{"body", "sender", "subject"},
+		Top:    &pageSize,
+	}
+
+	options := users.ItemMessagesRequestBuilderGetRequestConfiguration{
+		Headers:         headers,
+		QueryParameters: &query,
+	}
+
+	request, err := graphClient.Me().Messages().
+		ToGetRequestInformation(context.Background(), &options)
+	if err != nil {
+		return 0, fmt.Errorf("creating GET /me/messages request: %w", err)
+	}
+
+	// paging.FromRequest sends the request, then requests each next page
+	// with the same headers as the loop reaches it
+	count := 0
+	for message, err := range paging.FromRequest[*models.Message](
+		context.Background(),
+		graphClient,
+		request,
+		models.CreateMessageCollectionResponseFromDiscriminatorValue) {
+		if err != nil {
+			return count, fmt.Errorf("iterating over messages: %w", err)
+		}
+
+		count++
+		fmt.Printf("%s\n", *message.GetSubject())
+	}
+	// </RangePagingSnippet>
+
+	return count, nil
+}
+
+func ResumeMessagesFromCheckpoint(graphClient *graph.GraphServiceClient, checkpointFile string, limit int32) (int, error) {
+	// <CheckpointPagingSnippet>
+	var pageSize int32 = 10
+	query := users.ItemMessagesRequestBuilderGetQueryParameters{
+		Select: []string{"subject"},
+		Top:    &pageSize,
+	}
+
+	request, err := graphClient.Me().Messages().
+		ToGetRequestInformation(context.Background(),
+			&users.ItemMessagesRequestBuilderGetRequestConfiguration{
+				QueryParameters: &query,
+			})
+	if err != nil {
+		return 0, fmt.Errorf("creating GET /me/messages request: %w", err)
+	}
+
+	// The position is saved to checkpointFile as the loop runs. If this
+	// function stops early, the next call continues from the same message.
+	store := paging.NewFileCheckpointStore(checkpointFile)
+
+	count := 0
+	for message, err := range paging.Resumable[*models.Message](
+		context.Background(),
+		graphClient,
+		store,
+		"messages",
+		request,
+		models.CreateMessageCollectionResponseFromDiscriminatorValue) {
+		if err != nil {
+			return count, fmt.Errorf("iterating over messages: %w", err)
+		}
+
+		// limit simulates an interrupted run
+		if limit > 0 && count == int(limit) {
+			fmt.Printf("Stopping after %d messages, run again to resume\n", count)
+			break
+		}
+
+		count++
+		fmt.Printf("%s\n", *message.GetSubject())
+	}
+	// </CheckpointPagingSnippet>
+
+	return count, nil
+}
@@ -0,0 +1,616 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package snippets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sdksnippets/bandwidth"
+	"sdksnippets/progress"
+	"sdksnippets/transfer"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+)
+
+// Snippet describes a runnable snippet. Name matches the doc tag
+// used in the source, for example SimpleBatchSnippet.
+type Snippet struct {
+	Name        string
+	Group       string
+	Description string
+	// Scopes lists the delegated permissions the snippet needs
+	Scopes []string
+	// Mutates is true if the snippet creates, updates or deletes data
+	Mutates bool
+	Inputs  []Input
+	Run     func(graphClient *graph.GraphServiceClient, inputs Inputs) error
+}
+
+// Input describes a value a snippet needs in order to run.
+type Input struct {
+	Name        string
+	Description string
+	Default     string
+	// Env names an environment variable that, if set,
+	// replaces Default when the snippet is run from the command line
+	Env      string
+	Required bool
+}
+
+// Inputs holds input values keyed by Input.Name.
+type Inputs map[string]string
+
+func (inputs Inputs) Int32(name string) (int32, error) {
+	value, err := strconv.ParseInt(inputs[name], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for %s: %w", name, err)
+	}
+
+	return int32(value), nil
+}
+
+// List splits input name at commas, ignoring empty values.
+func (inputs Inputs) List(name string) []string {
+	var values []string
+	for _, value := range strings.Split(inputs[name], ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// Destination returns the upload destination described
+// by the inputs in destinationInputs.
+func (inputs Inputs) Destination() (transfer.Destination, error) {
+	conflict, err := transfer.ParseConflictBehavior(inputs["conflict"])
+	if err != nil {
+		return transfer.Destination{}, fmt.Errorf("invalid value for conflict: %w", err)
+	}
+	dest := transfer.Destination{
+		DriveId:  inputs["drive-id"],
+		SiteId:   inputs["site"],
+		Library:  inputs["library"],
+		Path:     inputs["dest"],
+		Conflict: conflict,
+	}
+	// With a parent folder, dest only names the file
+	if parentId := inputs["parent-id"]; parentId != "" {
+		dest.ParentId, dest.Name, dest.Path = parentId, path.Base(dest.Path), ""
+	}
+
+	return dest, dest.Validate()
+}
+
+// Progress returns an output of the style named by input name. A bar is
+// drawn on standard error, and JSON events are written to standard
+// output, where they are the only lines that are JSON objects.
+func (inputs Inputs) Progress(name string) (progress.Output, error) {
+	style := progress.Style(inputs[name])
+	if style == progress.JSONStyle {
+		return progress.NewJSON(os.Stdout), nil
+	}
+
+	output, err := progress.NewOutput(style, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %w", name, err)
+	}
+
+	return output, nil
+}
+
+// Limiter parses input name with bandwidth.ParseLimit. It
+// returns nil if the input is empty.
+func (inputs Inputs) Limiter(name string) (*bandwidth.Limiter, error) {
+	limiter, err := bandwidth.ParseLimit(inputs[name])
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %w", name, err)
+	}
+
+	return limiter, nil
+}
+
+// HasScope returns true if the snippet requires the given scope.
+// The comparison is case-insensitive.
+func (snippet Snippet) HasScope(scope string) bool {
+	for _, s := range snippet.Scopes {
+		if strings.EqualFold(s, scope) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ResolveInputs applies defaults to values and checks that
+// every required input is present.
+func (snippet Snippet) ResolveInputs(values Inputs) (Inputs, error) {
+	resolved := Inputs{}
+	for _, input := range snippet.Inputs {
+		value := values[input.Name]
+		if value == "" {
+			value = input.Default
+		}
+		if value == "" && input.Required {
+			return nil, fmt.Errorf("%s requires input %s", snippet.Name, input.Name)
+		}
+		resolved[input.Name] = value
+	}
+
+	return resolved, nil
+}
+
+var (
+	pageSizeInput = Input{
+		Name:        "page-size",
+		Description: "number of messages to request per page",
+		Default:     "10",
+	}
+	messageIdInput = Input{
+		Name:        "message-id",
+		Description: "ID of a message in the signed-in user's mailbox",
+		Required:    true,
+	}
+	largeFileInput = Input{
+		Name:        "file",
+		Description: "path to the local file to upload, or - for standard input",
+		Env:         "LARGE_FILE_PATH",
+		Required:    true,
+	}
+	progressInput = Input{
+		Name:        "progress",
+		Description: "how to show progress: bar, json or quiet",
+		Default:     string(progress.BarStyle),
+	}
+	limitInput = Input{
+		Name:        "limit",
+		Description: "bandwidth cap, such as 2MiB or 09:00-18:00=512KiB,4MiB; empty is unlimited",
+		Env:         "BANDWIDTH_LIMIT",
+	}
+	// destinationInputs are read by Inputs.Destination
+	destinationInputs = []Input{{
+		Name:        "dest",
+		Description: "destination path, relative to the drive root, or the file name if parent-id is given",
+		Default:     "Documents/vacation.gif",
+	}, {
+		Name:        "drive-id",
+		Description: "ID of the drive to upload to; empty is the signed-in user's OneDrive",
+	}, {
+		Name:        "site",
+		Description: "ID or hostname:/path: of a SharePoint site to upload to",
+	}, {
+		Name:        "library",
+		Description: "name of a document library in site; empty is the site's default library",
+	}, {
+		Name:        "parent-id",
+		Description: "ID of the folder to upload into, instead of a path",
+	}, {
+		Name:        "conflict",
+		Description: "what to do if the destination exists: fail, replace or rename",
+		Default:     string(transfer.ConflictReplace),
+	}}
+)
+
+var registry = map[string]Snippet{}
+
+func register(snippet Snippet) {
+	if _, exists := registry[snippet.Name]; exists {
+		panic("snippets: duplicate snippet " + snippet.Name)
+	}
+	registry[snippet.Name] = snippet
+}
+
+// Lookup returns the snippet registered with the given doc tag name.
+func Lookup(name string) (Snippet, bool) {
+	snippet, ok := registry[name]
+	return snippet, ok
+}
+
+// All returns every registered snippet, sorted by group and name.
+func All() []Snippet {
+	all := make([]Snippet, 0, len(registry))
+	for _, snippet := range registry {
+		all = append(all, snippet)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Group != all[j].Group {
+			return all[i].Group < all[j].Group
+		}
+		return all[i].Name < all[j].Name
+	})
+
+	return all
+}
+
+func init() {
+	// Batch samples
+	register(Snippet{
+		Name:        "SimpleBatchSnippet",
+		Group:       "batch",
+		Description: "Batch GET /me and GET /me/calendarView",
+		Scopes:      []string{"User.Read", "Calendars.Read"},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := SimpleBatch(graphClient)
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "DependentBatchSnippet",
+		Group:       "batch",
+		Description: "Batch POST /me/events and a dependent GET /me/calendarView",
+		Scopes:      []string{"Calendars.ReadWrite"},
+		Mutates:     true,
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := DependentBatch(graphClient)
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "ChunkedBatchSnippet",
+		Group:       "batch",
+		Description: "Batch GET /me/messages/{message-id} for 25 messages, split into Graph-sized batches",
+		Scopes:      []string{"Mail.Read"},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := ChunkedBatch(graphClient)
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "TypedBatchSnippet",
+		Group:       "batch",
+		Description: "Batch GET /me and GET /me/messages, reading each result without failing on errors",
+		Scopes:      []string{"User.Read", "Mail.Read"},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := TypedBatch(graphClient)
+			return err
+		},
+	})
+
+	// Request samples
+	register(Snippet{
+		Name:        "ReadRequestSnippet",
+		Group:       "requests",
+		Description: "GET /me",
+		Scopes:      []string{"User.Read"},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := MakeReadRequest(graphClient)
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "SelectRequestSnippet",
+		Group:       "requests",
+		Description: "GET /me with $select",
+		Scopes:      []string{"User.Read"},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := MakeSelectRequest(graphClient)
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "ListRequestSnippet",
+		Group:       "requests",
+		Description: "GET /me/messages with $select and $filter",
+		Scopes:      []string{"Mail.Read"},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := MakeListRequest(graphClient)
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "ItemByIdRequestSnippet",
+		Group:       "requests",
+		Description: "GET /me/messages/{message-id}",
+		Scopes:      []string{"Mail.Read"},
+		Inputs:      []Input{messageIdInput},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := MakeItemByIdRequest(graphClient, inputs["message-id"])
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "ExpandRequestSnippet",
+		Group:       "requests",
+		Description: "GET /me/messages/{message-id} with $expand=attachments",
+		Scopes:      []string{"Mail.Read"},
+		Inputs:      []Input{messageIdInput},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := MakeExpandRequest(graphClient, inputs["message-id"])
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "DeleteRequestSnippet",
+		Group:       "requests",
+		Description: "DELETE /me/messages/{message-id}",
+		Scopes:      []string{"Mail.ReadWrite"},
+		Mutates:     true,
+		Inputs:      []Input{messageIdInput},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			return MakeDeleteRequest(graphClient, inputs["message-id"])
+		},
+	})
+	register(Snippet{
+		Name:        "CreateRequestSnippet",
+		Group:       "requests",
+		Description: "POST /me/calendars",
+		Scopes:      []string{"Calendars.ReadWrite"},
+		Mutates:     true,
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := MakeCreateRequest(graphClient)
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "UpdateRequestSnippet",
+		Group:       "requests",
+		Description: "PATCH /teams/{team-id}",
+		Scopes:      []string{"TeamSettings.ReadWrite.All"},
+		Mutates:     true,
+		Inputs: []Input{{
+			Name:        "team-id",
+			Description: "ID of a team the signed-in user can update",
+			Required:    true,
+		}},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := MakeUpdateRequest(graphClient, inputs["team-id"])
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "HeadersRequestSnippet",
+		Group:       "requests",
+		Description: "GET /me/events with a Prefer header",
+		Scopes:      []string{"Calendars.Read"},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := MakeHeadersRequest(graphClient)
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "QueryParametersRequestSnippet",
+		Group:       "requests",
+		Description: "GET /me/calendarView with startDateTime and endDateTime",
+		Scopes:      []string{"Calendars.Read"},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := MakeQueryParametersRequest(graphClient)
+			return err
+		},
+	})
+
+	// Upload samples
+	register(Snippet{
+		Name:        "LargeFileUploadSnippet",
+		Group:       "upload",
+		Description: "Upload a large file to OneDrive with an upload session",
+		Scopes:      []string{"Files.ReadWrite"},
+		Mutates:     true,
+		Inputs:      slices.Concat([]Input{largeFileInput}, destinationInputs, []Input{progressInput, limitInput}),
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			output, err := inputs.Progress("progress")
+			if err != nil {
+				return err
+			}
+			limiter, err := inputs.Limiter("limit")
+			if err != nil {
+				return err
+			}
+			dest, err := inputs.Destination()
+			if err != nil {
+				return err
+			}
+			if inputs["file"] == "-" {
+				_, err = UploadStreamToOneDrive(graphClient, os.Stdin, dest.FileName(), dest, output, limiter)
+				return err
+			}
+			_, err = UploadFileToOneDrive(graphClient, inputs["file"], dest, output, limiter)
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "UploadAttachmentSnippet",
+		Group:       "upload",
+		Description: "Upload a large attachment to a new draft message",
+		Scopes:      []string{"Mail.ReadWrite"},
+		Mutates:     true,
+		Inputs:      []Input{largeFileInput, progressInput, limitInput},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			output, err := inputs.Progress("progress")
+			if err != nil {
+				return err
+			}
+			limiter, err := inputs.Limiter("limit")
+			if err != nil {
+				return err
+			}
+			if inputs["file"] == "-" {
+				_, err = UploadStreamAttachmentToMessage(graphClient, os.Stdin, "stdin", output, limiter)
+				return err
+			}
+			_, err = UploadAttachmentToMessage(graphClient, inputs["file"], output, limiter)
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "AttachFilesSnippet",
+		Group:       "upload",
+		Description: "Attach files of any size to a message or an event, including inline images",
+		Scopes:      []string{"Mail.ReadWrite", "Calendars.ReadWrite"},
+		Mutates:     true,
+		Inputs: []Input{{
+			Name:        "item-type",
+			Description: "type of item to attach to: message or event",
+			Default:     "message",
+		}, {
+			Name:        "item-id",
+			Description: "ID of the message or event in the signed-in user's mailbox",
+			Required:    true,
+		}, {
+			Name:        "files",
+			Description: "comma separated paths of the local files to attach",
+		}, {
+			Name:        "inline",
+			Description: "comma separated paths of images to show in the body, as cid:<file name>",
+		}, limitInput},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			limiter, err := inputs.Limiter("limit")
+			if err != nil {
+				return err
+			}
+			files, inline := inputs.List("files"), inputs.List("inline")
+			if len(files) == 0 && len(inline) == 0 {
+				return errors.New("AttachFilesSnippet requires input files or inline")
+			}
+			_, err = AttachFilesToItem(graphClient, inputs["item-type"], inputs["item-id"], files, inline, limiter)
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "ResumableUploadSnippet",
+		Group:       "upload",
+		Description: "Upload a large file to OneDrive, resuming an interrupted upload of the same file",
+		Scopes:      []string{"Files.ReadWrite"},
+		Mutates:     true,
+		Inputs:      slices.Concat([]Input{largeFileInput}, destinationInputs, []Input{progressInput, limitInput}),
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			dest, err := inputs.Destination()
+			if err != nil {
+				return err
+			}
+			output, err := inputs.Progress("progress")
+			if err != nil {
+				return err
+			}
+			limiter, err := inputs.Limiter("limit")
+			if err != nil {
+				return err
+			}
+			// Resuming reads the file again, which a stream cannot do
+			if inputs["file"] == "-" {
+				return errors.New("resumable uploads need a file, not standard input")
+			}
+			_, err = ResumableUploadToOneDrive(graphClient, inputs["file"], dest, output, limiter)
+			return err
+		},
+	})
+
+	register(Snippet{
+		Name:        "ResumableDownloadSnippet",
+		Group:       "download",
+		Description: "Download a large file from OneDrive in ranges, resuming an interrupted download",
+		Scopes:      []string{"Files.Read"},
+		Inputs: []Input{{
+			Name:        "source",
+			Description: "path of the file in OneDrive, relative to the root",
+			Default:     "Documents/vacation.gif",
+		}, {
+			Name:        "output",
+			Description: "path to save the file to",
+			Required:    true,
+		}, progressInput, limitInput},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			output, err := inputs.Progress("progress")
+			if err != nil {
+				return err
+			}
+			limiter, err := inputs.Limiter("limit")
+			if err != nil {
+				return err
+			}
+			_, err = ResumableDownloadFromOneDrive(graphClient, inputs["source"], inputs["output"], output, limiter)
+			return err
+		},
+	})
+
+	// Paging samples
+	register(Snippet{
+		Name:        "PagingSnippet",
+		Group:       "paging",
+		Description: "Iterate over all messages with a page iterator",
+		Scopes:      []string{"Mail.Read"},
+		Inputs:      []Input{pageSizeInput},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			pageSize, err := inputs.Int32("page-size")
+			if err != nil {
+				return err
+			}
+			_, err = IterateAllMessages(graphClient, pageSize)
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "ResumePagingSnippet",
+		Group:       "paging",
+		Description: "Pause and resume a page iterator",
+		Scopes:      []string{"Mail.Read"},
+		Inputs:      []Input{pageSizeInput},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			pageSize, err := inputs.Int32("page-size")
+			if err != nil {
+				return err
+			}
+			_, err = IterateAllMessagesWithPause(graphClient, pageSize)
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "RangePagingSnippet",
+		Group:       "paging",
+		Description: "Iterate all messages with a range-over-func loop",
+		Scopes:      []string{"Mail.Read"},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := RangeOverAllMessages(graphClient)
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "CheckpointPagingSnippet",
+		Group:       "paging",
+		Description: "Iterate all messages, saving the position so a later run can resume",
+		Scopes:      []string{"Mail.Read"},
+		Inputs: []Input{
+			{Name: "checkpoint-file", Description: "file to save the paging position to", Default: "paging-checkpoint.json"},
+			{Name: "limit", Description: "stop after this many messages, 0 for no limit", Default: "0"},
+		},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			limit, err := inputs.Int32("limit")
+			if err != nil {
+				return err
+			}
+			_, err = ResumeMessagesFromCheckpoint(graphClient, inputs["checkpoint-file"], limit)
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "DeltaSyncSnippet",
+		Group:       "paging",
+		Description: "Sync the inbox with a delta query, returning only changes after the first run",
+		Scopes:      []string{"Mail.Read"},
+		Inputs: []Input{
+			{Name: "state-file", Description: "file to save the delta link to", Default: "delta-state.json"},
+		},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := SyncInboxMessages(graphClient, inputs["state-file"])
+			return err
+		},
+	})
+	register(Snippet{
+		Name:        "ManualPagingSnippet",
+		Group:       "paging",
+		Description: "Page through messages by following @odata.nextLink",
+		Scopes:      []string{"Mail.Read"},
+		Run: func(graphClient *graph.GraphServiceClient, inputs Inputs) error {
+			_, err := ManuallyPageAllMessages(&graphClient.GraphBaseServiceClient)
+			return err
+		},
+	})
+}
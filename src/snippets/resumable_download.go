@@ -0,0 +1,49 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package snippets
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sdksnippets/bandwidth"
+	"sdksnippets/progress"
+	"sdksnippets/transfer"
+
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+func ResumableDownloadFromOneDrive(graphClient *graph.GraphServiceClient, itemPath string, localFile string,
+	output progress.Output, limiter *bandwidth.Limiter) (models.DriveItemable, error) {
+	// <ResumableDownloadSnippet>
+	myDrive, err := graphClient.Me().Drive().Get(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting user's drive: %w", err)
+	}
+
+	// The size of the file is learned from the first progress callback
+	reporter := progress.NewReporter(output, progress.Options{})
+	fileTransfer := reporter.Start(path.Base(itemPath), 0)
+
+	// The content is requested four ranges at a time. The ranges written
+	// so far are saved in localFile.download.json, so if this run is
+	// interrupted, the next one only requests the rest.
+	item, err := transfer.DownloadItem(context.Background(), graphClient,
+		*myDrive.GetId(), "root:/"+itemPath+":", localFile,
+		transfer.DownloadOptions{
+			Parallel: 4,
+			Progress: fileTransfer.DownloadProgress(),
+			Limiter:  limiter,
+		})
+	reporter.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Download complete, saved %s to %s\n", *item.GetName(), localFile)
+	// </ResumableDownloadSnippet>
+
+	return item, nil
+}
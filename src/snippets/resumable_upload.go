@@ -0,0 +1,55 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package snippets
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sdksnippets/bandwidth"
+	"sdksnippets/progress"
+	"sdksnippets/transfer"
+
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+func ResumableUploadToOneDrive(graphClient *graph.GraphServiceClient, largeFile string, dest transfer.Destination,
+	output progress.Output, limiter *bandwidth.Limiter) (models.DriveItemable, error) {
+	// The size of the file is learned from the first progress callback
+	uploadProgress, finish := reportUpload(output, filepath.Base(largeFile), 0)
+	defer finish()
+
+	// <ResumableUploadSnippet>
+	err := dest.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only called when there is no saved session to resume. The session
+	// is created in dest's drive as in LargeFileUploadSnippet.
+	createSession := func() (models.UploadSessionable, error) {
+		return dest.CreateUploadSession(context.Background(), graphClient)
+	}
+
+	// The session is saved next to the file, in largeFile.upload.json.
+	// If this run is interrupted, the next one continues where it stopped.
+	item, err := transfer.UploadResumable[models.DriveItemable](
+		graphClient.RequestAdapter,
+		largeFile,
+		createSession,
+		models.CreateDriveItemFromDiscriminatorValue,
+		transfer.ResumableUploadOptions{
+			Progress: uploadProgress,
+			Limiter:  limiter,
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Upload complete, item ID: %s\n", *item.GetId())
+	// </ResumableUploadSnippet>
+
+	return item, nil
+}
@@ -0,0 +1,426 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package snippets_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sdksnippets/graphmock"
+	"sdksnippets/paging"
+	"sdksnippets/progress"
+	"sdksnippets/snippets"
+	"sdksnippets/transfer"
+	"strings"
+	"testing"
+
+	khttp "github.com/microsoft/kiota-http-go"
+)
+
+func TestRunBatchSamples(t *testing.T) {
+	graphClient, _ := graphmock.NewTestClient(t)
+
+	err := snippets.RunBatchSamples(graphClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChunkedBatch(t *testing.T) {
+	graphClient, _ := graphmock.NewTestClient(t)
+
+	result, err := snippets.ChunkedBatch(graphClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.GetResponses()) != 25 {
+		t.Errorf("got %d responses, want 25", len(result.GetResponses()))
+	}
+}
+
+func TestTypedBatch(t *testing.T) {
+	graphClient, server := graphmock.NewTestClient(t)
+	server.FailNext("/me/messages", 1, 500, 0)
+
+	result, err := snippets.TypedBatch(graphClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := result.GetStatusCodes()
+	if statuses["me"] != 200 || statuses["messages"] != 500 {
+		t.Errorf("got statuses %v, want me 200 and messages 500", statuses)
+	}
+}
+
+// emptyStep replaces the response to the batch step that requests
+// url with a 204 that has no body
+type emptyStep struct {
+	url string
+}
+
+func (e emptyStep) Intercept(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/$batch") {
+		return pipeline.Next(req, middlewareIndex)
+	}
+
+	// The step IDs sent are generated, so the step is found by its URL
+	requestBody, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	var requestJson io.Reader = bytes.NewReader(requestBody)
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		requestJson, err = gzip.NewReader(requestJson)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var request map[string][]map[string]any
+	err = json.NewDecoder(requestJson).Decode(&request)
+	if err != nil {
+		return nil, err
+	}
+	var id any
+	for _, step := range request["requests"] {
+		if step["url"] == e.url {
+			id = step["id"]
+		}
+	}
+
+	response, err := pipeline.Next(req, middlewareIndex)
+	if err != nil {
+		return response, err
+	}
+	var batch map[string][]map[string]any
+	err = json.NewDecoder(response.Body).Decode(&batch)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	for i, step := range batch["responses"] {
+		if step["id"] == id {
+			batch["responses"][i] = map[string]any{"id": id, "status": http.StatusNoContent}
+		}
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return nil, err
+	}
+
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	response.ContentLength = -1
+	response.Header.Del("Content-Length")
+	return response, nil
+}
+
+func TestTypedBatchWithoutBody(t *testing.T) {
+	graphClient, _ := graphmock.NewTestClient(t, emptyStep{url: "/me"})
+
+	result, err := snippets.TypedBatch(graphClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := result.GetStatusCodes()["me"]; status != http.StatusNoContent {
+		t.Errorf("got status %d for me, want 204", status)
+	}
+}
+
+func TestResumeMessagesFromCheckpoint(t *testing.T) {
+	graphClient, _ := graphmock.NewTestClient(t)
+	checkpointFile := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	// Stop partway through the second page, then resume
+	for _, run := range []struct{ limit, want int32 }{{12, 12}, {0, 18}, {0, 30}} {
+		count, err := snippets.ResumeMessagesFromCheckpoint(graphClient, checkpointFile, run.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != int(run.want) {
+			t.Errorf("limit %d: got %d messages, want %d", run.limit, count, run.want)
+		}
+	}
+}
+
+func TestSyncInboxMessages(t *testing.T) {
+	graphClient, _ := graphmock.NewTestClient(t)
+	stateFile := filepath.Join(t.TempDir(), "delta-state.json")
+
+	result, err := snippets.SyncInboxMessages(graphClient, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 30 {
+		t.Errorf("first run added %d messages, want 30", result.Added)
+	}
+
+	result, err = snippets.SyncInboxMessages(graphClient, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (paging.DeltaResult{}) {
+		t.Errorf("second run returned changes: %+v", result)
+	}
+}
+
+func TestRunRequestSamples(t *testing.T) {
+	graphClient, _ := graphmock.NewTestClient(t)
+
+	err := snippets.RunRequestSamples(graphClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunUploadSamples(t *testing.T) {
+	graphClient, server := graphmock.NewTestClient(t)
+
+	// Not a multiple of the slice size, so the last slice is partial
+	content := bytes.Repeat([]byte("0123456789abcdef"), 80*1024+3)
+	largeFile := filepath.Join(t.TempDir(), "large.bin")
+	err := os.WriteFile(largeFile, content, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events bytes.Buffer
+	err = snippets.RunUploadSamples(graphClient, largeFile, transfer.Destination{Path: "Documents/large.bin"},
+		progress.NewJSON(&events), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploaded, ok := server.DriveItemContent("Documents/large.bin")
+	if !ok {
+		t.Fatal("file was not uploaded")
+	}
+	if !bytes.Equal(uploaded, content) {
+		t.Fatalf("uploaded content does not match: got %d bytes, want %d", len(uploaded), len(content))
+	}
+
+	// Each upload ends with a done event for the whole file
+	lines := strings.Split(strings.TrimSpace(events.String()), "\n")
+	var last progress.Event
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatal(err)
+	}
+	if last.Type != "done" || last.Bytes != int64(len(content)) || last.TotalBytes != int64(len(content)) {
+		t.Errorf("last progress event is %+v, want done with %d bytes", last, len(content))
+	}
+}
+
+func TestUploadFileToOneDriveRenames(t *testing.T) {
+	graphClient, server := graphmock.NewTestClient(t)
+	server.SetDriveItemContent("Documents/report.bin", []byte("existing"))
+	largeFile := writeTempFile(t, "report.bin", 1000)
+
+	dest := transfer.Destination{Path: "Documents/report.bin", Conflict: transfer.ConflictRename}
+	item, err := snippets.UploadFileToOneDrive(graphClient, largeFile, dest, progress.Quiet{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, renamed := dest.Renamed(item); !renamed || name != "report 1.bin" {
+		t.Errorf("got %s, renamed %v, want report 1.bin", name, renamed)
+	}
+	if existing, _ := server.DriveItemContent("Documents/report.bin"); string(existing) != "existing" {
+		t.Error("existing file was replaced")
+	}
+}
+
+func TestUploadFileToOneDriveSite(t *testing.T) {
+	graphClient, server := graphmock.NewTestClient(t)
+	largeFile := writeTempFile(t, "report.bin", 1000)
+
+	for _, dest := range []transfer.Destination{
+		{SiteId: "mock-site-id", Path: "site.bin"},
+		{SiteId: "mock-site-id", Library: "documents", Path: "library.bin"},
+	} {
+		_, err := snippets.UploadFileToOneDrive(graphClient, largeFile, dest, progress.Quiet{}, nil)
+		if err != nil {
+			t.Errorf("%+v: %v", dest, err)
+			continue
+		}
+		if _, ok := server.DriveItemContent(dest.Path); !ok {
+			t.Errorf("%+v: content was not uploaded", dest)
+		}
+	}
+
+	dest := transfer.Destination{SiteId: "mock-site-id", Library: "Archive", Path: "a.bin"}
+	_, err := snippets.UploadFileToOneDrive(graphClient, largeFile, dest, progress.Quiet{}, nil)
+	if err == nil {
+		t.Error("uploaded to a library the site does not have")
+	}
+}
+
+func TestUploadStreamToOneDrive(t *testing.T) {
+	graphClient, server := graphmock.NewTestClient(t)
+
+	// Longer than a slice, so it is uploaded in several requests
+	content := bytes.Repeat([]byte("0123456789abcdef"), 300*1024)
+	item, err := snippets.UploadStreamToOneDrive(graphClient, bytes.NewReader(content), "dump.sql",
+		transfer.Destination{Path: "Backups/dump.sql"}, progress.Quiet{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.GetSize() == nil || *item.GetSize() != int64(len(content)) {
+		t.Errorf("uploaded item has size %v, want %d", item.GetSize(), len(content))
+	}
+	uploaded, _ := server.DriveItemContent("Backups/dump.sql")
+	if !bytes.Equal(uploaded, content) {
+		t.Fatalf("uploaded content does not match: got %d bytes, want %d", len(uploaded), len(content))
+	}
+
+	_, err = snippets.UploadStreamAttachmentToMessage(graphClient, bytes.NewReader(content[:1000]), "dump.sql",
+		progress.Quiet{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = snippets.UploadStreamToOneDrive(graphClient, strings.NewReader(""), "empty.sql",
+		transfer.Destination{Path: "Backups/empty.sql"}, progress.Quiet{}, nil)
+	if err == nil {
+		t.Error("an empty stream was uploaded")
+	}
+}
+
+func TestAttachFilesToItem(t *testing.T) {
+	graphClient, server := graphmock.NewTestClient(t)
+	draft, err := snippets.UploadAttachmentToMessage(graphClient, writeTempFile(t, "first.bin", 100), progress.Quiet{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One file small enough to post, and one that needs an upload session
+	files := []string{writeTempFile(t, "notes.txt", 1000), writeTempFile(t, "video.mp4", 4*1024*1024)}
+	inline := []string{writeTempFile(t, "logo.png", 2000)}
+	ids, err := snippets.AttachFilesToItem(graphClient, "message", *draft.GetId(), files, inline, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attachments := server.Attachments(*draft.GetId())
+	if len(ids) != 3 || len(attachments) != 4 {
+		t.Fatalf("got IDs %v and %d attachments, want 3 new attachments", ids, len(attachments))
+	}
+	if logo := attachments[3]; logo["id"] != ids[2] || logo["isInline"] != true || logo["contentId"] != "logo.png" {
+		t.Errorf("inline image is %v", logo)
+	}
+
+	// A file in both lists is attached once as a file and once inline,
+	// and the spare capacity of files is not written to
+	files = append(make([]string, 0, 2), files[0])
+	ids, err = snippets.AttachFilesToItem(graphClient, "message", *draft.GetId(), files, files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachments = server.Attachments(*draft.GetId())
+	if len(ids) != 2 || attachments[4]["isInline"] == true || attachments[5]["isInline"] != true {
+		t.Errorf("got IDs %v and attachments %v, want the file and then the inline image", ids, attachments[4:])
+	}
+	if spare := files[:2][1]; spare != "" {
+		t.Errorf("files was appended to in place with %s", spare)
+	}
+
+	_, err = snippets.AttachFilesToItem(graphClient, "contact", *draft.GetId(), files, nil, nil)
+	if err == nil {
+		t.Error("attached files to a contact")
+	}
+}
+
+// writeTempFile writes size bytes to a file called name and returns its path
+func writeTempFile(t *testing.T, name string, size int) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, bytes.Repeat([]byte{'a'}, size), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestResumableUploadToOneDrive(t *testing.T) {
+	graphClient, server := graphmock.NewTestClient(t)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 300*1024)
+	largeFile := filepath.Join(t.TempDir(), "large.bin")
+	err := os.WriteFile(largeFile, content, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = snippets.ResumableUploadToOneDrive(graphClient, largeFile, transfer.Destination{Path: "Documents/large.bin"}, progress.Quiet{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploaded, _ := server.DriveItemContent("Documents/large.bin")
+	if !bytes.Equal(uploaded, content) {
+		t.Fatalf("uploaded content does not match: got %d bytes, want %d", len(uploaded), len(content))
+	}
+	if _, err := os.Stat(largeFile + ".upload.json"); !os.IsNotExist(err) {
+		t.Error("upload state was not deleted")
+	}
+}
+
+func TestResumableDownloadFromOneDrive(t *testing.T) {
+	graphClient, server := graphmock.NewTestClient(t)
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 300*1024)
+	server.SetDriveItemContent("Documents/large.bin", content)
+	localFile := filepath.Join(t.TempDir(), "large.bin")
+
+	_, err := snippets.ResumableDownloadFromOneDrive(graphClient, "Documents/large.bin", localFile, progress.Quiet{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	downloaded, err := os.ReadFile(localFile)
+	if err != nil || !bytes.Equal(downloaded, content) {
+		t.Fatalf("downloaded content does not match: got %d bytes, want %d", len(downloaded), len(content))
+	}
+}
+
+func TestRunPagingSamples(t *testing.T) {
+	graphClient, _ := graphmock.NewTestClient(t)
+
+	err := snippets.RunPagingSamples(graphClient, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPagingVisitsEveryMessage(t *testing.T) {
+	graphClient, _ := graphmock.NewTestClient(t)
+
+	count, err := snippets.IterateAllMessages(graphClient, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 30 {
+		t.Fatalf("got %d messages, want 30", count)
+	}
+
+	count, err = snippets.RangeOverAllMessages(graphClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 30 {
+		t.Fatalf("got %d messages, want 30", count)
+	}
+
+	count, err = snippets.ManuallyPageAllMessages(&graphClient.GraphBaseServiceClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 30 {
+		t.Fatalf("got %d messages, want 30", count)
+	}
+}
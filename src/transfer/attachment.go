@@ -0,0 +1,238 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sdksnippets/bandwidth"
+	"strings"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+	"github.com/microsoftgraph/msgraph-sdk-go-core/fileuploader"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/users"
+)
+
+// LargeAttachmentSize is the size from which UploadAttachment uses an
+// upload session. Graph only accepts smaller attachments in one request.
+const LargeAttachmentSize = 3 * 1024 * 1024
+
+// AttachmentTarget is an Outlook item that takes file attachments, such
+// as a message or an event. Its functions call the item's attachments
+// request builder, so a target can be built for any item whose
+// attachments support createUploadSession, for example an event in a
+// group calendar.
+type AttachmentTarget struct {
+	// Name describes the item in errors
+	Name string
+	// Post adds an attachment in one request
+	Post func(ctx context.Context, attachment models.Attachmentable) (models.Attachmentable, error)
+	// CreateUploadSession creates an upload session for an attachment
+	CreateUploadSession func(ctx context.Context, item models.AttachmentItemable) (models.UploadSessionable, error)
+}
+
+// MessageAttachments returns the target for attachments
+// of a message in the signed-in user's mailbox.
+func MessageAttachments(graphClient *graph.GraphServiceClient, messageId string) AttachmentTarget {
+	attachments := graphClient.Me().Messages().ByMessageId(messageId).Attachments()
+
+	return AttachmentTarget{
+		Name: "message " + messageId,
+		Post: func(ctx context.Context, attachment models.Attachmentable) (models.Attachmentable, error) {
+			return attachments.Post(ctx, attachment, nil)
+		},
+		CreateUploadSession: func(ctx context.Context, item models.AttachmentItemable) (models.UploadSessionable, error) {
+			body := users.NewItemMessagesItemAttachmentsCreateUploadSessionPostRequestBody()
+			body.SetAttachmentItem(item)
+			return attachments.CreateUploadSession().Post(ctx, body, nil)
+		},
+	}
+}
+
+// EventAttachments returns the target for attachments
+// of an event in the signed-in user's default calendar.
+func EventAttachments(graphClient *graph.GraphServiceClient, eventId string) AttachmentTarget {
+	attachments := graphClient.Me().Events().ByEventId(eventId).Attachments()
+
+	return AttachmentTarget{
+		Name: "event " + eventId,
+		Post: func(ctx context.Context, attachment models.Attachmentable) (models.Attachmentable, error) {
+			return attachments.Post(ctx, attachment, nil)
+		},
+		CreateUploadSession: func(ctx context.Context, item models.AttachmentItemable) (models.UploadSessionable, error) {
+			body := users.NewItemEventsItemAttachmentsCreateUploadSessionPostRequestBody()
+			body.SetAttachmentItem(item)
+			return attachments.CreateUploadSession().Post(ctx, body, nil)
+		},
+	}
+}
+
+// Attachment is a file to attach to an Outlook item.
+type Attachment struct {
+	Name string
+	// ContentType is the MIME type. If it is empty, Graph chooses one.
+	ContentType string
+	// Content is read from the start, up to the size Stat reports.
+	// An *os.File or a *Spool can be used.
+	Content fileuploader.ByteStream
+	// IsInline marks an image shown in the body of the item rather than
+	// listed as an attachment. The body refers to it as cid:ContentId.
+	IsInline  bool
+	ContentId string
+}
+
+// AttachmentOptions configures UploadAttachment and UploadAttachments.
+type AttachmentOptions struct {
+	// SliceSize is the size of each upload request, a multiple
+	// of 320 KiB. The default is DefaultSliceSize.
+	SliceSize int64
+	// Progress is called as each attachment is sent, with the last byte
+	// sent and the size of that attachment
+	Progress fileuploader.ProgressCallBack
+	// Limiter, if set, caps the rate attachments are read for upload
+	Limiter *bandwidth.Limiter
+}
+
+// UploadAttachment adds attachment to target and returns the ID of the
+// created attachment. Attachments smaller than LargeAttachmentSize are
+// posted in one request, and larger ones are sent through an upload
+// session with UploadContext.
+func UploadAttachment(ctx context.Context, adapter abstractions.RequestAdapter, target AttachmentTarget,
+	attachment Attachment, options AttachmentOptions) (string, error) {
+	if attachment.IsInline && attachment.ContentId == "" {
+		return "", fmt.Errorf("inline attachment %s has no content ID", attachment.Name)
+	}
+	info, err := attachment.Content.Stat()
+	if err != nil {
+		return "", fmt.Errorf("reading size of %s: %w", attachment.Name, err)
+	}
+	size := info.Size()
+	content := bandwidth.LimitByteStream(ctx, attachment.Content, options.Limiter)
+
+	var id string
+	if size < LargeAttachmentSize {
+		id, err = postAttachment(ctx, target, attachment, content, size)
+		if err == nil && options.Progress != nil {
+			// Reported like the last slice of an upload
+			options.Progress(size-1, size)
+		}
+	} else {
+		id, err = uploadLargeAttachment(ctx, adapter, target, attachment, content, size, options)
+	}
+	if err != nil {
+		return "", fmt.Errorf("attaching %s to %s: %w", attachment.Name, target.Name, err)
+	}
+
+	return id, nil
+}
+
+// UploadAttachments adds each of attachments to target in turn, and
+// returns the IDs of the created attachments in the same order. If one
+// fails, it returns the IDs of those already created with the error.
+func UploadAttachments(ctx context.Context, adapter abstractions.RequestAdapter, target AttachmentTarget,
+	attachments []Attachment, options AttachmentOptions) ([]string, error) {
+	var ids []string
+	for _, attachment := range attachments {
+		id, err := UploadAttachment(ctx, adapter, target, attachment, options)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// postAttachment sends a small attachment as a fileAttachment
+func postAttachment(ctx context.Context, target AttachmentTarget, attachment Attachment,
+	content fileuploader.ByteStream, size int64) (string, error) {
+	contentBytes, err := io.ReadAll(io.NewSectionReader(content, 0, size))
+	if err != nil {
+		return "", fmt.Errorf("reading content: %w", err)
+	}
+
+	fileAttachment := models.NewFileAttachment()
+	fileAttachment.SetName(&attachment.Name)
+	fileAttachment.SetContentBytes(contentBytes)
+	if attachment.ContentType != "" {
+		fileAttachment.SetContentType(&attachment.ContentType)
+	}
+	if attachment.IsInline {
+		fileAttachment.SetIsInline(&attachment.IsInline)
+		fileAttachment.SetContentId(&attachment.ContentId)
+	}
+
+	created, err := target.Post(ctx, fileAttachment)
+	if err != nil {
+		return "", fmt.Errorf("posting attachment: %w", err)
+	}
+	if created == nil || created.GetId() == nil {
+		return "", errors.New("posting attachment: response has no ID")
+	}
+
+	return *created.GetId(), nil
+}
+
+// uploadLargeAttachment sends an attachment through an upload session
+func uploadLargeAttachment(ctx context.Context, adapter abstractions.RequestAdapter, target AttachmentTarget,
+	attachment Attachment, content fileuploader.ByteStream, size int64, options AttachmentOptions) (string, error) {
+	item := models.NewAttachmentItem()
+	attachmentType := models.FILE_ATTACHMENTTYPE
+	item.SetAttachmentType(&attachmentType)
+	item.SetName(&attachment.Name)
+	item.SetSize(&size)
+	if attachment.ContentType != "" {
+		item.SetContentType(&attachment.ContentType)
+	}
+	if attachment.IsInline {
+		item.SetIsInline(&attachment.IsInline)
+		item.SetContentId(&attachment.ContentId)
+	}
+
+	session, err := target.CreateUploadSession(ctx, item)
+	if err != nil {
+		return "", fmt.Errorf("creating upload session: %w", err)
+	}
+
+	result, err := UploadContext[models.FileAttachmentable](ctx, adapter, session, content,
+		models.CreateFileAttachmentFromDiscriminatorValue, UploadOptions{
+			SliceSize: options.SliceSize,
+			Progress:  options.Progress,
+		})
+	if err != nil {
+		return "", err
+	}
+	if result.Item != nil && result.Item.GetId() != nil {
+		return *result.Item.GetId(), nil
+	}
+
+	return attachmentIdFromLocation(result.Location)
+}
+
+// attachmentIdFromLocation returns the ID at the end of the URL of a
+// created attachment. Graph returns either .../attachments/{id} or, from
+// the Outlook service, .../Attachments('{id}').
+func attachmentIdFromLocation(location string) (string, error) {
+	uri, err := url.Parse(location)
+	if err != nil || location == "" {
+		return "", errors.New("upload returned no attachment location")
+	}
+
+	segment := path.Base(uri.Path)
+	if open := strings.Index(segment, "('"); open >= 0 && strings.HasSuffix(segment, "')") {
+		segment = segment[open+2 : len(segment)-2]
+	}
+	id, err := url.PathUnescape(segment)
+	if err != nil || id == "" || id == "." || id == "/" {
+		return "", fmt.Errorf("no attachment ID in location %q", location)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,104 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"sdksnippets/transfer"
+	"testing"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+// spoolContent returns content as a ByteStream
+func spoolContent(t *testing.T, content []byte) *transfer.Spool {
+	t.Helper()
+
+	spool, err := transfer.SpoolStream(bytes.NewReader(content), transfer.SpoolOptions{Name: "content", Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { spool.Close() })
+
+	return spool
+}
+
+func TestUploadAttachments(t *testing.T) {
+	graphClient, server, _ := newMockClient(t)
+	subject := "Attachments"
+
+	draft := models.NewMessage()
+	draft.SetSubject(&subject)
+	message, err := graphClient.Me().Messages().Post(context.Background(), draft, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newEvent := models.NewEvent()
+	newEvent.SetSubject(&subject)
+	event, err := graphClient.Me().Events().Post(context.Background(), newEvent, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, small := writeFile(t, "logo.png", 10*1024, 1)
+	// Just at the limit, so it needs an upload session
+	_, large := writeFile(t, "report.bin", transfer.LargeAttachmentSize, 2)
+	attachments := []transfer.Attachment{
+		{Name: "logo.png", ContentType: "image/png", Content: spoolContent(t, small), IsInline: true, ContentId: "logo"},
+		{Name: "report.bin", Content: spoolContent(t, large)},
+	}
+
+	for _, target := range []struct {
+		id     string
+		target transfer.AttachmentTarget
+	}{
+		{*message.GetId(), transfer.MessageAttachments(graphClient, *message.GetId())},
+		{*event.GetId(), transfer.EventAttachments(graphClient, *event.GetId())},
+	} {
+		var sent int64
+		ids, err := transfer.UploadAttachments(context.Background(), graphClient.RequestAdapter, target.target,
+			attachments, transfer.AttachmentOptions{
+				SliceSize: sliceSize,
+				Progress: func(current int64, total int64) {
+					if current == total-1 {
+						sent += total
+					}
+				},
+			})
+		if err != nil {
+			t.Fatalf("%s: %v", target.target.Name, err)
+		}
+
+		stored := server.Attachments(target.id)
+		if len(ids) != 2 || len(stored) != 2 {
+			t.Fatalf("%s: got IDs %v and %d attachments, want 2", target.target.Name, ids, len(stored))
+		}
+		if sent != int64(len(small)+len(large)) {
+			t.Errorf("%s: progress reported %d bytes, want %d", target.target.Name, sent, len(small)+len(large))
+		}
+		for i, content := range [][]byte{small, large} {
+			got, _ := base64.StdEncoding.DecodeString(stored[i]["contentBytes"].(string))
+			if stored[i]["id"] != ids[i] || stored[i]["name"] != attachments[i].Name || !bytes.Equal(got, content) {
+				t.Errorf("%s: attachment %d is %v %v with %d bytes, want %s %s", target.target.Name, i,
+					stored[i]["id"], stored[i]["name"], len(got), ids[i], attachments[i].Name)
+			}
+		}
+		if stored[0]["isInline"] != true || stored[0]["contentId"] != "logo" || stored[0]["contentType"] != "image/png" {
+			t.Errorf("%s: inline image is %v", target.target.Name, stored[0])
+		}
+		if stored[1]["isInline"] != false {
+			t.Errorf("%s: large attachment is inline", target.target.Name)
+		}
+	}
+
+	_, err = transfer.UploadAttachment(context.Background(), graphClient.RequestAdapter,
+		transfer.MessageAttachments(graphClient, *message.GetId()),
+		transfer.Attachment{Name: "logo.png", Content: spoolContent(t, small), IsInline: true},
+		transfer.AttachmentOptions{})
+	if err == nil {
+		t.Error("an inline attachment without a content ID was accepted")
+	}
+}
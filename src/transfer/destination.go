@@ -0,0 +1,215 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/drives"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+// ConflictBehavior is what Graph does when an upload's destination
+// already has an item with the same name.
+type ConflictBehavior string
+
+const (
+	// ConflictFail fails the upload
+	ConflictFail ConflictBehavior = "fail"
+	// ConflictReplace replaces the existing item
+	ConflictReplace ConflictBehavior = "replace"
+	// ConflictRename gives the upload a name that is not taken,
+	// such as "report 1.pdf"
+	ConflictRename ConflictBehavior = "rename"
+)
+
+// ParseConflictBehavior parses fail, replace or rename.
+func ParseConflictBehavior(value string) (ConflictBehavior, error) {
+	switch behavior := ConflictBehavior(strings.ToLower(strings.TrimSpace(value))); behavior {
+	case ConflictFail, ConflictReplace, ConflictRename:
+		return behavior, nil
+	}
+
+	return "", fmt.Errorf("unknown conflict behavior %q, want fail, replace or rename", value)
+}
+
+// Destination is where a file is uploaded to. The drive is DriveId if
+// it is set, or else a document library of SiteId, or else the signed-in
+// user's OneDrive. In the drive, the file is either at Path, relative to
+// the root, or called Name in the folder with the ID ParentId.
+type Destination struct {
+	DriveId string
+	// SiteId is a site ID, or a hostname and path such as
+	// contoso.sharepoint.com:/sites/marketing:
+	SiteId string
+	// Library is the name of a document library of SiteId. If it is
+	// empty, the site's default library is used.
+	Library string
+
+	Path     string
+	ParentId string
+	Name     string
+
+	// Conflict is what happens if the destination already has an
+	// item with the file's name. The default is ConflictReplace.
+	Conflict ConflictBehavior
+}
+
+// Validate checks that d names exactly one drive and one location in it.
+func (d Destination) Validate() error {
+	if d.DriveId != "" && d.SiteId != "" {
+		return errors.New("destination has both a drive ID and a site")
+	}
+	if d.Library != "" && d.SiteId == "" {
+		return errors.New("destination has a library but no site")
+	}
+
+	if d.ParentId != "" {
+		if d.Path != "" {
+			return errors.New("destination has both a path and a parent ID")
+		}
+		if d.Name == "" || strings.Contains(d.Name, "/") {
+			return fmt.Errorf("destination name %q must be a file name when a parent ID is given", d.Name)
+		}
+	} else if d.Name != "" {
+		return errors.New("destination has a name but no parent ID")
+	} else if strings.Trim(d.Path, "/") == "" {
+		return errors.New("destination has no path")
+	}
+
+	if d.Conflict != "" {
+		if _, err := ParseConflictBehavior(string(d.Conflict)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FileName returns the name the uploaded file is given,
+// unless it is renamed because of a conflict.
+func (d Destination) FileName() string {
+	if d.ParentId != "" {
+		return d.Name
+	}
+
+	return path.Base(strings.Trim(d.Path, "/"))
+}
+
+// Renamed returns the name of item, as returned by an upload to d, and
+// whether Graph gave it a different name than FileName to avoid a
+// conflict.
+func (d Destination) Renamed(item models.DriveItemable) (string, bool) {
+	if item.GetName() == nil {
+		return d.FileName(), false
+	}
+
+	return *item.GetName(), *item.GetName() != d.FileName()
+}
+
+// Folder returns the ID of the folder the file is uploaded to, which is
+// root for a Path, and the path of the file relative to that folder.
+func (d Destination) Folder() (folderId string, itemPath string) {
+	if d.ParentId != "" {
+		return d.ParentId, d.Name
+	}
+
+	return "root", strings.Trim(d.Path, "/")
+}
+
+// ItemId returns the ID that addresses the file in its drive, either
+// root:/path: or parentId:/name:. The item does not need to exist.
+func (d Destination) ItemId() string {
+	folderId, itemPath := d.Folder()
+	return folderId + ":/" + itemPath + ":"
+}
+
+// ConflictBehavior returns d.Conflict, or ConflictReplace if it is empty.
+func (d Destination) ConflictBehavior() ConflictBehavior {
+	if d.Conflict == "" {
+		return ConflictReplace
+	}
+
+	return d.Conflict
+}
+
+// CreateUploadSession creates an upload session for a file at d. To use
+// it as the SessionFactory of UploadResumable, call it from a closure.
+func (d Destination) CreateUploadSession(ctx context.Context,
+	graphClient *graph.GraphServiceClient) (models.UploadSessionable, error) {
+	err := d.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	driveId, err := d.ResolveDriveId(ctx, graphClient)
+	if err != nil {
+		return nil, err
+	}
+
+	itemUploadProperties := models.NewDriveItemUploadableProperties()
+	itemUploadProperties.SetAdditionalData(map[string]any{"@microsoft.graph.conflictBehavior": string(d.ConflictBehavior())})
+	body := drives.NewItemItemsItemCreateUploadSessionPostRequestBody()
+	body.SetItem(itemUploadProperties)
+
+	session, err := graphClient.Drives().
+		ByDriveId(driveId).
+		Items().
+		ByDriveItemId(d.ItemId()).
+		CreateUploadSession().
+		Post(ctx, body, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating upload session: %w", err)
+	}
+
+	return session, nil
+}
+
+// ResolveDriveId returns the ID of the drive d names, looking up the
+// site's library or the signed-in user's OneDrive if DriveId is empty.
+func (d Destination) ResolveDriveId(ctx context.Context, graphClient *graph.GraphServiceClient) (string, error) {
+	var drive models.Driveable
+	var err error
+	switch {
+	case d.DriveId != "":
+		return d.DriveId, nil
+	case d.SiteId != "" && d.Library == "":
+		drive, err = graphClient.Sites().BySiteId(d.SiteId).Drive().Get(ctx, nil)
+		if err != nil {
+			return "", fmt.Errorf("getting default library of site %s: %w", d.SiteId, err)
+		}
+	case d.SiteId != "":
+		return d.libraryId(ctx, graphClient)
+	default:
+		drive, err = graphClient.Me().Drive().Get(ctx, nil)
+		if err != nil {
+			return "", fmt.Errorf("getting user's drive: %w", err)
+		}
+	}
+
+	return *drive.GetId(), nil
+}
+
+// libraryId returns the ID of the document library of
+// d.SiteId whose name is d.Library
+func (d Destination) libraryId(ctx context.Context, graphClient *graph.GraphServiceClient) (string, error) {
+	// A site has few libraries, so they fit in one page
+	libraries, err := graphClient.Sites().BySiteId(d.SiteId).Drives().Get(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("listing libraries of site %s: %w", d.SiteId, err)
+	}
+
+	for _, library := range libraries.GetValue() {
+		if library.GetName() != nil && strings.EqualFold(*library.GetName(), d.Library) {
+			return *library.GetId(), nil
+		}
+	}
+
+	return "", fmt.Errorf("site %s has no library named %q", d.SiteId, d.Library)
+}
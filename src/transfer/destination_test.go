@@ -0,0 +1,151 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sdksnippets/transfer"
+	"testing"
+
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+)
+
+// uploadTo uploads content to dest and returns the created item
+func uploadTo(t *testing.T, graphClient *graph.GraphServiceClient, dest transfer.Destination,
+	content []byte) (models.DriveItemable, error) {
+	t.Helper()
+
+	session, err := dest.CreateUploadSession(context.Background(), graphClient)
+	if err != nil {
+		return nil, err
+	}
+	result, err := transfer.UploadContext[models.DriveItemable](context.Background(), graphClient.RequestAdapter,
+		session, spoolContent(t, content), models.CreateDriveItemFromDiscriminatorValue, transfer.UploadOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Item, nil
+}
+
+func TestDestinationValidate(t *testing.T) {
+	for _, dest := range []transfer.Destination{
+		{},
+		{Path: "/"},
+		{Path: "a.bin", DriveId: "drive", SiteId: "site"},
+		{Path: "a.bin", Library: "Documents"},
+		{Path: "a.bin", ParentId: "folder", Name: "a.bin"},
+		{ParentId: "folder"},
+		{ParentId: "folder", Name: "sub/a.bin"},
+		{Name: "a.bin"},
+		{Path: "a.bin", Conflict: "overwrite"},
+	} {
+		if err := dest.Validate(); err == nil {
+			t.Errorf("%+v is valid", dest)
+		}
+	}
+
+	for _, dest := range []transfer.Destination{
+		{Path: "Documents/a.bin"},
+		{Path: "a.bin", DriveId: "drive", Conflict: transfer.ConflictFail},
+		{Path: "a.bin", SiteId: "contoso.sharepoint.com:/sites/team:", Library: "Shared Documents"},
+		{ParentId: "folder", Name: "a.bin", Conflict: transfer.ConflictRename},
+	} {
+		if err := dest.Validate(); err != nil {
+			t.Errorf("%+v: %v", dest, err)
+		}
+	}
+
+	if conflict, err := transfer.ParseConflictBehavior(" Rename "); err != nil || conflict != transfer.ConflictRename {
+		t.Errorf("ParseConflictBehavior = %q, %v", conflict, err)
+	}
+}
+
+func TestUploadToDestinations(t *testing.T) {
+	graphClient, server, _ := newMockClient(t)
+	_, content := writeFile(t, "report.bin", sliceSize+10, 8)
+
+	newFolder := models.NewDriveItem()
+	folderName := "Reports"
+	newFolder.SetName(&folderName)
+	newFolder.SetFolder(models.NewFolder())
+	folder, err := graphClient.Drives().ByDriveId("mock-drive-id").Items().ByDriveItemId("root").Children().
+		Post(context.Background(), newFolder, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		dest transfer.Destination
+		path string
+	}{
+		{transfer.Destination{Path: "me.bin"}, "me.bin"},
+		{transfer.Destination{DriveId: "mock-drive-id", Path: "/Reports/drive.bin"}, "Reports/drive.bin"},
+		{transfer.Destination{SiteId: "mock-site-id", Path: "site.bin"}, "site.bin"},
+		{transfer.Destination{SiteId: "mock-site-id", Library: "documents", Path: "library.bin"}, "library.bin"},
+		{transfer.Destination{ParentId: *folder.GetId(), Name: "parent.bin"}, "Reports/parent.bin"},
+	} {
+		item, err := uploadTo(t, graphClient, test.dest, content)
+		if err != nil {
+			t.Errorf("%+v: %v", test.dest, err)
+			continue
+		}
+		uploaded, _ := server.DriveItemContent(test.path)
+		if !bytes.Equal(uploaded, content) {
+			t.Errorf("%+v: content was not uploaded to %s", test.dest, test.path)
+		}
+		if name, renamed := test.dest.Renamed(item); renamed {
+			t.Errorf("%+v: renamed to %s", test.dest, name)
+		}
+	}
+
+	_, err = uploadTo(t, graphClient, transfer.Destination{SiteId: "mock-site-id", Library: "Archive", Path: "a.bin"}, content)
+	if err == nil {
+		t.Error("uploaded to a library the site does not have")
+	}
+}
+
+func TestUploadConflictBehavior(t *testing.T) {
+	graphClient, server, _ := newMockClient(t)
+	_, original := writeFile(t, "original.bin", 100, 1)
+	server.SetDriveItemContent("Reports/report.bin", original)
+	server.SetDriveItemContent("Reports/report 1.bin", original)
+	_, content := writeFile(t, "report.bin", 100, 2)
+
+	dest := transfer.Destination{Path: "Reports/report.bin", Conflict: transfer.ConflictFail}
+	_, err := uploadTo(t, graphClient, dest, content)
+	var odataErr *odataerrors.ODataError
+	if !errors.As(err, &odataErr) || odataErr.GetStatusCode() != http.StatusConflict {
+		t.Errorf("fail: got %v, want a conflict", err)
+	}
+
+	dest.Conflict = transfer.ConflictRename
+	item, err := uploadTo(t, graphClient, dest, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, renamed := dest.Renamed(item); !renamed || name != "report 2.bin" {
+		t.Errorf("rename: got %s, renamed %v, want report 2.bin", name, renamed)
+	}
+	if uploaded, _ := server.DriveItemContent("Reports/report 2.bin"); !bytes.Equal(uploaded, content) {
+		t.Error("rename: content was not uploaded under the new name")
+	}
+
+	dest.Conflict = transfer.ConflictReplace
+	item, err = uploadTo(t, graphClient, dest, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, renamed := dest.Renamed(item); renamed {
+		t.Errorf("replace: renamed to %s", name)
+	}
+	if uploaded, _ := server.DriveItemContent("Reports/report.bin"); !bytes.Equal(uploaded, content) {
+		t.Error("replace: content was not replaced")
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sdksnippets/bandwidth"
+	"sync"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+	"github.com/microsoftgraph/msgraph-sdk-go-core/fileuploader"
+	"github.com/microsoftgraph/msgraph-sdk-go/drives"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+// DefaultChunkSize is the size of each range requested by a download.
+const DefaultChunkSize int64 = 4 * 1024 * 1024
+
+const (
+	// partialFileSuffix is appended to the path of a download
+	// to name the file it is written to until it completes
+	partialFileSuffix = ".partial"
+	// downloadStateSuffix is appended to the path of a
+	// download to name its state file
+	downloadStateSuffix = ".download.json"
+)
+
+// DownloadState is the progress of a download, saved in a state file
+// next to the downloaded file so that another run can resume it.
+type DownloadState struct {
+	DriveId string `json:"driveId"`
+	ItemId  string `json:"itemId"`
+	// Tag is the item's cTag, or its eTag if it has no cTag. A download is
+	// only resumed if the item still has this tag.
+	Tag  string `json:"tag"`
+	Size int64  `json:"size"`
+	// CompletedRanges are the byte ranges written to the partial
+	// file, in the "start-end" form of nextExpectedRanges
+	CompletedRanges []string `json:"completedRanges"`
+}
+
+// DownloadOptions configures DownloadItem.
+type DownloadOptions struct {
+	// StatePath is the state file. The default is the downloaded
+	// file's path followed by .download.json.
+	StatePath string
+	// ChunkSize is the size of each range request.
+	// The default is DefaultChunkSize.
+	ChunkSize int64
+	// Parallel is the number of ranges requested at once. The default is 1.
+	Parallel int
+	// Progress is called after each range is written, with the number
+	// of bytes downloaded so far and the size of the item
+	Progress fileuploader.ProgressCallBack
+	// Limiter, if set, caps the rate ranges are requested at. Each range
+	// waits for its whole length before it is requested.
+	Limiter *bandwidth.Limiter
+}
+
+// DownloadItem downloads the content of a drive item to path and returns
+// the item. itemId may be an item ID or a path such as root:/a/b.txt:.
+//
+// The content is requested in ranges of ChunkSize and written to path
+// followed by .partial, which is renamed to path once it is complete and
+// verified with VerifyItem. The ranges written so far are saved to a state
+// file, so calling DownloadItem again after an interruption, even by the
+// process ending, only requests the ranges still missing. The download
+// starts over if the item changed in the meantime.
+//
+// If the content does not match the item's hashes, the partial file is
+// deleted and the item is returned with a *MismatchError.
+func DownloadItem(ctx context.Context, graphClient *graph.GraphServiceClient, driveId string, itemId string,
+	path string, options DownloadOptions) (models.DriveItemable, error) {
+	if options.StatePath == "" {
+		options.StatePath = path + downloadStateSuffix
+	}
+	if options.ChunkSize <= 0 {
+		options.ChunkSize = DefaultChunkSize
+	}
+	if options.Parallel < 1 {
+		options.Parallel = 1
+	}
+
+	itemBuilder := graphClient.Drives().ByDriveId(driveId).Items().ByDriveItemId(itemId)
+	item, err := itemBuilder.Get(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting item %s: %w", itemId, err)
+	}
+	if item.GetFile() == nil || item.GetSize() == nil {
+		return nil, fmt.Errorf("item %s is not a file", itemId)
+	}
+
+	wanted := DownloadState{
+		DriveId: driveId,
+		ItemId:  *item.GetId(),
+		Tag:     itemTag(item),
+		Size:    *item.GetSize(),
+	}
+	partialPath := path + partialFileSuffix
+	state, file, err := openPartialFile(partialPath, options.StatePath, wanted)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	downloader := &rangeDownloader{
+		content:   itemBuilder.Content(),
+		file:      file,
+		state:     state,
+		statePath: options.StatePath,
+		progress:  options.Progress,
+		limiter:   options.Limiter,
+	}
+	err = downloader.download(ctx, options.ChunkSize, options.Parallel)
+	if err != nil {
+		return item, fmt.Errorf("downloading %s, run again to resume: %w", path, err)
+	}
+
+	err = verifyContent(item, file, state.Size, path)
+	if isMismatch(err) {
+		// Downloading the same ranges again would not help
+		file.Close()
+		os.Remove(partialPath)
+		os.Remove(options.StatePath)
+		return item, err
+	}
+	if err != nil {
+		return item, err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return item, fmt.Errorf("writing %s: %w", partialPath, err)
+	}
+	err = os.Rename(partialPath, path)
+	if err != nil {
+		return item, fmt.Errorf("saving %s: %w", path, err)
+	}
+	err = os.Remove(options.StatePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return item, fmt.Errorf("deleting download state: %w", err)
+	}
+
+	return item, nil
+}
+
+// itemTag returns the tag that changes when the content of item does
+func itemTag(item models.DriveItemable) string {
+	if item.GetCTag() != nil {
+		return *item.GetCTag()
+	}
+
+	return derefString(item.GetETag())
+}
+
+// openPartialFile opens the partial file of a download and returns its
+// state. The saved state is used if it is for the same version of the
+// same item and the partial file is intact, and otherwise the partial
+// file is truncated and the state starts empty.
+func openPartialFile(partialPath string, statePath string, wanted DownloadState) (*DownloadState, *os.File, error) {
+	state, err := LoadDownloadState(statePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if state != nil && state.DriveId == wanted.DriveId && state.ItemId == wanted.ItemId &&
+		state.Tag == wanted.Tag && state.Size == wanted.Size {
+		file, err := os.OpenFile(partialPath, os.O_RDWR, 0)
+		if err == nil {
+			info, err := file.Stat()
+			if err == nil && info.Size() == state.Size {
+				return state, file, nil
+			}
+			file.Close()
+		}
+	}
+
+	file, err := os.OpenFile(partialPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating %s: %w", partialPath, err)
+	}
+	// Ranges are written in place, in any order
+	err = file.Truncate(wanted.Size)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("creating %s: %w", partialPath, err)
+	}
+
+	state = &wanted
+	err = saveDownloadState(statePath, state)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return state, file, nil
+}
+
+// rangeDownloader requests ranges of an item's content and
+// writes them to the partial file, recording each in the state
+type rangeDownloader struct {
+	content   *drives.ItemItemsItemContentRequestBuilder
+	file      *os.File
+	statePath string
+	progress  fileuploader.ProgressCallBack
+	limiter   *bandwidth.Limiter
+
+	mu         sync.Mutex
+	state      *DownloadState
+	downloaded int64
+	saveErr    error
+}
+
+// download requests every range that is not complete, parallel at a time.
+// It stops at the first range that fails.
+func (d *rangeDownloader) download(ctx context.Context, chunkSize int64, parallel int) error {
+	remaining := parseRanges(complementRanges(d.state.CompletedRanges, d.state.Size), d.state.Size)
+	d.downloaded = d.state.Size
+	for _, r := range remaining {
+		d.downloaded -= r.end - r.start + 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks := make(chan byteRange)
+	errs := make(chan error, parallel)
+	var workers sync.WaitGroup
+	for range parallel {
+		workers.Go(func() {
+			for chunk := range chunks {
+				err := d.downloadRange(ctx, chunk)
+				if err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		})
+	}
+
+send:
+	for _, r := range remaining {
+		for start := r.start; start <= r.end; start += chunkSize {
+			select {
+			case chunks <- byteRange{start: start, end: min(start+chunkSize-1, r.end)}:
+			case <-ctx.Done():
+				break send
+			}
+		}
+	}
+	close(chunks)
+	workers.Wait()
+	close(errs)
+
+	var err error
+	for workerErr := range errs {
+		err = errors.Join(err, workerErr)
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	return errors.Join(err, d.saveErr)
+}
+
+func (d *rangeDownloader) downloadRange(ctx context.Context, r byteRange) error {
+	err := d.limiter.WaitN(ctx, int(r.end-r.start+1))
+	if err != nil {
+		return err
+	}
+
+	headers := abstractions.NewRequestHeaders()
+	headers.Add("Range", fmt.Sprintf("bytes=%s", r))
+	content, err := d.content.Get(ctx, &drives.ItemItemsItemContentRequestBuilderGetRequestConfiguration{
+		Headers: headers,
+	})
+	if err != nil {
+		return fmt.Errorf("requesting bytes %s: %w", r, err)
+	}
+	// A server that ignores Range sends the whole content
+	if int64(len(content)) != r.end-r.start+1 {
+		return fmt.Errorf("requested bytes %s and received %d bytes", r, len(content))
+	}
+
+	_, err = d.file.WriteAt(content, r.start)
+	if err != nil {
+		return fmt.Errorf("writing bytes %s: %w", r, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.state.CompletedRanges = addRange(d.state.CompletedRanges, r.start, r.end)
+	d.downloaded += int64(len(content))
+	if err := saveDownloadState(d.statePath, d.state); err != nil && d.saveErr == nil {
+		d.saveErr = err
+	}
+	if d.progress != nil {
+		d.progress(d.downloaded, d.state.Size)
+	}
+
+	return nil
+}
+
+// LoadDownloadState reads the state file at path. It returns nil if
+// the file does not exist.
+func LoadDownloadState(path string) (*DownloadState, error) {
+	state := &DownloadState{}
+	found, err := loadJson(path, state)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func saveDownloadState(path string, state *DownloadState) error {
+	err := saveJson(path, state)
+	if err != nil {
+		return fmt.Errorf("saving download state: %w", err)
+	}
+
+	return nil
+}
+
+func derefString(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
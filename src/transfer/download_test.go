@@ -0,0 +1,239 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sdksnippets/bandwidth"
+	"sdksnippets/graphmock"
+	"sdksnippets/transfer"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	khttp "github.com/microsoft/kiota-http-go"
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+)
+
+const chunkSize = 100 * 1024
+
+// downloadLog records the Range of every request for item content,
+// and optionally corrupts the content served by the download URL
+type downloadLog struct {
+	mu      sync.Mutex
+	ranges  []string
+	corrupt bool
+}
+
+func (l *downloadLog) Intercept(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "/content") && req.Method == http.MethodGet {
+		l.mu.Lock()
+		l.ranges = append(l.ranges, req.Header.Get("Range"))
+		l.mu.Unlock()
+	}
+
+	response, err := pipeline.Next(req, middlewareIndex)
+	if err != nil || !l.corrupt || !strings.HasPrefix(req.URL.Path, "/download/") {
+		return response, err
+	}
+
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > 0 {
+		body[0] ^= 0xff
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	return response, nil
+}
+
+func (l *downloadLog) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.ranges)
+}
+
+func TestDownloadItem(t *testing.T) {
+	for _, parallel := range []int{1, 4} {
+		log := &downloadLog{}
+		graphClient, server := graphmock.NewTestClient(t, log)
+		options := transfer.DownloadOptions{ChunkSize: chunkSize, Parallel: parallel}
+		_, content := writeFile(t, "large.bin", 10*chunkSize+123, 1)
+		itemId := server.SetDriveItemContent("Documents/large.bin", content)
+		path := filepath.Join(t.TempDir(), "large.bin")
+
+		var progressMu sync.Mutex
+		var last int64
+		options.Progress = func(current int64, total int64) {
+			progressMu.Lock()
+			defer progressMu.Unlock()
+
+			if current <= last || total != int64(len(content)) {
+				t.Errorf("parallel %d: progress %d of %d after %d", parallel, current, total, last)
+			}
+			last = current
+		}
+
+		_, err := transfer.DownloadItem(context.Background(), graphClient, "mock-drive-id", itemId, path, options)
+		if err != nil {
+			t.Fatalf("parallel %d: %v", parallel, err)
+		}
+
+		downloaded, err := os.ReadFile(path)
+		if err != nil || !bytes.Equal(downloaded, content) {
+			t.Errorf("parallel %d: downloaded content does not match", parallel)
+		}
+		if last != int64(len(content)) {
+			t.Errorf("parallel %d: last progress %d, want %d", parallel, last, len(content))
+		}
+		if log.count() != 11 {
+			t.Errorf("parallel %d: requested %d ranges, want 11", parallel, log.count())
+		}
+		for _, leftover := range []string{path + ".partial", path + ".download.json"} {
+			if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+				t.Errorf("parallel %d: %s was not deleted", parallel, filepath.Base(leftover))
+			}
+		}
+	}
+}
+
+// downloadUntilInterrupted downloads itemId to path
+// and cancels it after chunks ranges are written
+func downloadUntilInterrupted(t *testing.T, graphClient *graph.GraphServiceClient, itemId string, path string,
+	options transfer.DownloadOptions, chunks int) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	written := 0
+	options.Progress = func(current int64, total int64) {
+		written++
+		if written == chunks {
+			cancel()
+		}
+	}
+	_, err := transfer.DownloadItem(ctx, graphClient, "mock-drive-id", itemId, path, options)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("interrupted download returned %v, want context.Canceled", err)
+	}
+}
+
+func TestDownloadItemResumes(t *testing.T) {
+	log := &downloadLog{}
+	graphClient, server := graphmock.NewTestClient(t, log)
+	options := transfer.DownloadOptions{ChunkSize: chunkSize}
+	_, content := writeFile(t, "large.bin", 5*chunkSize+10, 2)
+	itemId := server.SetDriveItemContent("large.bin", content)
+	path := filepath.Join(t.TempDir(), "large.bin")
+
+	downloadUntilInterrupted(t, graphClient, itemId, path, options, 2)
+
+	state, err := transfer.LoadDownloadState(path + ".download.json")
+	if err != nil || state == nil {
+		t.Fatalf("got state %v, error %v", state, err)
+	}
+	if len(state.CompletedRanges) != 1 || state.CompletedRanges[0] != "0-204799" {
+		t.Errorf("got completed ranges %v, want [0-204799]", state.CompletedRanges)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("incomplete download was written to the destination")
+	}
+
+	log.ranges = nil
+	_, err = transfer.DownloadItem(context.Background(), graphClient, "mock-drive-id", itemId, path, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(log.ranges) != 4 || log.ranges[0] != "bytes=204800-307199" {
+		t.Errorf("resumed with ranges %v, want 4 starting at byte 204800", log.ranges)
+	}
+	downloaded, _ := os.ReadFile(path)
+	if !bytes.Equal(downloaded, content) {
+		t.Error("resumed download does not match")
+	}
+}
+
+func TestDownloadItemStartsOverWhenItemChanges(t *testing.T) {
+	log := &downloadLog{}
+	graphClient, server := graphmock.NewTestClient(t, log)
+	options := transfer.DownloadOptions{ChunkSize: chunkSize}
+	_, original := writeFile(t, "large.bin", 5*chunkSize, 3)
+	itemId := server.SetDriveItemContent("large.bin", original)
+	path := filepath.Join(t.TempDir(), "large.bin")
+
+	downloadUntilInterrupted(t, graphClient, itemId, path, options, 2)
+
+	_, content := writeFile(t, "changed.bin", 5*chunkSize, 4)
+	server.SetDriveItemContent("large.bin", content)
+	log.ranges = nil
+	_, err := transfer.DownloadItem(context.Background(), graphClient, "mock-drive-id", itemId, path, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(log.ranges) != 5 {
+		t.Errorf("requested %d ranges, want all 5", len(log.ranges))
+	}
+	downloaded, _ := os.ReadFile(path)
+	if !bytes.Equal(downloaded, content) {
+		t.Error("download is not the changed content")
+	}
+}
+
+func TestDownloadItemLimited(t *testing.T) {
+	graphClient, server := graphmock.NewTestClient(t)
+	// 500 KiB at 2 MiB/s, shared by four parallel ranges
+	options := transfer.DownloadOptions{ChunkSize: chunkSize, Parallel: 4, Limiter: bandwidth.NewLimiter(2 * 1024 * 1024)}
+	_, content := writeFile(t, "large.bin", 5*chunkSize, 6)
+	itemId := server.SetDriveItemContent("large.bin", content)
+	path := filepath.Join(t.TempDir(), "large.bin")
+
+	started := time.Now()
+	_, err := transfer.DownloadItem(context.Background(), graphClient, "mock-drive-id", itemId, path, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(started); elapsed < 200*time.Millisecond {
+		t.Errorf("500 KiB at 2 MiB/s took %v", elapsed)
+	}
+	downloaded, _ := os.ReadFile(path)
+	if !bytes.Equal(downloaded, content) {
+		t.Error("limited download does not match")
+	}
+}
+
+func TestDownloadItemFailsOnMismatch(t *testing.T) {
+	log := &downloadLog{}
+	graphClient, server := graphmock.NewTestClient(t, log)
+	options := transfer.DownloadOptions{ChunkSize: chunkSize}
+	_, content := writeFile(t, "large.bin", 2*chunkSize, 5)
+	itemId := server.SetDriveItemContent("large.bin", content)
+	path := filepath.Join(t.TempDir(), "large.bin")
+
+	log.corrupt = true
+	_, err := transfer.DownloadItem(context.Background(), graphClient, "mock-drive-id", itemId, path, options)
+	var mismatch *transfer.MismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("got %v, want a mismatch", err)
+	}
+
+	for _, leftover := range []string{path, path + ".partial", path + ".download.json"} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("%s was left behind", filepath.Base(leftover))
+		}
+	}
+}
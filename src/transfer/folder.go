@@ -0,0 +1,351 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sdksnippets/bandwidth"
+	"sdksnippets/progress"
+	"strings"
+	"sync"
+	"time"
+
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/drives"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+// SimpleUploadLimit is the largest file Graph accepts in a single PUT
+// to the content of a drive item.
+const SimpleUploadLimit int64 = 4 * 1024 * 1024
+
+// FolderUploadOptions configures UploadFolder.
+type FolderUploadOptions struct {
+	// Workers is the number of files uploaded at once. The default is 4.
+	Workers int
+	// SliceSize is the size of each upload session request, a multiple
+	// of 320 KiB. The default is DefaultSliceSize.
+	SliceSize int64
+	// Progress, if set, is given a transfer for each file that is
+	// uploaded. Skipped files are not reported. The caller calls its
+	// Finish method once UploadFolder returns.
+	Progress *progress.Reporter
+	// Limiter, if set, caps the combined rate of every upload
+	Limiter *bandwidth.Limiter
+}
+
+// FileError is the failure to upload one file.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// FolderUploadSummary reports what UploadFolder did.
+type FolderUploadSummary struct {
+	FoldersCreated int
+	Uploaded       int
+	// Skipped counts files whose remote copy already
+	// had the same size and hash
+	Skipped       int
+	BytesUploaded int64
+	Failures      []*FileError
+	Elapsed       time.Duration
+}
+
+// UploadFolder uploads every file under localDir to the folder at
+// remotePath in the drive, relative to its root, creating any folders
+// that are missing. Files smaller than SimpleUploadLimit are sent in one
+// PUT, and larger files through an upload session. A file is skipped if
+// the drive already has one at its path with the same size and hashes.
+// Each uploaded file is checked against the item Graph returns, and
+// fails with a *MismatchError if its content differs.
+//
+// A file that fails to upload is recorded in the summary and the others
+// continue. The returned error is only set if localDir cannot be read or
+// ctx is cancelled. Cancelling ctx stops large files between slices and
+// deletes their upload sessions.
+func UploadFolder(ctx context.Context, graphClient *graph.GraphServiceClient, driveId string,
+	localDir string, remotePath string, options FolderUploadOptions) (*FolderUploadSummary, error) {
+	if options.Workers < 1 {
+		options.Workers = 4
+	}
+	if options.SliceSize == 0 {
+		options.SliceSize = DefaultSliceSize
+	}
+
+	uploader := &folderUploader{
+		graphClient: graphClient,
+		driveId:     driveId,
+		sliceSize:   options.SliceSize,
+		progress:    options.Progress,
+		limiter:     options.Limiter,
+		summary:     &FolderUploadSummary{},
+	}
+	started := time.Now()
+
+	type job struct {
+		localPath  string
+		remotePath string
+	}
+	jobs := make(chan job)
+	var workers sync.WaitGroup
+	for range options.Workers {
+		workers.Go(func() {
+			for job := range jobs {
+				uploader.uploadFile(ctx, job.localPath, job.remotePath)
+			}
+		})
+	}
+
+	remoteRoot := strings.Trim(remotePath, "/")
+	// Files in a folder that could not be created are not sent to workers
+	failedFolders := map[string]error{}
+	err := filepath.WalkDir(localDir, func(localPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		relative, err := filepath.Rel(localDir, localPath)
+		if err != nil {
+			return err
+		}
+		itemPath := path.Join(remoteRoot, filepath.ToSlash(relative))
+		if parentErr, failed := failedFolders[path.Dir(itemPath)]; failed {
+			uploader.fail(localPath, parentErr)
+			if entry.IsDir() {
+				failedFolders[itemPath] = parentErr
+			}
+			return nil
+		}
+
+		if entry.IsDir() {
+			err := uploader.ensureFolder(ctx, itemPath)
+			if err != nil {
+				failedFolders[itemPath] = err
+				uploader.fail(localPath, err)
+			}
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			jobs <- job{localPath: localPath, remotePath: itemPath}
+		}
+		return nil
+	})
+	close(jobs)
+	workers.Wait()
+
+	uploader.summary.Elapsed = time.Since(started)
+	return uploader.summary, err
+}
+
+type folderUploader struct {
+	graphClient *graph.GraphServiceClient
+	driveId     string
+	sliceSize   int64
+	progress    *progress.Reporter
+	limiter     *bandwidth.Limiter
+
+	mu      sync.Mutex
+	summary *FolderUploadSummary
+}
+
+func (u *folderUploader) item(itemPath string) *drives.ItemItemsDriveItemItemRequestBuilder {
+	return u.graphClient.Drives().ByDriveId(u.driveId).Items().ByDriveItemId("root:/" + itemPath + ":")
+}
+
+func (u *folderUploader) fail(localPath string, err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.summary.Failures = append(u.summary.Failures, &FileError{Path: localPath, Err: err})
+}
+
+// ensureFolder creates the folder at itemPath if it does not exist.
+// Its parent must already exist.
+func (u *folderUploader) ensureFolder(ctx context.Context, itemPath string) error {
+	if itemPath == "" || itemPath == "." {
+		return nil
+	}
+
+	existing, err := u.item(itemPath).Get(ctx, nil)
+	if err == nil {
+		if existing.GetFolder() == nil {
+			return fmt.Errorf("%s exists in the drive and is not a folder", itemPath)
+		}
+		return nil
+	}
+	if !isStatus(err, http.StatusNotFound) {
+		return fmt.Errorf("getting folder %s: %w", itemPath, err)
+	}
+
+	folder := models.NewDriveItem()
+	name := path.Base(itemPath)
+	folder.SetName(&name)
+	folder.SetFolder(models.NewFolder())
+	folder.SetAdditionalData(map[string]any{"@microsoft.graph.conflictBehavior": "fail"})
+
+	parent := path.Dir(itemPath)
+	parentBuilder := u.graphClient.Drives().ByDriveId(u.driveId).Items().ByDriveItemId("root")
+	if parent != "." {
+		parentBuilder = u.item(parent)
+	}
+	_, err = parentBuilder.Children().Post(ctx, folder, nil)
+	if err != nil {
+		return fmt.Errorf("creating folder %s: %w", itemPath, err)
+	}
+
+	u.mu.Lock()
+	u.summary.FoldersCreated++
+	u.mu.Unlock()
+	return nil
+}
+
+func (u *folderUploader) uploadFile(ctx context.Context, localPath string, itemPath string) {
+	if ctx.Err() != nil {
+		u.fail(localPath, ctx.Err())
+		return
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		u.fail(localPath, err)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		u.fail(localPath, err)
+		return
+	}
+
+	unchanged, err := u.matchesRemote(ctx, file, info.Size(), itemPath)
+	if err != nil {
+		u.fail(localPath, err)
+		return
+	}
+	if unchanged {
+		u.mu.Lock()
+		u.summary.Skipped++
+		u.mu.Unlock()
+		return
+	}
+
+	var fileTransfer *progress.Transfer
+	if u.progress != nil {
+		fileTransfer = u.progress.Start(itemPath, info.Size())
+	}
+
+	var item models.DriveItemable
+	if info.Size() < SimpleUploadLimit {
+		item, err = u.putContent(ctx, file, info.Size(), itemPath)
+	} else {
+		item, err = u.uploadLarge(ctx, file, itemPath, fileTransfer)
+	}
+	if err == nil {
+		err = verifyContent(item, file, info.Size(), localPath)
+	}
+	if err != nil {
+		if fileTransfer != nil {
+			fileTransfer.Fail()
+		}
+		u.fail(localPath, err)
+		return
+	}
+	if fileTransfer != nil {
+		fileTransfer.Done()
+	}
+
+	u.mu.Lock()
+	u.summary.Uploaded++
+	u.summary.BytesUploaded += info.Size()
+	u.mu.Unlock()
+}
+
+// matchesRemote reports whether the drive has a file at itemPath with
+// the size and hashes of file. The local file is only hashed if the sizes
+// match, and only with the hashes the drive reports.
+func (u *folderUploader) matchesRemote(ctx context.Context, file *os.File, size int64, itemPath string) (bool, error) {
+	remote, err := u.item(itemPath).Get(ctx, nil)
+	if isStatus(err, http.StatusNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("getting %s: %w", itemPath, err)
+	}
+	if remote.GetFile() == nil || remote.GetSize() == nil || *remote.GetSize() != size {
+		return false, nil
+	}
+
+	reported := reportedHashes(remote)
+	if len(reported) == 0 {
+		return false, nil
+	}
+	err = checkHashes(file, size, file.Name(), reported)
+	if isMismatch(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (u *folderUploader) putContent(ctx context.Context, file *os.File, size int64, itemPath string) (models.DriveItemable, error) {
+	content := make([]byte, size)
+	_, err := bandwidth.LimitByteStream(ctx, file, u.limiter).ReadAt(content, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	item, err := u.item(itemPath).Content().Put(ctx, content, nil)
+	if err != nil {
+		return nil, fmt.Errorf("uploading %s: %w", itemPath, err)
+	}
+
+	return item, nil
+}
+
+func (u *folderUploader) uploadLarge(ctx context.Context, file *os.File, itemPath string,
+	fileTransfer *progress.Transfer) (models.DriveItemable, error) {
+	itemUploadProperties := models.NewDriveItemUploadableProperties()
+	itemUploadProperties.SetAdditionalData(map[string]any{"@microsoft.graph.conflictBehavior": "replace"})
+	uploadSessionRequestBody := drives.NewItemItemsItemCreateUploadSessionPostRequestBody()
+	uploadSessionRequestBody.SetItem(itemUploadProperties)
+
+	uploadSession, err := u.item(itemPath).CreateUploadSession().Post(ctx, uploadSessionRequestBody, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating upload session for %s: %w", itemPath, err)
+	}
+
+	options := UploadOptions{SliceSize: u.sliceSize}
+	if fileTransfer != nil {
+		options.Progress = fileTransfer.UploadProgress()
+	}
+	byteStream := bandwidth.LimitByteStream(ctx, file, u.limiter)
+	uploadResult, err := UploadContext[models.DriveItemable](ctx, u.graphClient.RequestAdapter, uploadSession, byteStream,
+		models.CreateDriveItemFromDiscriminatorValue, options)
+	if err != nil {
+		return nil, fmt.Errorf("uploading %s: %w", itemPath, err)
+	}
+
+	return uploadResult.Item, nil
+}
@@ -0,0 +1,129 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sdksnippets/progress"
+	"sdksnippets/transfer"
+	"strings"
+	"testing"
+)
+
+// writeTree writes files, keyed by slash-separated path, under a new directory
+func writeTree(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, content, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestUploadFolder(t *testing.T) {
+	graphClient, server, log := newMockClient(t)
+
+	large := bytes.Repeat([]byte("0123456789abcdef"), int(transfer.SimpleUploadLimit/16)+100)
+	files := map[string][]byte{
+		"readme.txt":            []byte("hello"),
+		"docs/a.txt":            []byte("first"),
+		"docs/nested/b.txt":     []byte("second"),
+		"docs/nested/large.bin": large,
+	}
+	dir := writeTree(t, files)
+	reporter := progress.NewReporter(progress.Quiet{}, progress.Options{})
+	options := transfer.FolderUploadOptions{Workers: 3, SliceSize: sliceSize, Progress: reporter}
+
+	summary, err := transfer.UploadFolder(context.Background(), graphClient, "mock-drive-id", dir, "/Backup/", options)
+	if err != nil {
+		t.Fatalf("uploading: %v", err)
+	}
+	stats := reporter.Stats()
+	if stats.Files != 4 || stats.FilesDone != 4 || stats.Bytes != summary.BytesUploaded || stats.TotalBytes != stats.Bytes {
+		t.Errorf("progress = %+v, want 4 files of %d bytes done", stats, summary.BytesUploaded)
+	}
+	if len(summary.Failures) != 0 {
+		t.Fatalf("failures: %v", summary.Failures)
+	}
+	// Backup, Backup/docs and Backup/docs/nested
+	if summary.FoldersCreated != 3 || summary.Uploaded != 4 || summary.Skipped != 0 {
+		t.Errorf("summary = %+v", summary)
+	}
+	if want := int64(len(large) + 16); summary.BytesUploaded != want {
+		t.Errorf("BytesUploaded = %d, want %d", summary.BytesUploaded, want)
+	}
+	for name, content := range files {
+		uploaded, ok := server.DriveItemContent("Backup/" + name)
+		if !ok || !bytes.Equal(uploaded, content) {
+			t.Errorf("Backup/%s was not uploaded intact", name)
+		}
+	}
+
+	// Only the large file goes through an upload session
+	wantSlices := (len(large) + sliceSize - 1) / sliceSize
+	if len(log.ranges) != wantSlices {
+		t.Errorf("sent %d slices, want %d", len(log.ranges), wantSlices)
+	}
+
+	// A second run skips everything, except the file that changed
+	err = os.WriteFile(filepath.Join(dir, "docs", "a.txt"), []byte("FIRST"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options.Progress = nil
+	summary, err = transfer.UploadFolder(context.Background(), graphClient, "mock-drive-id", dir, "Backup", options)
+	if err != nil {
+		t.Fatalf("uploading again: %v", err)
+	}
+	if summary.FoldersCreated != 0 || summary.Uploaded != 1 || summary.Skipped != 3 || len(summary.Failures) != 0 {
+		t.Errorf("second summary = %+v", summary)
+	}
+	if uploaded, _ := server.DriveItemContent("Backup/docs/a.txt"); string(uploaded) != "FIRST" {
+		t.Errorf("changed file = %q, want FIRST", uploaded)
+	}
+}
+
+func TestUploadFolderReportsFailures(t *testing.T) {
+	graphClient, _, _ := newMockClient(t)
+
+	// The drive has a file where the local tree has a folder
+	dir := writeTree(t, map[string][]byte{"clash": []byte("file")})
+	_, err := transfer.UploadFolder(context.Background(), graphClient, "mock-drive-id", dir, "Target",
+		transfer.FolderUploadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir = writeTree(t, map[string][]byte{
+		"clash/inside.txt": []byte("lost"),
+		"fine.txt":         []byte("kept"),
+	})
+	summary, err := transfer.UploadFolder(context.Background(), graphClient, "mock-drive-id", dir, "Target",
+		transfer.FolderUploadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Uploaded != 1 || len(summary.Failures) != 2 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	for _, failure := range summary.Failures {
+		if !strings.Contains(failure.Error(), "not a folder") {
+			t.Errorf("failure = %v", failure)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// byteRange is an inclusive range of byte offsets
+type byteRange struct {
+	start int64
+	end   int64
+}
+
+func (r byteRange) String() string {
+	return fmt.Sprintf("%d-%d", r.start, r.end)
+}
+
+// parseRange parses a range in the "start-end" form of nextExpectedRanges.
+// An open range such as "1024-" ends at the last byte of size.
+func parseRange(value string, size int64) (byteRange, error) {
+	startValue, endValue, found := strings.Cut(value, "-")
+	if !found {
+		return byteRange{}, fmt.Errorf("invalid range %q", value)
+	}
+
+	start, err := strconv.ParseInt(startValue, 10, 64)
+	if err != nil {
+		return byteRange{}, fmt.Errorf("invalid range %q", value)
+	}
+	end := size - 1
+	if endValue != "" {
+		end, err = strconv.ParseInt(endValue, 10, 64)
+		if err != nil {
+			return byteRange{}, fmt.Errorf("invalid range %q", value)
+		}
+	}
+	if start < 0 || end < start-1 {
+		return byteRange{}, fmt.Errorf("invalid range %q", value)
+	}
+
+	return byteRange{start: start, end: end}, nil
+}
+
+// parseRanges parses ranges, skipping any that are invalid,
+// and returns them sorted and merged
+func parseRanges(values []string, size int64) []byteRange {
+	var ranges []byteRange
+	for _, value := range values {
+		r, err := parseRange(value, size)
+		if err == nil && r.end >= r.start {
+			ranges = append(ranges, r)
+		}
+	}
+
+	return mergeRanges(ranges)
+}
+
+func mergeRanges(ranges []byteRange) []byteRange {
+	slices.SortFunc(ranges, func(a, b byteRange) int {
+		return cmp.Compare(a.start, b.start)
+	})
+
+	var merged []byteRange
+	for _, r := range ranges {
+		if last := len(merged) - 1; last >= 0 && r.start <= merged[last].end+1 {
+			merged[last].end = max(merged[last].end, r.end)
+			continue
+		}
+		merged = append(merged, r)
+	}
+
+	return merged
+}
+
+func formatRanges(ranges []byteRange) []string {
+	values := make([]string, len(ranges))
+	for i, r := range ranges {
+		values[i] = r.String()
+	}
+
+	return values
+}
+
+// addRange adds start-end to ranges
+func addRange(values []string, start int64, end int64) []string {
+	ranges := append(parseRanges(values, end+1), byteRange{start: start, end: end})
+	return formatRanges(mergeRanges(ranges))
+}
+
+// sliceStart returns the first byte of the slice ending at end, when a
+// file of size bytes is sent in slices of sliceSize bytes the way
+// fileuploader splits it: each of nextExpectedRanges in turn, from its
+// start. It returns end+1 if no expected range contains end.
+func sliceStart(nextExpectedRanges []string, end int64, size int64, sliceSize int64) int64 {
+	for _, value := range nextExpectedRanges {
+		r, err := parseRange(value, size)
+		if err != nil || end < r.start || end > r.end {
+			continue
+		}
+
+		return r.start + (end-r.start)/sliceSize*sliceSize
+	}
+
+	return end + 1
+}
+
+// complementRanges returns the ranges of a file of size
+// bytes that are not in values
+func complementRanges(values []string, size int64) []string {
+	var complement []byteRange
+	next := int64(0)
+	for _, r := range parseRanges(values, size) {
+		if r.start > next {
+			complement = append(complement, byteRange{start: next, end: r.start - 1})
+		}
+		next = r.end + 1
+	}
+	if next < size {
+		complement = append(complement, byteRange{start: next, end: size - 1})
+	}
+
+	return formatRanges(complement)
+}
@@ -0,0 +1,331 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+// Package transfer moves files to and from Microsoft Graph, building on
+// the upload sessions of the fileuploader package.
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sdksnippets/bandwidth"
+	"time"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoft/kiota-abstractions-go/serialization"
+	"github.com/microsoftgraph/msgraph-sdk-go-core/fileuploader"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+)
+
+// DefaultSliceSize is the size of each upload request. Graph requires
+// slices to be a multiple of 320 KiB.
+const DefaultSliceSize int64 = 10 * 320 * 1024
+
+// stateFileSuffix is appended to the path of the uploaded
+// file to name its state file
+const stateFileSuffix = ".upload.json"
+
+// FileIdentity identifies the content of a local file. An upload is only
+// resumed if the file still has the identity it had when the upload began.
+type FileIdentity struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	// SHA256 is the hex-encoded SHA-256 hash of the content
+	SHA256 string `json:"sha256"`
+}
+
+// Matches reports whether two identities describe the same content.
+func (f FileIdentity) Matches(other FileIdentity) bool {
+	return f.Path == other.Path && f.Size == other.Size &&
+		f.ModTime.Equal(other.ModTime) && f.SHA256 == other.SHA256
+}
+
+// UploadState is the progress of an upload, saved in a state file
+// next to the uploaded file so that another run can resume it.
+type UploadState struct {
+	UploadUrl          string       `json:"uploadUrl"`
+	ExpirationDateTime time.Time    `json:"expirationDateTime"`
+	File               FileIdentity `json:"file"`
+	// ConfirmedRanges are the byte ranges the service has accepted, in
+	// the "start-end" form of nextExpectedRanges. On resume the session's
+	// nextExpectedRanges, not these, decide what is sent.
+	ConfirmedRanges []string `json:"confirmedRanges"`
+}
+
+// ResumableUploadOptions configures UploadResumable.
+type ResumableUploadOptions struct {
+	// StatePath is the state file. The default is the uploaded
+	// file's path followed by .upload.json.
+	StatePath string
+	// SliceSize is the size of each upload request, a multiple
+	// of 320 KiB. The default is DefaultSliceSize.
+	SliceSize int64
+	// Progress is called after each slice is accepted
+	Progress fileuploader.ProgressCallBack
+	// Limiter, if set, caps the rate the file is read for upload
+	Limiter *bandwidth.Limiter
+}
+
+// SessionFactory creates an upload session for a file, for example with
+// the CreateUploadSession request builder of a drive item.
+type SessionFactory func() (models.UploadSessionable, error)
+
+// UploadResumable uploads the file at path and returns the item created
+// by the final slice, parsed with factory.
+//
+// The upload session and the ranges accepted so far are saved to a state
+// file as the upload runs. If the upload is interrupted, even by the
+// process ending, calling UploadResumable again with the same file asks
+// the saved session which ranges it still needs and sends only those. A
+// new session is created with createSession if there is no state file,
+// the file changed since the state was saved, or the saved session has
+// expired. The state file is deleted once the upload completes.
+//
+// Once the upload completes, the returned item is verified against the
+// file with VerifyItem. If they differ, the item is returned with a
+// *MismatchError.
+func UploadResumable[T serialization.Parsable](adapter abstractions.RequestAdapter, path string,
+	createSession SessionFactory, factory serialization.ParsableFactory, options ResumableUploadOptions) (T, error) {
+	var zero T
+
+	if options.StatePath == "" {
+		options.StatePath = path + stateFileSuffix
+	}
+	if options.SliceSize == 0 {
+		options.SliceSize = DefaultSliceSize
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return zero, fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer file.Close()
+
+	identity, err := identifyFile(file, path)
+	if err != nil {
+		return zero, err
+	}
+
+	state, session, err := resumeSession(adapter, options.StatePath, identity)
+	if err != nil {
+		return zero, err
+	}
+	if session == nil {
+		uploadSession, err := createSession()
+		if err != nil {
+			return zero, fmt.Errorf("creating upload session: %w", err)
+		}
+		session = uploadSession
+		state = &UploadState{
+			UploadUrl:          *uploadSession.GetUploadUrl(),
+			ExpirationDateTime: derefTime(uploadSession.GetExpirationDateTime()),
+			File:               identity,
+		}
+		err = saveUploadState(options.StatePath, state)
+		if err != nil {
+			return zero, err
+		}
+	}
+
+	byteStream := bandwidth.LimitByteStream(context.Background(), file, options.Limiter)
+	task := fileuploader.NewLargeFileUploadTask[T](adapter, session, byteStream, options.SliceSize, factory, errorMapping)
+
+	// The task keeps sending slices after one fails and reports only
+	// those that succeed, so each slice's start is worked out from its
+	// end rather than from the slice reported before it
+	expectedRanges := session.GetNextExpectedRanges()
+	var saveErr error
+	result := task.Upload(func(current int64, total int64) {
+		start := sliceStart(expectedRanges, current, total, options.SliceSize)
+		state.ConfirmedRanges = addRange(state.ConfirmedRanges, start, current)
+		if err := saveUploadState(options.StatePath, state); err != nil && saveErr == nil {
+			saveErr = err
+		}
+		if options.Progress != nil {
+			options.Progress(current, total)
+		}
+	})
+
+	if !result.GetUploadSucceeded() {
+		err = errors.Join(result.GetResponseErrors()...)
+		return zero, fmt.Errorf("uploading %s, run again to resume: %w", path, errors.Join(err, saveErr))
+	}
+
+	// The session is complete, so the state is of no use even if the
+	// content turns out to differ
+	err = os.Remove(options.StatePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return result.GetItemResponse(), fmt.Errorf("deleting upload state: %w", err)
+	}
+
+	item := result.GetItemResponse()
+	if driveItem, ok := any(item).(models.DriveItemable); ok {
+		err = verifyContent(driveItem, file, identity.Size, path)
+	}
+
+	return item, err
+}
+
+// errorMapping parses Graph error responses, as the request builders do
+var errorMapping = abstractions.ErrorMappings{
+	"XXX": odataerrors.CreateODataErrorFromDiscriminatorValue,
+}
+
+// resumeSession returns the saved state and its session, refreshed
+// with the ranges the service still expects. It returns a nil session
+// if the saved upload cannot be resumed.
+func resumeSession(adapter abstractions.RequestAdapter, statePath string,
+	identity FileIdentity) (*UploadState, models.UploadSessionable, error) {
+	state, err := LoadUploadState(statePath)
+	if err != nil || state == nil {
+		return nil, nil, err
+	}
+	if !time.Now().Before(state.ExpirationDateTime) {
+		return nil, nil, nil
+	}
+
+	session := models.NewUploadSession()
+	session.SetUploadUrl(&state.UploadUrl)
+	session.SetExpirationDateTime(&state.ExpirationDateTime)
+
+	// The task only reads and deletes the session, so it needs no file
+	task := fileuploader.NewLargeFileUploadTask[serialization.Parsable](adapter, session, nil, 0, nil, errorMapping)
+	if !state.File.Matches(identity) {
+		// The file changed, so the uploaded ranges are of no use. Failing
+		// to delete the session only leaves it to expire.
+		task.Cancel()
+		return nil, nil, nil
+	}
+
+	err = task.RefreshUploadStatus()
+	if isStatus(err, http.StatusNotFound) {
+		// The session expired or was cancelled
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading upload session status: %w", err)
+	}
+	if len(session.GetNextExpectedRanges()) == 0 {
+		return nil, nil, nil
+	}
+
+	state.ConfirmedRanges = complementRanges(session.GetNextExpectedRanges(), identity.Size)
+	return state, session, nil
+}
+
+// LoadUploadState reads the state file at path. It returns nil if
+// the file does not exist.
+func LoadUploadState(path string) (*UploadState, error) {
+	state := &UploadState{}
+	found, err := loadJson(path, state)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// loadJson parses the JSON file at path into value. It
+// returns false if the file does not exist.
+func loadJson(path string, value any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	err = json.Unmarshal(data, value)
+	if err != nil {
+		return false, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return true, nil
+}
+
+// saveUploadState saves state with saveJson. The upload URL authorizes
+// writes to the file without a token, so only the owner can read it.
+func saveUploadState(path string, state *UploadState) error {
+	err := saveJson(path, state)
+	if err != nil {
+		return fmt.Errorf("saving upload state: %w", err)
+	}
+
+	return nil
+}
+
+// saveJson replaces the file at path with value as JSON in one step, so a
+// crash never leaves a partly written file behind. The file has mode 0600.
+func saveJson(path string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// CreateTemp creates the file with mode 0600
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+func identifyFile(file *os.File, path string) (FileIdentity, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return FileIdentity{}, fmt.Errorf("reading size of %s: %w", path, err)
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, io.NewSectionReader(file, 0, info.Size()))
+	if err != nil {
+		return FileIdentity{}, fmt.Errorf("hashing %s: %w", path, err)
+	}
+
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return FileIdentity{}, err
+	}
+
+	return FileIdentity{
+		Path:    absolutePath,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UTC(),
+		SHA256:  hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+func isStatus(err error, status int) bool {
+	var apiError abstractions.ApiErrorable
+	return errors.As(err, &apiError) && apiError.GetStatusCode() == status
+}
+
+func derefTime(value *time.Time) time.Time {
+	if value == nil {
+		return time.Time{}
+	}
+
+	return *value
+}
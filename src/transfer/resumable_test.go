@@ -0,0 +1,275 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sdksnippets/graphmock"
+	"sdksnippets/transfer"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	khttp "github.com/microsoft/kiota-http-go"
+	graph "github.com/microsoftgraph/msgraph-sdk-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/drives"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+const sliceSize = 320 * 1024
+
+// rangeLog records the Content-Range of every upload request
+type rangeLog struct {
+	mu     sync.Mutex
+	ranges []string
+}
+
+func (l *rangeLog) Intercept(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request) (*http.Response, error) {
+	if contentRange := req.Header.Get("Content-Range"); contentRange != "" {
+		l.mu.Lock()
+		l.ranges = append(l.ranges, contentRange)
+		l.mu.Unlock()
+	}
+
+	return pipeline.Next(req, middlewareIndex)
+}
+
+func newMockClient(t *testing.T) (*graph.GraphServiceClient, *graphmock.Server, *rangeLog) {
+	t.Helper()
+
+	log := &rangeLog{}
+	graphClient, server := graphmock.NewTestClient(t, log)
+
+	return graphClient, server, log
+}
+
+// writeFile writes size bytes of generated content to a new file
+func writeFile(t *testing.T, name string, size int, seed byte) (string, []byte) {
+	t.Helper()
+
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i*7) + seed
+	}
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, content, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path, content
+}
+
+// driveSessions creates upload sessions for itemPath and counts them
+type driveSessions struct {
+	graphClient *graph.GraphServiceClient
+	itemPath    string
+	created     int
+}
+
+func (d *driveSessions) create() (models.UploadSessionable, error) {
+	d.created++
+	return d.graphClient.Drives().ByDriveId("mock-drive-id").Items().ByDriveItemId("root:/"+d.itemPath+":").
+		CreateUploadSession().Post(context.Background(), drives.NewItemItemsItemCreateUploadSessionPostRequestBody(), nil)
+}
+
+// uploadUntilCrash uploads path and panics after slices slices,
+// as if the process had ended
+func uploadUntilCrash(t *testing.T, graphClient *graph.GraphServiceClient, path string, sessions *driveSessions, slices int) {
+	t.Helper()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("upload finished before the crash")
+		}
+	}()
+
+	uploaded := 0
+	transfer.UploadResumable[models.DriveItemable](graphClient.RequestAdapter, path, sessions.create,
+		models.CreateDriveItemFromDiscriminatorValue, transfer.ResumableUploadOptions{
+			SliceSize: sliceSize,
+			Progress: func(current int64, total int64) {
+				uploaded++
+				if uploaded == slices {
+					panic("crash")
+				}
+			},
+		})
+}
+
+func TestUploadResumableContinuesAfterCrash(t *testing.T) {
+	graphClient, server, log := newMockClient(t)
+	path, content := writeFile(t, "large.bin", 5*sliceSize+100, 1)
+	sessions := &driveSessions{graphClient: graphClient, itemPath: "Documents/large.bin"}
+
+	uploadUntilCrash(t, graphClient, path, sessions, 2)
+
+	state, err := transfer.LoadUploadState(path + ".upload.json")
+	if err != nil || state == nil {
+		t.Fatalf("got state %v, error %v", state, err)
+	}
+	if len(state.ConfirmedRanges) != 1 || state.ConfirmedRanges[0] != "0-655359" {
+		t.Errorf("got confirmed ranges %v, want [0-655359]", state.ConfirmedRanges)
+	}
+	info, err := os.Stat(path + ".upload.json")
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("state file mode is %v, want 0600", info.Mode().Perm())
+	}
+
+	log.ranges = nil
+	item, err := transfer.UploadResumable[models.DriveItemable](graphClient.RequestAdapter, path, sessions.create,
+		models.CreateDriveItemFromDiscriminatorValue, transfer.ResumableUploadOptions{SliceSize: sliceSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sessions.created != 1 {
+		t.Errorf("created %d sessions, want 1", sessions.created)
+	}
+	if len(log.ranges) != 4 || log.ranges[0] != "bytes 655360-983039/1638500" {
+		t.Errorf("resumed with ranges %v, want 4 starting at byte 655360", log.ranges)
+	}
+	uploaded, _ := server.DriveItemContent("Documents/large.bin")
+	if !bytes.Equal(uploaded, content) || item.GetId() == nil {
+		t.Errorf("uploaded content does not match: got %d bytes, want %d", len(uploaded), len(content))
+	}
+	if _, err := os.Stat(path + ".upload.json"); !os.IsNotExist(err) {
+		t.Error("state file was not deleted after the upload completed")
+	}
+}
+
+func TestUploadResumableStartsOverWhenFileChanges(t *testing.T) {
+	graphClient, server, _ := newMockClient(t)
+	path, _ := writeFile(t, "large.bin", 3*sliceSize, 1)
+	sessions := &driveSessions{graphClient: graphClient, itemPath: "Documents/large.bin"}
+
+	uploadUntilCrash(t, graphClient, path, sessions, 1)
+
+	content := bytes.Repeat([]byte{9}, 3*sliceSize)
+	err := os.WriteFile(path, content, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = transfer.UploadResumable[models.DriveItemable](graphClient.RequestAdapter, path, sessions.create,
+		models.CreateDriveItemFromDiscriminatorValue, transfer.ResumableUploadOptions{SliceSize: sliceSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sessions.created != 2 {
+		t.Errorf("created %d sessions, want 2", sessions.created)
+	}
+	uploaded, _ := server.DriveItemContent("Documents/large.bin")
+	if !bytes.Equal(uploaded, content) {
+		t.Error("uploaded content is not the changed file")
+	}
+}
+
+func TestUploadResumableStartsOverWhenSessionExpired(t *testing.T) {
+	for name, expire := range map[string]func(t *testing.T, statePath string, state *transfer.UploadState){
+		// The saved expiration time has passed
+		"saved expiration": func(t *testing.T, statePath string, state *transfer.UploadState) {
+			state.ExpirationDateTime = time.Now().Add(-time.Minute)
+			data, _ := json.Marshal(state)
+			if err := os.WriteFile(statePath, data, 0600); err != nil {
+				t.Fatal(err)
+			}
+		},
+		// The service no longer knows the session
+		"session deleted": func(t *testing.T, statePath string, state *transfer.UploadState) {
+			request, _ := http.NewRequest(http.MethodDelete, state.UploadUrl, nil)
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			graphClient, server, _ := newMockClient(t)
+			path, content := writeFile(t, "large.bin", 3*sliceSize, 1)
+			sessions := &driveSessions{graphClient: graphClient, itemPath: "Documents/large.bin"}
+
+			uploadUntilCrash(t, graphClient, path, sessions, 1)
+			state, _ := transfer.LoadUploadState(path + ".upload.json")
+			expire(t, path+".upload.json", state)
+
+			_, err := transfer.UploadResumable[models.DriveItemable](graphClient.RequestAdapter, path, sessions.create,
+				models.CreateDriveItemFromDiscriminatorValue, transfer.ResumableUploadOptions{SliceSize: sliceSize})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sessions.created != 2 {
+				t.Errorf("created %d sessions, want 2", sessions.created)
+			}
+			uploaded, _ := server.DriveItemContent("Documents/large.bin")
+			if !bytes.Equal(uploaded, content) {
+				t.Error("uploaded content does not match")
+			}
+		})
+	}
+}
+
+// outOfOrderService fails the upload slice starting at failStart and
+// accepts the slices after it without passing them on, as Graph
+// accepts slices out of order while the mock server does not
+type outOfOrderService struct {
+	failStart string
+	failed    bool
+}
+
+func (s *outOfOrderService) Intercept(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request) (*http.Response, error) {
+	contentRange := req.Header.Get("Content-Range")
+	if strings.HasPrefix(contentRange, "bytes "+s.failStart+"-") {
+		s.failed = true
+		return fakeResponse(req, http.StatusInternalServerError,
+			`{"error":{"code":"generalException","message":"An unspecified error has occurred."}}`), nil
+	}
+	if s.failed && contentRange != "" {
+		return fakeResponse(req, http.StatusAccepted, `{"nextExpectedRanges":[]}`), nil
+	}
+
+	return pipeline.Next(req, middlewareIndex)
+}
+
+func fakeResponse(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func TestUploadResumableSkipsFailedSlices(t *testing.T) {
+	graphClient, _ := graphmock.NewTestClient(t, &outOfOrderService{failStart: "327680"})
+	path, _ := writeFile(t, "large.bin", 5*sliceSize+100, 1)
+	sessions := &driveSessions{graphClient: graphClient, itemPath: "Documents/large.bin"}
+
+	_, err := transfer.UploadResumable[models.DriveItemable](graphClient.RequestAdapter, path, sessions.create,
+		models.CreateDriveItemFromDiscriminatorValue, transfer.ResumableUploadOptions{SliceSize: sliceSize})
+	if err == nil {
+		t.Fatal("upload succeeded although a slice failed")
+	}
+
+	// The failed slice is not recorded as confirmed
+	state, err := transfer.LoadUploadState(path + ".upload.json")
+	if err != nil || state == nil {
+		t.Fatalf("got state %v, error %v", state, err)
+	}
+	want := []string{"0-327679", "655360-1638499"}
+	if !slices.Equal(state.ConfirmedRanges, want) {
+		t.Errorf("got confirmed ranges %v, want %v", state.ConfirmedRanges, want)
+	}
+}
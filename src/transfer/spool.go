@@ -0,0 +1,151 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+// DefaultSpoolMemory is the most of a stream SpoolStream
+// holds in memory before it moves it to a temporary file
+const DefaultSpoolMemory = 4 * 1024 * 1024
+
+// SpoolOptions configures SpoolStream.
+type SpoolOptions struct {
+	// Name is the name Stat reports, and the name used in errors
+	Name string
+	// MemoryLimit is the largest stream kept in memory. Longer streams
+	// are written to a temporary file. The default is DefaultSpoolMemory,
+	// and a negative limit always uses a file.
+	MemoryLimit int64
+	// Dir is the folder of the temporary file. The default is os.TempDir.
+	Dir string
+	// MaxSize is the longest stream accepted. 0 is no limit.
+	MaxSize int64
+}
+
+// Spool is a stream of unknown length read to its end, so that it has
+// the size an upload session needs. It implements fileuploader.ByteStream.
+type Spool struct {
+	name    string
+	memory  []byte
+	file    *os.File
+	size    int64
+	modTime time.Time
+}
+
+// SpoolStream reads stream to its end, in memory if it is no longer than
+// options.MemoryLimit and otherwise to a temporary file that is removed
+// by Close. It returns an error if stream is longer than options.MaxSize.
+func SpoolStream(stream io.Reader, options SpoolOptions) (*Spool, error) {
+	if options.MemoryLimit == 0 {
+		options.MemoryLimit = DefaultSpoolMemory
+	}
+	if options.MaxSize > 0 {
+		// One byte more, to tell a stream of MaxSize from a longer one
+		stream = io.LimitReader(stream, options.MaxSize+1)
+	}
+
+	spool := &Spool{name: options.Name, modTime: time.Now()}
+	// Also one byte more, to learn whether the stream fits in memory
+	memory, err := io.ReadAll(io.LimitReader(stream, max(options.MemoryLimit, 0)+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", options.Name, err)
+	}
+	if int64(len(memory)) <= options.MemoryLimit {
+		spool.memory = memory
+		spool.size = int64(len(memory))
+		return spool, spool.checkSize(options.MaxSize)
+	}
+
+	spool.file, err = os.CreateTemp(options.Dir, "spool-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary file for %s: %w", options.Name, err)
+	}
+	spool.size, err = io.Copy(spool.file, io.MultiReader(bytes.NewReader(memory), stream))
+	if err == nil {
+		err = spool.checkSize(options.MaxSize)
+	}
+	if err != nil {
+		spool.Close()
+		return nil, fmt.Errorf("spooling %s: %w", options.Name, err)
+	}
+
+	return spool, nil
+}
+
+func (s *Spool) checkSize(maxSize int64) error {
+	if maxSize > 0 && s.size > maxSize {
+		s.Close()
+		return fmt.Errorf("%s is larger than %d bytes", s.name, maxSize)
+	}
+
+	return nil
+}
+
+// Name returns the name given in SpoolOptions.
+func (s *Spool) Name() string {
+	return s.name
+}
+
+// Size returns the length of the stream.
+func (s *Spool) Size() int64 {
+	return s.size
+}
+
+// ReadAt reads the stream from off.
+func (s *Spool) ReadAt(p []byte, off int64) (int, error) {
+	if s.file != nil {
+		return s.file.ReadAt(p, off)
+	}
+
+	return bytes.NewReader(s.memory).ReadAt(p, off)
+}
+
+// Stat returns the name and size of the stream.
+func (s *Spool) Stat() (os.FileInfo, error) {
+	return spoolInfo{s}, nil
+}
+
+// Verify checks that item, as returned by an upload of the spool, has
+// its size and hashes, like VerifyItem does for a file.
+func (s *Spool) Verify(item models.DriveItemable) error {
+	return verifyContent(item, s, s.size, s.name)
+}
+
+// Close removes the temporary file, if there is one.
+func (s *Spool) Close() error {
+	s.memory = nil
+	if s.file == nil {
+		return nil
+	}
+
+	file := s.file
+	s.file = nil
+	err := file.Close()
+	if removeErr := os.Remove(file.Name()); removeErr != nil && err == nil {
+		err = removeErr
+	}
+
+	return err
+}
+
+// spoolInfo describes a Spool as a file
+type spoolInfo struct {
+	spool *Spool
+}
+
+func (i spoolInfo) Name() string       { return i.spool.name }
+func (i spoolInfo) Size() int64        { return i.spool.size }
+func (i spoolInfo) Mode() fs.FileMode  { return 0600 }
+func (i spoolInfo) ModTime() time.Time { return i.spool.modTime }
+func (i spoolInfo) IsDir() bool        { return false }
+func (i spoolInfo) Sys() any           { return nil }
@@ -0,0 +1,146 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sdksnippets/transfer"
+	"testing"
+	"testing/iotest"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+// tempFiles returns the number of files in dir
+func tempFiles(t *testing.T, dir string) int {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return len(entries)
+}
+
+func TestSpoolStream(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		size   int
+		inFile bool
+	}{
+		{"empty", 0, false},
+		{"at memory limit", 1000, false},
+		{"past memory limit", 1001, true},
+		{"large", 5*sliceSize + 7, true},
+	} {
+		dir := t.TempDir()
+		content := bytes.Repeat([]byte{'x', 'y', 'z'}, test.size/3+1)[:test.size]
+
+		// A pipe returns a little at a time
+		stream := iotest.HalfReader(bytes.NewReader(content))
+		spool, err := transfer.SpoolStream(stream, transfer.SpoolOptions{Name: "stdin", MemoryLimit: 1000, Dir: dir})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if files := tempFiles(t, dir); (files == 1) != test.inFile {
+			t.Errorf("%s: %d temporary files, want a file %v", test.name, files, test.inFile)
+		}
+		info, err := spool.Stat()
+		if err != nil || info.Name() != "stdin" || info.Size() != int64(test.size) || spool.Size() != int64(test.size) {
+			t.Errorf("%s: Stat = %v, %v, want stdin of %d bytes", test.name, info, err, test.size)
+		}
+		spooled, err := io.ReadAll(io.NewSectionReader(spool, 0, spool.Size()))
+		if err != nil || !bytes.Equal(spooled, content) {
+			t.Errorf("%s: spooled content does not match", test.name)
+		}
+
+		if err := spool.Close(); err != nil {
+			t.Errorf("%s: Close = %v", test.name, err)
+		}
+		if files := tempFiles(t, dir); files != 0 {
+			t.Errorf("%s: %d temporary files left after Close", test.name, files)
+		}
+	}
+}
+
+func TestSpoolStreamMaxSize(t *testing.T) {
+	for _, memoryLimit := range []int64{-1, 100} {
+		dir := t.TempDir()
+		_, err := transfer.SpoolStream(bytes.NewReader(make([]byte, 51)), transfer.SpoolOptions{
+			Name: "stdin", MemoryLimit: memoryLimit, Dir: dir, MaxSize: 50,
+		})
+		if err == nil {
+			t.Errorf("memory limit %d: 51 bytes were spooled with a maximum of 50", memoryLimit)
+		}
+		if files := tempFiles(t, dir); files != 0 {
+			t.Errorf("memory limit %d: %d temporary files left after failing", memoryLimit, files)
+		}
+
+		spool, err := transfer.SpoolStream(bytes.NewReader(make([]byte, 50)), transfer.SpoolOptions{
+			Name: "stdin", MemoryLimit: memoryLimit, Dir: dir, MaxSize: 50,
+		})
+		if err != nil {
+			t.Errorf("memory limit %d: %v", memoryLimit, err)
+			continue
+		}
+		spool.Close()
+	}
+
+	readErr := errors.New("broken pipe")
+	_, err := transfer.SpoolStream(iotest.ErrReader(readErr), transfer.SpoolOptions{Name: "stdin"})
+	if !errors.Is(err, readErr) {
+		t.Errorf("got %v, want the read error", err)
+	}
+}
+
+func TestUploadSpool(t *testing.T) {
+	graphClient, server, _ := newMockClient(t)
+	_, content := writeFile(t, "large.bin", 3*sliceSize+10, 7)
+	spool, err := transfer.SpoolStream(bytes.NewReader(content), transfer.SpoolOptions{
+		Name: "large.bin", MemoryLimit: sliceSize, Dir: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spool.Close()
+
+	sessions := &driveSessions{graphClient: graphClient, itemPath: "Documents/large.bin"}
+	session, err := sessions.create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := transfer.UploadContext[models.DriveItemable](context.Background(), graphClient.RequestAdapter,
+		session, spool, models.CreateDriveItemFromDiscriminatorValue, transfer.UploadOptions{SliceSize: sliceSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := spool.Verify(result.Item); err != nil {
+		t.Errorf("Verify = %v, want nil", err)
+	}
+	uploaded, _ := server.DriveItemContent("Documents/large.bin")
+	if !bytes.Equal(uploaded, content) {
+		t.Error("uploaded content does not match")
+	}
+
+	// Content that differs from the spool is reported
+	changed, _ := server.DriveItemContent("Documents/large.bin")
+	changed[0] ^= 0xff
+	server.SetDriveItemContent("Documents/other.bin", changed)
+	other, err := graphClient.Drives().ByDriveId("mock-drive-id").Items().ByDriveItemId("root:/Documents/other.bin:").
+		Get(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mismatch *transfer.MismatchError
+	if err := spool.Verify(other); !errors.As(err, &mismatch) {
+		t.Errorf("Verify of changed content = %v, want a mismatch", err)
+	}
+}
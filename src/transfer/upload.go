@@ -0,0 +1,157 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	"github.com/microsoft/kiota-abstractions-go/serialization"
+	"github.com/microsoftgraph/msgraph-sdk-go-core/fileuploader"
+)
+
+// UploadOutcome is how an upload ended.
+type UploadOutcome int
+
+const (
+	// UploadCompleted means every slice was accepted
+	UploadCompleted UploadOutcome = iota
+	// UploadCancelled means the context ended before the last slice
+	// and the upload session was deleted
+	UploadCancelled
+	// UploadFailed means a slice was rejected. The upload session is
+	// left as it is, so the upload can be resumed.
+	UploadFailed
+)
+
+func (o UploadOutcome) String() string {
+	switch o {
+	case UploadCompleted:
+		return "completed"
+	case UploadCancelled:
+		return "cancelled"
+	case UploadFailed:
+		return "failed"
+	}
+
+	return fmt.Sprintf("UploadOutcome(%d)", int(o))
+}
+
+// UploadResult reports how UploadContext ended.
+type UploadResult[T serialization.Parsable] struct {
+	Outcome UploadOutcome
+	// Item is the item created by the final slice. It is only set
+	// if the upload completed.
+	Item T
+	// Location is the URL of the created item, from the final slice.
+	// Attachment uploads return it instead of the item.
+	Location string
+	// BytesCommitted is the number of bytes the session has accepted,
+	// including any accepted before UploadContext was called
+	BytesCommitted int64
+}
+
+// UploadOptions configures UploadContext.
+type UploadOptions struct {
+	// SliceSize is the size of each upload request, a multiple
+	// of 320 KiB. The default is DefaultSliceSize.
+	SliceSize int64
+	// Progress is called after each slice is accepted, with the
+	// last byte of the slice and the size of the upload
+	Progress fileuploader.ProgressCallBack
+}
+
+// UploadContext uploads byteStream to session, like the Upload method of
+// a task from fileuploader.NewLargeFileUploadTask, sending the ranges in
+// the session's nextExpectedRanges. ctx is checked before each slice, so
+// a slice already being sent finishes first. If ctx ends before the
+// upload completes, the session is deleted and the result is
+// UploadCancelled with an error wrapping ctx.Err().
+//
+// The returned result is never nil, and the error is nil only if the
+// upload completed. When an upload fails, session is updated with the
+// ranges that are still missing, so it can be passed to UploadContext
+// again to resume.
+func UploadContext[T serialization.Parsable](ctx context.Context, adapter abstractions.RequestAdapter,
+	session fileuploader.UploadSession, byteStream fileuploader.ByteStream, factory serialization.ParsableFactory,
+	options UploadOptions) (*UploadResult[T], error) {
+	if options.SliceSize == 0 {
+		options.SliceSize = DefaultSliceSize
+	}
+
+	result := &UploadResult[T]{Outcome: UploadFailed}
+	info, err := byteStream.Stat()
+	if err != nil {
+		return result, fmt.Errorf("reading size of upload: %w", err)
+	}
+	size := info.Size()
+
+	remaining := parseRanges(session.GetNextExpectedRanges(), size)
+	committed := complementRanges(formatRanges(remaining), size)
+	result.BytesCommitted = size
+	for _, r := range remaining {
+		result.BytesCommitted -= r.end - r.start + 1
+	}
+	// Whatever happens, the session is left expecting what was not sent
+	defer func() {
+		session.SetNextExpectedRanges(complementRanges(committed, size))
+	}()
+
+	task := fileuploader.NewLargeFileUploadTask[T](adapter, session, byteStream, options.SliceSize, factory, errorMapping)
+	var item T
+	for _, r := range remaining {
+		for start := r.start; start <= r.end; start += options.SliceSize {
+			if ctx.Err() != nil {
+				result.Outcome = UploadCancelled
+				return result, cancelUpload(ctx, task, result.BytesCommitted)
+			}
+
+			// The task sends every expected range, so it is
+			// given one slice at a time
+			slice := byteRange{start: start, end: min(start+options.SliceSize-1, r.end)}
+			session.SetNextExpectedRanges([]string{slice.String()})
+			sliceResult := task.Upload(func(int64, int64) {})
+			if !sliceResult.GetUploadSucceeded() && ctx.Err() != nil {
+				// A byteStream that waits on ctx, such as one from
+				// bandwidth.LimitByteStream, fails once it ends
+				result.Outcome = UploadCancelled
+				return result, cancelUpload(ctx, task, result.BytesCommitted)
+			}
+			if !sliceResult.GetUploadSucceeded() {
+				return result, fmt.Errorf("uploading bytes %s: %w", slice, errors.Join(sliceResult.GetResponseErrors()...))
+			}
+
+			item = sliceResult.GetItemResponse()
+			if sliceResult.GetURI() != nil {
+				result.Location = *sliceResult.GetURI()
+			}
+			committed = addRange(committed, slice.start, slice.end)
+			result.BytesCommitted += slice.end - slice.start + 1
+			if options.Progress != nil {
+				options.Progress(slice.end, size)
+			}
+		}
+	}
+
+	result.Outcome = UploadCompleted
+	result.Item = item
+	return result, nil
+}
+
+// cancelUpload deletes the session of task after ctx ended
+func cancelUpload[T serialization.Parsable](ctx context.Context, task fileuploader.LargeFileUploadTask[T],
+	committed int64) error {
+	err := fmt.Errorf("upload cancelled after %d bytes: %w", committed, ctx.Err())
+
+	// The session may already have expired
+	cancelErr := task.Cancel()
+	if cancelErr != nil && !isStatus(cancelErr, http.StatusNotFound) {
+		err = errors.Join(err, fmt.Errorf("deleting upload session: %w", cancelErr))
+	}
+
+	return err
+}
@@ -0,0 +1,148 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sdksnippets/transfer"
+	"testing"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+// sessionExists reports whether the service still has the upload session
+func sessionExists(t *testing.T, session models.UploadSessionable) bool {
+	t.Helper()
+
+	response, err := http.Get(*session.GetUploadUrl())
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	return response.StatusCode == http.StatusOK
+}
+
+func TestUploadContext(t *testing.T) {
+	graphClient, server, log := newMockClient(t)
+	path, content := writeFile(t, "large.bin", 3*sliceSize+10, 1)
+	sessions := &driveSessions{graphClient: graphClient, itemPath: "Documents/large.bin"}
+	session, err := sessions.create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var progress []int64
+	result, err := transfer.UploadContext[models.DriveItemable](context.Background(), graphClient.RequestAdapter,
+		session, file, models.CreateDriveItemFromDiscriminatorValue, transfer.UploadOptions{
+			SliceSize: sliceSize,
+			Progress: func(current int64, total int64) {
+				progress = append(progress, current)
+			},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Outcome != transfer.UploadCompleted || result.BytesCommitted != int64(len(content)) {
+		t.Errorf("got %v with %d bytes, want completed with %d", result.Outcome, result.BytesCommitted, len(content))
+	}
+	if result.Item == nil || result.Item.GetId() == nil {
+		t.Error("result has no item")
+	}
+	if len(log.ranges) != 4 || len(progress) != 4 || progress[3] != int64(len(content)-1) {
+		t.Errorf("sent ranges %v with progress %v, want 4 slices", log.ranges, progress)
+	}
+	uploaded, _ := server.DriveItemContent("Documents/large.bin")
+	if !bytes.Equal(uploaded, content) {
+		t.Error("uploaded content does not match")
+	}
+}
+
+func TestUploadContextCancelled(t *testing.T) {
+	graphClient, server, log := newMockClient(t)
+	path, _ := writeFile(t, "large.bin", 5*sliceSize, 2)
+	sessions := &driveSessions{graphClient: graphClient, itemPath: "Documents/large.bin"}
+	session, err := sessions.create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	slices := 0
+	result, err := transfer.UploadContext[models.DriveItemable](ctx, graphClient.RequestAdapter,
+		session, file, models.CreateDriveItemFromDiscriminatorValue, transfer.UploadOptions{
+			SliceSize: sliceSize,
+			Progress: func(current int64, total int64) {
+				slices++
+				if slices == 2 {
+					cancel()
+				}
+			},
+		})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+	if result.Outcome != transfer.UploadCancelled || result.BytesCommitted != 2*sliceSize {
+		t.Errorf("got %v with %d bytes, want cancelled with %d", result.Outcome, result.BytesCommitted, 2*sliceSize)
+	}
+	if len(log.ranges) != 2 {
+		t.Errorf("sent ranges %v after cancelling, want 2", log.ranges)
+	}
+	if sessionExists(t, session) {
+		t.Error("upload session was not deleted")
+	}
+	if _, found := server.DriveItemContent("Documents/large.bin"); found {
+		t.Error("cancelled upload created the item")
+	}
+}
+
+func TestUploadContextFailed(t *testing.T) {
+	graphClient, _, _ := newMockClient(t)
+	path, _ := writeFile(t, "large.bin", 2*sliceSize, 3)
+	sessions := &driveSessions{graphClient: graphClient, itemPath: "Documents/large.bin"}
+	session, err := sessions.create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	// Every slice is rejected with 416 once the first is skipped
+	session.SetNextExpectedRanges([]string{"327680-"})
+	result, err := transfer.UploadContext[models.DriveItemable](context.Background(), graphClient.RequestAdapter,
+		session, file, models.CreateDriveItemFromDiscriminatorValue, transfer.UploadOptions{SliceSize: sliceSize})
+
+	if err == nil || result.Outcome != transfer.UploadFailed {
+		t.Fatalf("got %v with error %v, want failed", result.Outcome, err)
+	}
+	if result.BytesCommitted != sliceSize {
+		t.Errorf("got %d bytes committed, want the %d before the expected range", result.BytesCommitted, sliceSize)
+	}
+	if ranges := session.GetNextExpectedRanges(); len(ranges) != 1 || ranges[0] != "327680-655359" {
+		t.Errorf("session expects %v, want [327680-655359]", ranges)
+	}
+	if !sessionExists(t, session) {
+		t.Error("failed upload deleted the session")
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sdksnippets/quickxorhash"
+	"strconv"
+	"strings"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+// MismatchError reports that an item in the drive does not have
+// the content of the local file it was uploaded from.
+type MismatchError struct {
+	Path string
+	// Property is size, or the name of the hash in
+	// file.hashes, such as quickXorHash
+	Property string
+	Local    string
+	Remote   string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("uploaded item does not match %s: local %s is %s, remote is %s",
+		e.Path, e.Property, e.Local, e.Remote)
+}
+
+// VerifyItem checks that item, as returned by an upload, has the size of
+// the file at path and the same value for every hash in its file.hashes:
+// quickXorHash, sha256Hash and sha1Hash. The file is read once however
+// many hashes are reported. It returns a *MismatchError for the first
+// property that differs.
+//
+// Only the size is checked if the item reports no hashes.
+func VerifyItem(item models.DriveItemable, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("reading size of %s: %w", path, err)
+	}
+
+	return verifyContent(item, file, info.Size(), path)
+}
+
+func verifyContent(item models.DriveItemable, file io.ReaderAt, size int64, path string) error {
+	if item.GetSize() != nil && *item.GetSize() != size {
+		return &MismatchError{
+			Path:     path,
+			Property: "size",
+			Local:    strconv.FormatInt(size, 10),
+			Remote:   strconv.FormatInt(*item.GetSize(), 10),
+		}
+	}
+
+	return checkHashes(file, size, path, reportedHashes(item))
+}
+
+// fileHash is a hash Graph reported for an item, and
+// the same hash being computed for a local file
+type fileHash struct {
+	property string
+	remote   string
+	local    hash.Hash
+	encode   func([]byte) string
+}
+
+func (h *fileHash) matches() bool {
+	local := h.encode(h.local.Sum(nil))
+	if h.property == "quickXorHash" {
+		// Base64 is case sensitive
+		return local == h.remote
+	}
+
+	return strings.EqualFold(local, h.remote)
+}
+
+// reportedHashes returns the hashes item reports, strongest first
+func reportedHashes(item models.DriveItemable) []*fileHash {
+	if item.GetFile() == nil || item.GetFile().GetHashes() == nil {
+		return nil
+	}
+	hashes := item.GetFile().GetHashes()
+
+	var reported []*fileHash
+	if value := hashes.GetQuickXorHash(); value != nil {
+		reported = append(reported, &fileHash{"quickXorHash", *value, quickxorhash.New(), quickxorhash.EncodeToString})
+	}
+	if value := hashes.GetSha256Hash(); value != nil {
+		reported = append(reported, &fileHash{"sha256Hash", *value, sha256.New(), hex.EncodeToString})
+	}
+	if value := hashes.GetSha1Hash(); value != nil {
+		reported = append(reported, &fileHash{"sha1Hash", *value, sha1.New(), hex.EncodeToString})
+	}
+
+	return reported
+}
+
+// checkHashes computes every hash in reported over the first size bytes
+// of file and returns a *MismatchError for the first that differs
+func checkHashes(file io.ReaderAt, size int64, path string, reported []*fileHash) error {
+	if len(reported) == 0 {
+		return nil
+	}
+
+	writers := make([]io.Writer, len(reported))
+	for i, h := range reported {
+		writers[i] = h.local
+	}
+	_, err := io.Copy(io.MultiWriter(writers...), io.NewSectionReader(file, 0, size))
+	if err != nil {
+		return fmt.Errorf("hashing %s: %w", path, err)
+	}
+
+	for _, h := range reported {
+		if !h.matches() {
+			return &MismatchError{
+				Path:     path,
+				Property: h.property,
+				Local:    h.encode(h.local.Sum(nil)),
+				Remote:   h.remote,
+			}
+		}
+	}
+
+	return nil
+}
+
+// isMismatch reports whether err is a *MismatchError
+func isMismatch(err error) bool {
+	var mismatch *MismatchError
+	return errors.As(err, &mismatch)
+}
@@ -0,0 +1,135 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package transfer_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sdksnippets/graphmock"
+	"sdksnippets/transfer"
+	"testing"
+
+	khttp "github.com/microsoft/kiota-http-go"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+)
+
+// corruptUploads flips the first byte of every PUT, as if
+// the content had been damaged on the way to the service
+type corruptUploads struct{}
+
+func (corruptUploads) Intercept(pipeline khttp.Pipeline, middlewareIndex int, req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPut || req.Body == nil {
+		return pipeline.Next(req, middlewareIndex)
+	}
+
+	// Small bodies have already been compressed
+	var reader io.Reader = req.Body
+	gzipped := req.Header.Get("Content-Encoding") == "gzip"
+	if gzipped {
+		gzipReader, err := gzip.NewReader(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		reader = gzipReader
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+
+	if len(body) > 0 {
+		body[0] ^= 0xff
+	}
+	if gzipped {
+		var compressed bytes.Buffer
+		gzipWriter := gzip.NewWriter(&compressed)
+		gzipWriter.Write(body)
+		gzipWriter.Close()
+		body = compressed.Bytes()
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+
+	return pipeline.Next(req, middlewareIndex)
+}
+
+func TestVerifyItem(t *testing.T) {
+	graphClient, _, _ := newMockClient(t)
+	path, _ := writeFile(t, "large.bin", 2*sliceSize+10, 3)
+	sessions := &driveSessions{graphClient: graphClient, itemPath: "Documents/large.bin"}
+
+	item, err := transfer.UploadResumable[models.DriveItemable](graphClient.RequestAdapter, path, sessions.create,
+		models.CreateDriveItemFromDiscriminatorValue, transfer.ResumableUploadOptions{SliceSize: sliceSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes := item.GetFile().GetHashes()
+	if hashes.GetQuickXorHash() == nil || hashes.GetSha1Hash() == nil || hashes.GetSha256Hash() == nil {
+		t.Fatal("uploaded item is missing hashes")
+	}
+
+	err = transfer.VerifyItem(item, path)
+	if err != nil {
+		t.Errorf("VerifyItem = %v, want nil", err)
+	}
+
+	// Each hash is checked, not just the first
+	for _, property := range []string{"quickXorHash", "sha256Hash", "sha1Hash", "size"} {
+		changed := models.NewDriveItem()
+		changed.SetSize(item.GetSize())
+		changed.SetFile(models.NewFile())
+		changedHashes := models.NewHashes()
+		changedHashes.SetQuickXorHash(hashes.GetQuickXorHash())
+		changedHashes.SetSha256Hash(hashes.GetSha256Hash())
+		changedHashes.SetSha1Hash(hashes.GetSha1Hash())
+		changed.GetFile().SetHashes(changedHashes)
+
+		wrongHash := "AAAA"
+		wrongSize := *item.GetSize() + 1
+		switch property {
+		case "quickXorHash":
+			changedHashes.SetQuickXorHash(&wrongHash)
+		case "sha256Hash":
+			changedHashes.SetSha256Hash(&wrongHash)
+		case "sha1Hash":
+			changedHashes.SetSha1Hash(&wrongHash)
+		case "size":
+			changed.SetSize(&wrongSize)
+		}
+
+		var mismatch *transfer.MismatchError
+		err = transfer.VerifyItem(changed, path)
+		if !errors.As(err, &mismatch) || mismatch.Property != property {
+			t.Errorf("changed %s: VerifyItem = %v, want a %s mismatch", property, err, property)
+		}
+	}
+}
+
+func TestUploadsFailOnMismatch(t *testing.T) {
+	graphClient, _ := graphmock.NewTestClient(t, corruptUploads{})
+
+	path, _ := writeFile(t, "large.bin", 2*sliceSize, 5)
+	sessions := &driveSessions{graphClient: graphClient, itemPath: "Documents/large.bin"}
+	item, err := transfer.UploadResumable[models.DriveItemable](graphClient.RequestAdapter, path, sessions.create,
+		models.CreateDriveItemFromDiscriminatorValue, transfer.ResumableUploadOptions{SliceSize: sliceSize})
+	var mismatch *transfer.MismatchError
+	if !errors.As(err, &mismatch) || item == nil {
+		t.Errorf("UploadResumable = %v, want a mismatch and the item", err)
+	}
+
+	dir := writeTree(t, map[string][]byte{"small.txt": []byte("small file")})
+	summary, err := transfer.UploadFolder(context.Background(), graphClient, "mock-drive-id", dir, "Backup",
+		transfer.FolderUploadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Uploaded != 0 || len(summary.Failures) != 1 || !errors.As(summary.Failures[0], &mismatch) {
+		t.Errorf("summary = %+v, want one mismatch", summary)
+	}
+}